@@ -15,6 +15,7 @@ import (
 
 	"github.com/vrutkovs/synapse-operator/pkg/apis"
 	"github.com/vrutkovs/synapse-operator/pkg/controller"
+	"github.com/vrutkovs/synapse-operator/pkg/webhook"
 	"github.com/vrutkovs/synapse-operator/version"
 
 	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
@@ -38,6 +39,7 @@ var (
 	metricsHost               = "0.0.0.0"
 	metricsPort         int32 = 8383
 	operatorMetricsPort int32 = 8686
+	webhookPort               = 9443
 )
 var log = logf.Log.WithName("cmd")
 
@@ -57,6 +59,10 @@ func main() {
 	// controller-runtime)
 	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
 
+	// Webhooks require serving certificates issued by cert-manager, so these are opt-in
+	enableWebhooks := pflag.Bool("enable-webhooks", false, "Serve admission webhooks, certificates are read from --webhook-cert-dir")
+	webhookCertDir := pflag.String("webhook-cert-dir", "/tmp/k8s-webhook-server/serving-certs", "Directory with tls.crt and tls.key for webhook server")
+
 	pflag.Parse()
 
 	// Use a zap logr.Logger implementation. If none of the zap
@@ -96,6 +102,8 @@ func main() {
 	options := manager.Options{
 		Namespace:          namespace,
 		MetricsBindAddress: fmt.Sprintf("%s:%d", metricsHost, metricsPort),
+		Port:               webhookPort,
+		CertDir:            *webhookCertDir,
 	}
 
 	// Add support for MultiNamespace set in WATCH_NAMESPACE (e.g ns1,ns2)
@@ -128,6 +136,14 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Setup admission webhooks
+	if *enableWebhooks {
+		if err := webhook.AddToManager(mgr); err != nil {
+			log.Error(err, "")
+			os.Exit(1)
+		}
+	}
+
 	// Add the Metrics Service
 	addMetrics(ctx, cfg)
 
@@ -0,0 +1,28 @@
+package common
+
+// MergeConfig recursively merges src into dst. Values from src take precedence,
+// null values remove the key from dst
+func MergeConfig(dst, src map[string]interface{}) {
+	for key, srcValue := range src {
+		if srcValue == nil {
+			delete(dst, key)
+			continue
+		}
+		srcMap, srcIsMap := srcValue.(map[string]interface{})
+		dstMap, dstIsMap := dst[key].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			MergeConfig(dstMap, srcMap)
+			continue
+		}
+		dst[key] = srcValue
+	}
+}
+
+// ToConfigList converts strings to a list as it's decoded from YAML or JSON config
+func ToConfigList(values []string) []interface{} {
+	result := make([]interface{}, len(values))
+	for i, value := range values {
+		result[i] = value
+	}
+	return result
+}
@@ -0,0 +1,3 @@
+// Package common contains types and helpers shared by API groups
+// +kubebuilder:object:generate=true
+package common
@@ -0,0 +1,68 @@
+package common
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// PodTemplate overrides resources, scheduling and security settings of managed pods
+type PodTemplate struct {
+	// Resources are set on the main container of the pod
+	Resources         *corev1.ResourceRequirements `json:"resources,omitempty"`
+	NodeSelector      map[string]string            `json:"nodeSelector,omitempty"`
+	Tolerations       []corev1.Toleration          `json:"tolerations,omitempty"`
+	Affinity          *corev1.Affinity             `json:"affinity,omitempty"`
+	PriorityClassName string                       `json:"priorityClassName,omitempty"`
+	// SecurityContext is a pod-level security context
+	SecurityContext *corev1.PodSecurityContext `json:"securityContext,omitempty"`
+	// ContainerSecurityContext is set on all containers and init containers of the pod
+	ContainerSecurityContext *corev1.SecurityContext `json:"containerSecurityContext,omitempty"`
+}
+
+// Apply merges overrides into generated pod spec. Resources are set on mainContainer only,
+// helper containers keep running without requests and limits
+func (p *PodTemplate) Apply(spec *corev1.PodSpec, mainContainer string) {
+	if p == nil {
+		return
+	}
+	p.ApplyScheduling(spec)
+	if p.SecurityContext != nil {
+		spec.SecurityContext = p.SecurityContext.DeepCopy()
+	}
+	for i := range spec.InitContainers {
+		if p.ContainerSecurityContext != nil {
+			spec.InitContainers[i].SecurityContext = p.ContainerSecurityContext.DeepCopy()
+		}
+	}
+	for i := range spec.Containers {
+		if p.ContainerSecurityContext != nil {
+			spec.Containers[i].SecurityContext = p.ContainerSecurityContext.DeepCopy()
+		}
+		if p.Resources != nil && spec.Containers[i].Name == mainContainer {
+			spec.Containers[i].Resources = *p.Resources.DeepCopy()
+		}
+	}
+}
+
+// ApplyScheduling merges node selector, tolerations, affinity and priority class into pod spec
+func (p *PodTemplate) ApplyScheduling(spec *corev1.PodSpec) {
+	if p == nil {
+		return
+	}
+	if len(p.NodeSelector) > 0 {
+		if spec.NodeSelector == nil {
+			spec.NodeSelector = map[string]string{}
+		}
+		for key, value := range p.NodeSelector {
+			spec.NodeSelector[key] = value
+		}
+	}
+	if len(p.Tolerations) > 0 {
+		spec.Tolerations = append(spec.Tolerations, p.Tolerations...)
+	}
+	if p.Affinity != nil {
+		spec.Affinity = p.Affinity.DeepCopy()
+	}
+	if p.PriorityClassName != "" {
+		spec.PriorityClassName = p.PriorityClassName
+	}
+}
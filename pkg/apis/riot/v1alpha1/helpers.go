@@ -1,5 +1,24 @@
 package v1alpha1
 
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/vrutkovs/synapse-operator/pkg/apis/common"
+)
+
+// DefaultImage is Riot image tested with this operator version
+const DefaultImage = "docker.io/vectorim/riot-web:v1.7.5"
+
+// GetImage returns Riot image, so that the default is applied even if defaulting webhook is not deployed
+func (s *Riot) GetImage() string {
+	if s.Spec.Image == "" {
+		return DefaultImage
+	}
+	return s.Spec.Image
+}
+
 // GetConfigMapName returns managed configmap name
 func (s *Riot) GetConfigMapName() string {
 	return s.ObjectMeta.Name + "-config"
@@ -20,9 +39,125 @@ func (s *Riot) GetServiceName() string {
 	return s.ObjectMeta.Name + "-service"
 }
 
+// GetIngressName returns managed ingress name
+func (s *Riot) GetIngressName() string {
+	return s.ObjectMeta.Name
+}
+
 // GetExpectedConfigmapData returns expected data stored in configmap
-func (s *Riot) GetExpectedConfigmapData() map[string]string {
+func (s *Riot) GetExpectedConfigmapData(baseURL, serverName string) (map[string]string, error) {
+	config, err := s.GenerateConfig(baseURL, serverName)
+	if err != nil {
+		return nil, err
+	}
 	return map[string]string{
-		"config.json": s.Spec.Config,
+		"config.json": config,
+	}, nil
+}
+
+// ParseConfig returns settings set in Config, it's empty if Config is not set
+func (s *Riot) ParseConfig() (map[string]interface{}, error) {
+	config := map[string]interface{}{}
+	if s.Spec.Config == "" {
+		return config, nil
+	}
+	if err := json.Unmarshal([]byte(s.Spec.Config), &config); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	return config, nil
+}
+
+// GenerateConfig returns config.json contents. Default homeserver is set from base URL and server name
+// of referenced Synapse, typed settings are added and Config is merged on top
+func (s *Riot) GenerateConfig(baseURL, serverName string) (string, error) {
+	overrides, err := s.ParseConfig()
+	if err != nil {
+		return "", err
+	}
+	config := s.getTypedConfig(baseURL, serverName)
+	common.MergeConfig(config, overrides)
+	out, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// getTypedConfig returns config generated from referenced Synapse and typed settings
+func (s *Riot) getTypedConfig(baseURL, serverName string) map[string]interface{} {
+	config := map[string]interface{}{}
+	if s.Spec.ServerName != "" {
+		serverName = s.Spec.ServerName
+	}
+	homeserver := map[string]interface{}{}
+	if baseURL != "" {
+		homeserver["base_url"] = baseURL
+	}
+	if serverName != "" {
+		homeserver["server_name"] = serverName
+	}
+	if len(homeserver) > 0 {
+		config["default_server_config"] = map[string]interface{}{
+			"m.homeserver": homeserver,
+		}
+	}
+	if s.Spec.Brand != "" {
+		config["brand"] = s.Spec.Brand
+	}
+	if s.Spec.DefaultTheme != "" {
+		config["default_theme"] = s.Spec.DefaultTheme
+	}
+	if len(s.Spec.Features) > 0 {
+		features := map[string]interface{}{}
+		for name, value := range s.Spec.Features {
+			features[name] = value
+		}
+		config["features"] = features
+	}
+	if len(s.Spec.RoomDirectoryServers) > 0 {
+		config["roomDirectory"] = map[string]interface{}{
+			"servers": common.ToConfigList(s.Spec.RoomDirectoryServers),
+		}
+	}
+	if s.Spec.Jitsi != nil {
+		config["jitsi"] = map[string]interface{}{
+			"preferredDomain": s.Spec.Jitsi.PreferredDomain,
+		}
+	}
+	if s.Spec.Integrations != nil {
+		if s.Spec.Integrations.UIURL != "" {
+			config["integrations_ui_url"] = s.Spec.Integrations.UIURL
+		}
+		if s.Spec.Integrations.RestURL != "" {
+			config["integrations_rest_url"] = s.Spec.Integrations.RestURL
+		}
+		if len(s.Spec.Integrations.WidgetsURLs) > 0 {
+			config["integrations_widgets_urls"] = common.ToConfigList(s.Spec.Integrations.WidgetsURLs)
+		}
+	}
+	return config
+}
+
+// GetServiceAddress returns in-cluster address of Riot service
+func (s *Riot) GetServiceAddress() string {
+	return fmt.Sprintf("%s.%s.svc", s.GetServiceName(), s.Namespace)
+}
+
+// GetURL returns public URL of Riot, it's empty if ingress is not enabled
+func (s *Riot) GetURL() string {
+	if s.Spec.Ingress == nil {
+		return ""
+	}
+	return fmt.Sprintf("https://%s/", s.Spec.Ingress.Host)
+}
+
+// GetImageTag returns tag of the image, digest is returned for images pinned by digest
+func GetImageTag(image string) string {
+	if i := strings.LastIndex(image, "@"); i != -1 {
+		return image[i+1:]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[i+1:]
 	}
+	return "latest"
 }
@@ -1,19 +1,89 @@
 package v1alpha1
 
 import (
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	"github.com/vrutkovs/synapse-operator/pkg/apis/common"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // RiotSpec defines the desired state of Riot
 type RiotSpec struct {
-	Replicas   int    `json:"replicas"`
-	Image      string `json:"image"`
-	ServerName string `json:"serverName"`
-	Config     string `json:"config"`
+	// +kubebuilder:default=1
+	// +optional
+	Replicas int `json:"replicas"`
+	// Image is Riot image, DefaultImage is used if not set
+	// +optional
+	Image string `json:"image"`
+	// SynapseRef references Synapse in the same namespace. Its public URL and server name are used
+	// as default homeserver in generated config
+	SynapseRef *corev1.LocalObjectReference `json:"synapseRef,omitempty"`
+	// ServerName overrides server name of default homeserver. If SynapseRef is not set Riot
+	// discovers homeserver URL via .well-known of this server name
+	ServerName string `json:"serverName,omitempty"`
+	Brand      string `json:"brand,omitempty"`
+	// +kubebuilder:validation:Enum=light;dark
+	DefaultTheme string `json:"defaultTheme,omitempty"`
+	// Features maps feature names to enable, disable or labs
+	Features map[string]string `json:"features,omitempty"`
+	// RoomDirectoryServers are servers listed in room directory
+	RoomDirectoryServers []string          `json:"roomDirectoryServers,omitempty"`
+	Jitsi                *RiotJitsi        `json:"jitsi,omitempty"`
+	Integrations         *RiotIntegrations `json:"integrations,omitempty"`
+	// Config is config.json contents merged on top of generated config
+	Config string `json:"config,omitempty"`
+	// Ingress exposes Riot via Ingress or OpenShift Route
+	Ingress *RiotIngress `json:"ingress,omitempty"`
+	// PodTemplate overrides resources, scheduling and security settings of Riot pods
+	PodTemplate *common.PodTemplate `json:"podTemplate,omitempty"`
 }
 
+// RiotIngress configures external access to Riot, all paths on the host are routed to Riot service.
+// Use the same host as Synapse ingress to serve both on a single domain
+type RiotIngress struct {
+	Host string `json:"host"`
+	// TLSSecretName is a secret with TLS certificate and key for the host.
+	// Ingress controller or router default certificate is used if not set
+	TLSSecretName string `json:"tlsSecretName,omitempty"`
+	// Annotations are added to generated Ingress or Route
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// RiotJitsi configures Jitsi conferences
+type RiotJitsi struct {
+	PreferredDomain string `json:"preferredDomain"`
+}
+
+// RiotIntegrations configures integration manager
+type RiotIntegrations struct {
+	UIURL       string   `json:"uiURL,omitempty"`
+	RestURL     string   `json:"restURL,omitempty"`
+	WidgetsURLs []string `json:"widgetsURLs,omitempty"`
+}
+
+const (
+	// RiotConditionAvailable is true when all desired Riot replicas are available
+	RiotConditionAvailable status.ConditionType = "Available"
+	// RiotConditionConfigValid is true when config is a valid JSON document
+	RiotConditionConfigValid status.ConditionType = "ConfigValid"
+	// RiotConditionSynapseNotFound is true when referenced Synapse doesn't exist
+	RiotConditionSynapseNotFound status.ConditionType = "SynapseNotFound"
+	// RiotConditionFieldConflict is true when fields of managed objects are left to other field managers
+	RiotConditionFieldConflict status.ConditionType = "FieldConflict"
+)
+
 // RiotStatus defines the observed state of Riot
 type RiotStatus struct {
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Version is a tag of deployed Riot image
+	Version       string `json:"version,omitempty"`
+	Replicas      int32  `json:"replicas"`
+	ReadyReplicas int32  `json:"readyReplicas"`
+	// Service is an in-cluster address of Riot service
+	Service string `json:"service,omitempty"`
+	// URL is a public address of Riot, set when ingress is enabled
+	URL        string            `json:"url,omitempty"`
+	Conditions status.Conditions `json:"conditions,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -21,6 +91,10 @@ type RiotStatus struct {
 // Riot is the Schema for the riots API
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:path=riots,scope=Namespaced
+// +kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.status.version`
+// +kubebuilder:printcolumn:name="Ready",type=integer,JSONPath=`.status.readyReplicas`
+// +kubebuilder:printcolumn:name="URL",type=string,JSONPath=`.status.url`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 type Riot struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
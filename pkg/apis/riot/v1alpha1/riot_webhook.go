@@ -0,0 +1,73 @@
+package v1alpha1
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// SetupWebhookWithManager registers Riot webhooks in the manager webhook server
+func (r *Riot) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(r).Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-riot-vrutkovs-eu-v1alpha1-riot,mutating=true,failurePolicy=fail,groups=riot.vrutkovs.eu,resources=riots,verbs=create;update,versions=v1alpha1,name=mriot.vrutkovs.eu
+
+var _ webhook.Defaulter = &Riot{}
+
+// Default implements webhook.Defaulter. Replicas are defaulted by CRD schema only,
+// as zero replicas can't be told apart from unset ones here
+func (r *Riot) Default() {
+	if r.Spec.Image == "" {
+		r.Spec.Image = DefaultImage
+	}
+}
+
+// +kubebuilder:webhook:path=/validate-riot-vrutkovs-eu-v1alpha1-riot,mutating=false,failurePolicy=fail,groups=riot.vrutkovs.eu,resources=riots,verbs=create;update,versions=v1alpha1,name=vriot.vrutkovs.eu
+
+var _ webhook.Validator = &Riot{}
+
+// ValidateCreate implements webhook.Validator
+func (r *Riot) ValidateCreate() error {
+	return r.validate()
+}
+
+// ValidateUpdate implements webhook.Validator
+func (r *Riot) ValidateUpdate(old runtime.Object) error {
+	return r.validate()
+}
+
+// ValidateDelete implements webhook.Validator
+func (r *Riot) ValidateDelete() error {
+	return nil
+}
+
+func (r *Riot) validate() error {
+	specPath := field.NewPath("spec")
+	allErrs := field.ErrorList{}
+	if _, err := r.ParseConfig(); err != nil {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("config"), r.Spec.Config, err.Error()))
+	}
+	if r.Spec.SynapseRef != nil && r.Spec.SynapseRef.Name == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("synapseRef", "name"), "Synapse name must be set"))
+	}
+	for name, value := range r.Spec.Features {
+		switch value {
+		case "enable", "disable", "labs":
+		default:
+			allErrs = append(allErrs, field.NotSupported(specPath.Child("features").Key(name), value, []string{"enable", "disable", "labs"}))
+		}
+	}
+	if r.Spec.Jitsi != nil && r.Spec.Jitsi.PreferredDomain == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("jitsi", "preferredDomain"), "Jitsi domain must be set"))
+	}
+	if r.Spec.Replicas < 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("replicas"), r.Spec.Replicas, "must be non-negative"))
+	}
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(SchemeGroupVersion.WithKind("Riot").GroupKind(), r.Name, allErrs)
+}
@@ -5,7 +5,10 @@
 package v1alpha1
 
 import (
-	runtime "k8s.io/apimachinery/pkg/runtime"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	"github.com/vrutkovs/synapse-operator/pkg/apis/common"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -13,8 +16,8 @@ func (in *Riot) DeepCopyInto(out *Riot) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -36,6 +39,66 @@ func (in *Riot) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RiotIngress) DeepCopyInto(out *RiotIngress) {
+	*out = *in
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RiotIngress.
+func (in *RiotIngress) DeepCopy() *RiotIngress {
+	if in == nil {
+		return nil
+	}
+	out := new(RiotIngress)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RiotIntegrations) DeepCopyInto(out *RiotIntegrations) {
+	*out = *in
+	if in.WidgetsURLs != nil {
+		in, out := &in.WidgetsURLs, &out.WidgetsURLs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RiotIntegrations.
+func (in *RiotIntegrations) DeepCopy() *RiotIntegrations {
+	if in == nil {
+		return nil
+	}
+	out := new(RiotIntegrations)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RiotJitsi) DeepCopyInto(out *RiotJitsi) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RiotJitsi.
+func (in *RiotJitsi) DeepCopy() *RiotJitsi {
+	if in == nil {
+		return nil
+	}
+	out := new(RiotJitsi)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RiotList) DeepCopyInto(out *RiotList) {
 	*out = *in
@@ -72,6 +135,43 @@ func (in *RiotList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RiotSpec) DeepCopyInto(out *RiotSpec) {
 	*out = *in
+	if in.SynapseRef != nil {
+		in, out := &in.SynapseRef, &out.SynapseRef
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+	if in.Features != nil {
+		in, out := &in.Features, &out.Features
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.RoomDirectoryServers != nil {
+		in, out := &in.RoomDirectoryServers, &out.RoomDirectoryServers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Jitsi != nil {
+		in, out := &in.Jitsi, &out.Jitsi
+		*out = new(RiotJitsi)
+		**out = **in
+	}
+	if in.Integrations != nil {
+		in, out := &in.Integrations, &out.Integrations
+		*out = new(RiotIntegrations)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Ingress != nil {
+		in, out := &in.Ingress, &out.Ingress
+		*out = new(RiotIngress)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PodTemplate != nil {
+		in, out := &in.PodTemplate, &out.PodTemplate
+		*out = new(common.PodTemplate)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -88,6 +188,13 @@ func (in *RiotSpec) DeepCopy() *RiotSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RiotStatus) DeepCopyInto(out *RiotStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(status.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
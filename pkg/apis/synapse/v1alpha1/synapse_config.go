@@ -0,0 +1,308 @@
+package v1alpha1
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+
+	"github.com/vrutkovs/synapse-operator/pkg/apis/common"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// ConfigMountPath is a path where homeserver config is mounted in synapse container
+	ConfigMountPath = "/synapse/config"
+	// KeysMountPath is a path where homeserver keys are mounted in synapse container
+	KeysMountPath = "/synapse/keys"
+	// MediaStoreMountPath is a path where media store volume is mounted in synapse container
+	MediaStoreMountPath = "/synapse/media_store"
+)
+
+// GenerateHomeserverConfig returns homeserver.yaml contents rendered from Synapse spec.
+// Main process duties taken over by workers are disabled
+func (s *Synapse) GenerateHomeserverConfig(workers []SynapseWorker) ([]byte, error) {
+	config, err := s.getHomeserverConfig()
+	if err != nil {
+		return nil, err
+	}
+	applyWorkerSettings(config, workers)
+	return yaml.Marshal(config)
+}
+
+// GenerateSecretsConfig returns contents of secrets.yaml with sensitive homeserver settings
+// taken from managed secret data. Synapse merges config files by top-level keys only,
+// so sections containing passwords are copied from homeserver config in full
+func (s *Synapse) GenerateSecretsConfig(data map[string][]byte) ([]byte, error) {
+	config := map[string]interface{}{
+		"macaroon_secret_key":        string(data[SecretKeyMacaroonSecretKey]),
+		"form_secret":                string(data[SecretKeyFormSecret]),
+		"registration_shared_secret": string(data[SecretKeyRegistrationSharedSecret]),
+	}
+
+	dbPassword, hasDBPassword := data[SecretKeyDatabasePassword]
+	smtpPassword, hasSMTPPassword := data[SecretKeySMTPPassword]
+	redisPassword, hasRedisPassword := data[SecretKeyRedisPassword]
+	if hasDBPassword || hasSMTPPassword || hasRedisPassword {
+		homeserverConfig, err := s.getHomeserverConfig()
+		if err != nil {
+			return nil, err
+		}
+		if hasDBPassword {
+			database := copySection(homeserverConfig, "database")
+			args := copySection(database, "args")
+			args["password"] = string(dbPassword)
+			database["args"] = args
+			config["database"] = database
+		}
+		if hasSMTPPassword {
+			email := copySection(homeserverConfig, "email")
+			email["smtp_pass"] = string(smtpPassword)
+			config["email"] = email
+		}
+		if hasRedisPassword {
+			redis := copySection(homeserverConfig, "redis")
+			redis["password"] = string(redisPassword)
+			config["redis"] = redis
+		}
+	}
+	return yaml.Marshal(config)
+}
+
+// copySection returns a shallow copy of config section, or an empty map if it's not set
+func copySection(config map[string]interface{}, key string) map[string]interface{} {
+	section := map[string]interface{}{}
+	if existing, ok := config[key].(map[string]interface{}); ok {
+		for k, v := range existing {
+			section[k] = v
+		}
+	}
+	return section
+}
+
+// getHomeserverConfig merges legacy raw config, structured settings, values derived
+// from the spec and overrides - in that order. Paths and listeners are set last,
+// so that they always match mounted volumes and exposed ports
+func (s *Synapse) getHomeserverConfig() (map[string]interface{}, error) {
+	config, err := s.Spec.Config.parseHomeserver()
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Spec.Config.Settings != nil {
+		if err := s.Spec.Config.Settings.apply(config); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.Spec.Database != nil {
+		config["database"] = s.Spec.Database.toConfig()
+	}
+	if s.Spec.Redis != nil {
+		config["redis"] = s.getRedisConfig()
+	}
+
+	overrides, err := s.Spec.Config.parseOverrides()
+	if err != nil {
+		return nil, err
+	}
+	common.MergeConfig(config, overrides)
+
+	// Paths are defined by the volume layout, so these are set after overrides and cannot be changed by the user
+	if s.Spec.ServerName != "" {
+		config["server_name"] = s.Spec.ServerName
+	}
+	config["log_config"] = path.Join(ConfigMountPath, s.getLogConfigFileName())
+	config["signing_key_path"] = path.Join(KeysMountPath, s.getSigningKeyFileName())
+	if s.Spec.Storage != nil {
+		config["media_store_path"] = MediaStoreMountPath
+	}
+
+	s.applyPortListeners(config)
+
+	return config, nil
+}
+
+// parseHomeserver returns legacy raw homeserver config, or an empty map if it's not set
+func (c *SynapseConfig) parseHomeserver() (map[string]interface{}, error) {
+	config := map[string]interface{}{}
+	if c.Homeserver == "" {
+		return config, nil
+	}
+	if err := yaml.Unmarshal([]byte(c.Homeserver), &config); err != nil {
+		return nil, fmt.Errorf("failed to parse homeserver config: %v", err)
+	}
+	if config == nil {
+		config = map[string]interface{}{}
+	}
+	return config, nil
+}
+
+// parseOverrides returns homeserver config overrides, or an empty map if these are not set
+func (c *SynapseConfig) parseOverrides() (map[string]interface{}, error) {
+	overrides := map[string]interface{}{}
+	if c.Overrides == nil || len(c.Overrides.Raw) == 0 {
+		return overrides, nil
+	}
+	if err := json.Unmarshal(c.Overrides.Raw, &overrides); err != nil {
+		return nil, fmt.Errorf("failed to parse homeserver config overrides: %v", err)
+	}
+	return overrides, nil
+}
+
+// applyWorkerSettings sets homeserver settings required by running workers.
+// Scaled down workers are skipped, so that main process resumes their duties
+func applyWorkerSettings(config map[string]interface{}, workers []SynapseWorker) {
+	for _, worker := range workers {
+		if worker.Spec.Replicas == 0 {
+			continue
+		}
+		for key, value := range worker.GetMainProcessSettings() {
+			config[key] = value
+		}
+	}
+}
+
+func parseFactor(name, value string) (float64, error) {
+	factor, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cache factor %s %q: %v", name, value, err)
+	}
+	return factor, nil
+}
+
+func (ss *SynapseSettings) apply(config map[string]interface{}) error {
+	if ss.PublicBaseURL != "" {
+		config["public_baseurl"] = ss.PublicBaseURL
+	}
+	if len(ss.Listeners) > 0 {
+		listeners := []interface{}{}
+		for _, listener := range ss.Listeners {
+			listeners = append(listeners, listener.toConfig())
+		}
+		config["listeners"] = listeners
+	}
+	if ss.Database != nil {
+		config["database"] = ss.Database.toConfig()
+	}
+	if ss.MediaStore != nil {
+		ss.MediaStore.apply(config)
+	}
+	if ss.Registration != nil {
+		ss.Registration.apply(config)
+	}
+	if ss.Federation != nil {
+		ss.Federation.apply(config)
+	}
+	if ss.Caches != nil {
+		if err := ss.Caches.apply(config); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *SynapseListener) toConfig() map[string]interface{} {
+	bindAddresses := l.BindAddresses
+	if len(bindAddresses) == 0 {
+		bindAddresses = []string{"0.0.0.0"}
+	}
+	listener := map[string]interface{}{
+		"port":           l.Port,
+		"type":           l.Type,
+		"tls":            l.TLS,
+		"x_forwarded":    l.XForwarded,
+		"bind_addresses": common.ToConfigList(bindAddresses),
+	}
+	if len(l.Resources) > 0 {
+		resources := []interface{}{}
+		for _, resource := range l.Resources {
+			resources = append(resources, map[string]interface{}{
+				"names":    common.ToConfigList(resource.Names),
+				"compress": resource.Compress,
+			})
+		}
+		listener["resources"] = resources
+	}
+	return listener
+}
+
+func (d *SynapseDatabaseSettings) toConfig() map[string]interface{} {
+	args := map[string]interface{}{}
+	for key, value := range d.Args {
+		args[key] = value
+	}
+	return map[string]interface{}{
+		"name": d.Name,
+		"args": args,
+	}
+}
+
+func (m *SynapseMediaStoreSettings) apply(config map[string]interface{}) {
+	if m.Path != "" {
+		config["media_store_path"] = m.Path
+	}
+	if m.MaxUploadSize != "" {
+		config["max_upload_size"] = m.MaxUploadSize
+	}
+	config["url_preview_enabled"] = m.URLPreviewEnabled
+	if len(m.URLPreviewIPRangeBlacklist) > 0 {
+		config["url_preview_ip_range_blacklist"] = common.ToConfigList(m.URLPreviewIPRangeBlacklist)
+	}
+}
+
+func (r *SynapseRegistrationSettings) apply(config map[string]interface{}) {
+	config["enable_registration"] = r.Enabled
+	config["allow_guest_access"] = r.AllowGuestAccess
+	if len(r.RequireThreePID) > 0 {
+		config["registrations_require_3pid"] = common.ToConfigList(r.RequireThreePID)
+	}
+	if len(r.AutoJoinRooms) > 0 {
+		config["auto_join_rooms"] = common.ToConfigList(r.AutoJoinRooms)
+	}
+}
+
+func (f *SynapseFederationSettings) apply(config map[string]interface{}) {
+	if len(f.DomainWhitelist) > 0 {
+		config["federation_domain_whitelist"] = common.ToConfigList(f.DomainWhitelist)
+	}
+	if len(f.IPRangeBlacklist) > 0 {
+		config["federation_ip_range_blacklist"] = common.ToConfigList(f.IPRangeBlacklist)
+	}
+	if len(f.TrustedKeyServers) > 0 {
+		servers := []interface{}{}
+		for _, server := range f.TrustedKeyServers {
+			servers = append(servers, map[string]interface{}{"server_name": server})
+		}
+		config["trusted_key_servers"] = servers
+	}
+}
+
+func (c *SynapseCachesSettings) apply(config map[string]interface{}) error {
+	caches := map[string]interface{}{}
+	if c.GlobalFactor != "" {
+		factor, err := parseFactor("globalFactor", c.GlobalFactor)
+		if err != nil {
+			return err
+		}
+		caches["global_factor"] = factor
+	}
+	if len(c.PerCacheFactors) > 0 {
+		perCacheFactors := map[string]interface{}{}
+		for name, value := range c.PerCacheFactors {
+			factor, err := parseFactor(name, value)
+			if err != nil {
+				return err
+			}
+			perCacheFactors[name] = factor
+		}
+		caches["per_cache_factors"] = perCacheFactors
+	}
+	if len(caches) > 0 {
+		config["caches"] = caches
+	}
+	if c.EventCacheSize != "" {
+		config["event_cache_size"] = c.EventCacheSize
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package v1alpha1
+
+import (
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	defaultDatabasePort  = 5432
+	defaultDatabaseCPMin = 5
+	defaultDatabaseCPMax = 10
+	// DefaultDatabaseWaitImage provides pg_isready used to wait for database
+	DefaultDatabaseWaitImage = "docker.io/library/postgres:12-alpine"
+)
+
+func (d *SynapseDatabase) getPort() int {
+	if d.Port == 0 {
+		return defaultDatabasePort
+	}
+	return d.Port
+}
+
+// toConfig returns homeserver database section. Password is not included,
+// it's rendered from the managed secret into secrets.yaml
+func (d *SynapseDatabase) toConfig() map[string]interface{} {
+	cpMin, cpMax := d.CPMin, d.CPMax
+	if cpMin == 0 {
+		cpMin = defaultDatabaseCPMin
+	}
+	if cpMax == 0 {
+		cpMax = defaultDatabaseCPMax
+	}
+	args := map[string]interface{}{
+		"user":     d.User,
+		"database": d.Name,
+		"host":     d.Host,
+		"port":     d.getPort(),
+		"cp_min":   cpMin,
+		"cp_max":   cpMax,
+	}
+	if d.SSLMode != "" {
+		args["sslmode"] = d.SSLMode
+	}
+	return map[string]interface{}{
+		"name": "psycopg2",
+		"args": args,
+	}
+}
+
+// getDatabasePasswordRef returns reference to database password, the one set in database section takes precedence
+func (s *Synapse) getDatabasePasswordRef() *corev1.SecretKeySelector {
+	if s.Spec.Database != nil && s.Spec.Database.PasswordSecretRef != nil {
+		return s.Spec.Database.PasswordSecretRef
+	}
+	return s.Spec.Secrets.DatabasePasswordRef
+}
+
+// GetInitContainers returns init containers, which should complete before homeserver is started
+func (s *Synapse) GetInitContainers() []corev1.Container {
+	if s.Spec.Database == nil {
+		return nil
+	}
+	image := s.Spec.Database.WaitImage
+	if image == "" {
+		image = DefaultDatabaseWaitImage
+	}
+	return []corev1.Container{
+		{
+			Name:    "wait-for-database",
+			Image:   image,
+			Command: []string{"sh", "-c", "until pg_isready; do echo waiting for database; sleep 2; done"},
+			Env: []corev1.EnvVar{
+				{Name: "PGHOST", Value: s.Spec.Database.Host},
+				{Name: "PGPORT", Value: strconv.Itoa(s.Spec.Database.getPort())},
+				{Name: "PGDATABASE", Value: s.Spec.Database.Name},
+				{Name: "PGUSER", Value: s.Spec.Database.User},
+			},
+		},
+	}
+}
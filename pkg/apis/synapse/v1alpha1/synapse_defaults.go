@@ -0,0 +1,17 @@
+package v1alpha1
+
+const (
+	// DefaultImage is Synapse image tested with this operator version.
+	// Generated JSON logging config needs synapse.logging.TerseJsonFormatter, which 1.19 doesn't have
+	DefaultImage = "docker.io/matrixdotorg/synapse:v1.21.2"
+	// DefaultHTTPPort is a default client and federation port
+	DefaultHTTPPort = 8008
+	// DefaultHTTPSPort is a default TLS client and federation port
+	DefaultHTTPSPort = 8448
+	// DefaultReplicationPort is a default port workers connect to
+	DefaultReplicationPort = 9093
+	// DefaultWorkerProtocol is a default worker listener type
+	DefaultWorkerProtocol = "http"
+	// DefaultSynapseDeletionPolicy is a default worker policy on Synapse deletion
+	DefaultSynapseDeletionPolicy = SynapseDeletionPolicyDelete
+)
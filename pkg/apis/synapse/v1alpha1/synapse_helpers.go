@@ -1,10 +1,32 @@
 package v1alpha1
 
+import (
+	"path"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// HomeserverApp is a Synapse app run by the main process
+const HomeserverApp = "synapse.app.homeserver"
+
+// GetImage returns Synapse image, so that the default is applied even if defaulting webhook is not deployed
+func (s *Synapse) GetImage() string {
+	if s.Spec.Image == "" {
+		return DefaultImage
+	}
+	return s.Spec.Image
+}
+
 // GetConfigMapName returns managed configmap name
 func (s *Synapse) GetConfigMapName() string {
 	return s.ObjectMeta.Name + "-config"
 }
 
+// GetRoutingConfigMapName returns managed configmap name with worker routing config
+func (s *Synapse) GetRoutingConfigMapName() string {
+	return s.ObjectMeta.Name + "-routing"
+}
+
 // GetSecretName returns managed secret name
 func (s *Synapse) GetSecretName() string {
 	return s.ObjectMeta.Name + "-secret"
@@ -24,3 +46,91 @@ func (s *Synapse) GetDeploymentPodName() string {
 func (s *Synapse) GetServiceName() string {
 	return s.ObjectMeta.Name + "-service"
 }
+
+// GetMediaStorePVCName returns managed media store persistent volume claim name
+func (s *Synapse) GetMediaStorePVCName() string {
+	return s.ObjectMeta.Name + "-media-store"
+}
+
+// GetIngressName returns managed ingress name
+func (s *Synapse) GetIngressName() string {
+	return s.ObjectMeta.Name
+}
+
+// GetIngressHost returns public hostname of the ingress
+func (s *Synapse) GetIngressHost() string {
+	if s.Spec.Ingress != nil && s.Spec.Ingress.Host != "" {
+		return s.Spec.Ingress.Host
+	}
+	return s.Spec.ServerName
+}
+
+// GetProxyName returns name of managed reverse proxy configmap, deployment and service
+func (s *Synapse) GetProxyName() string {
+	return s.ObjectMeta.Name + "-proxy"
+}
+
+// GetIngressServiceName returns service which receives traffic from ingress
+func (s *Synapse) GetIngressServiceName() string {
+	if s.Spec.Proxy != nil {
+		return s.GetProxyName()
+	}
+	return s.GetServiceName()
+}
+
+// GetTLSSecretNames returns secrets with TLS certificates for homeserver and well-known hosts
+func (s *Synapse) GetTLSSecretNames() []string {
+	names := []string{}
+	if s.Spec.Ingress != nil && s.Spec.Ingress.TLSSecretName != "" {
+		names = append(names, s.Spec.Ingress.TLSSecretName)
+	}
+	if s.Spec.WellKnown != nil && s.Spec.WellKnown.Ingress != nil && s.Spec.WellKnown.Ingress.TLSSecretName != "" {
+		names = append(names, s.Spec.WellKnown.Ingress.TLSSecretName)
+	}
+	return names
+}
+
+// getLogConfigFileName returns logging config file name in the config volume
+func (s *Synapse) getLogConfigFileName() string {
+	return s.Spec.ServerName + ".log.config"
+}
+
+// GetCommand returns command running Synapse app with homeserver config, secrets and extra config files.
+// Command is set explicitly, so that pods don't depend on image entrypoint and its config layout
+func (s *Synapse) GetCommand(app string, configFiles ...string) []string {
+	command := []string{
+		"python", "-m", app,
+		"--config-path", path.Join(ConfigMountPath, "homeserver.yaml"),
+		"--config-path", path.Join(KeysMountPath, SecretKeySecretsConfig),
+	}
+	for _, file := range configFiles {
+		command = append(command, "--config-path", file)
+	}
+	return command
+}
+
+// getSigningKeyFileName returns signing key file name in the keys volume
+func (s *Synapse) getSigningKeyFileName() string {
+	return s.Spec.ServerName + ".signing.key"
+}
+
+// GetSecretRefs returns references to existing secrets keyed by managed secret key
+func (s *Synapse) GetSecretRefs() map[string]*corev1.SecretKeySelector {
+	refs := map[string]*corev1.SecretKeySelector{
+		SecretKeyCert:                     s.Spec.Secrets.CertRef,
+		SecretKeyKey:                      s.Spec.Secrets.KeyRef,
+		SecretKeySigningKey:               s.Spec.Secrets.SigningKeyRef,
+		SecretKeyMacaroonSecretKey:        s.Spec.Secrets.MacaroonSecretKeyRef,
+		SecretKeyFormSecret:               s.Spec.Secrets.FormSecretRef,
+		SecretKeyRegistrationSharedSecret: s.Spec.Secrets.RegistrationSharedSecretRef,
+		SecretKeyDatabasePassword:         s.getDatabasePasswordRef(),
+		SecretKeySMTPPassword:             s.Spec.Secrets.SMTPPasswordRef,
+		SecretKeyRedisPassword:            s.getRedisPasswordRef(),
+	}
+	for key, ref := range refs {
+		if ref == nil {
+			delete(refs, key)
+		}
+	}
+	return refs
+}
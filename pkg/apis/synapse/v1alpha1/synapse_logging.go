@@ -0,0 +1,93 @@
+package v1alpha1
+
+import (
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// LoggingFormatPlain writes human readable lines
+	LoggingFormatPlain = "plain"
+	// LoggingFormatJSON writes a JSON object per line
+	LoggingFormatJSON = "json"
+
+	defaultLoggingLevel = "INFO"
+	plainLoggingFormat  = "%(asctime)s - %(name)s - %(lineno)d - %(levelname)s - %(request)s - %(message)s"
+)
+
+// GenerateLoggingConfig returns homeserver logging config, raw config takes precedence over generated one
+func (s *Synapse) GenerateLoggingConfig() ([]byte, error) {
+	if s.Spec.Config.Logging != "" {
+		return []byte(s.Spec.Config.Logging), nil
+	}
+	return s.Spec.Config.LoggingSettings.generate("")
+}
+
+// GenerateLoggingConfig returns worker logging config. Worker logging settings take precedence
+// over raw config and logging settings of the Synapse
+func (w *SynapseWorker) GenerateLoggingConfig(s *Synapse) ([]byte, error) {
+	if w.Spec.Logging == nil && s.Spec.Config.Logging != "" {
+		return []byte(s.Spec.Config.Logging), nil
+	}
+	settings := w.Spec.Logging
+	if settings == nil {
+		settings = s.Spec.Config.LoggingSettings
+	}
+	return settings.generate(w.Name)
+}
+
+// generate returns Python logging dictConfig writing to stdout. Plain log lines are prefixed
+// with prefix if it's set, so that worker logs could be told apart. Nil settings produce default config
+func (l *SynapseLoggingSettings) generate(prefix string) ([]byte, error) {
+	if l == nil {
+		l = &SynapseLoggingSettings{}
+	}
+	level := l.Level
+	if level == "" {
+		level = defaultLoggingLevel
+	}
+
+	formatter := map[string]interface{}{}
+	if l.Format == LoggingFormatJSON {
+		formatter["class"] = "synapse.logging.TerseJsonFormatter"
+	} else {
+		format := plainLoggingFormat
+		if prefix != "" {
+			format = prefix + " - " + format
+		}
+		formatter["format"] = format
+	}
+
+	loggers := map[string]interface{}{}
+	for name, loggerLevel := range l.Loggers {
+		loggers[name] = map[string]interface{}{
+			"level": loggerLevel,
+		}
+	}
+
+	return yaml.Marshal(map[string]interface{}{
+		"version": 1,
+		"formatters": map[string]interface{}{
+			"default": formatter,
+		},
+		"filters": map[string]interface{}{
+			"context": map[string]interface{}{
+				"()":      "synapse.logging.context.LoggingContextFilter",
+				"request": "",
+			},
+		},
+		"handlers": map[string]interface{}{
+			"console": map[string]interface{}{
+				"class":     "logging.StreamHandler",
+				"formatter": "default",
+				"filters":   []string{"context"},
+				"stream":    "ext://sys.stdout",
+			},
+		},
+		"loggers": loggers,
+		"root": map[string]interface{}{
+			"level":    level,
+			"handlers": []string{"console"},
+		},
+		"disable_existing_loggers": false,
+	})
+}
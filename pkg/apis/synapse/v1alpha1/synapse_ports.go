@@ -0,0 +1,131 @@
+package v1alpha1
+
+import (
+	"path"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// portListener is a homeserver listener generated for a named port from SynapsePorts
+type portListener struct {
+	name     string
+	listener SynapseListener
+}
+
+// getPortListeners returns listeners for all non-zero ports in SynapsePorts
+func (s *Synapse) getPortListeners() []portListener {
+	clientAndFederation := []SynapseListenerResource{
+		{Names: []string{"client", "federation"}},
+	}
+	listeners := []portListener{}
+	if s.Spec.Ports.HTTP != 0 {
+		listeners = append(listeners, portListener{
+			name: "http",
+			listener: SynapseListener{
+				Port:       s.Spec.Ports.HTTP,
+				Type:       "http",
+				XForwarded: true,
+				Resources:  clientAndFederation,
+			},
+		})
+	}
+	if s.Spec.Ports.HTTPS != 0 {
+		listeners = append(listeners, portListener{
+			name: "https",
+			listener: SynapseListener{
+				Port:      s.Spec.Ports.HTTPS,
+				Type:      "http",
+				TLS:       true,
+				Resources: clientAndFederation,
+			},
+		})
+	}
+	if s.Spec.Ports.Replication != 0 {
+		// Workers use HTTP replication along with Redis instead of legacy TCP replication
+		replication := SynapseListener{
+			Port: s.Spec.Ports.Replication,
+			Type: "replication",
+		}
+		if s.Spec.Redis != nil {
+			replication = SynapseListener{
+				Port: s.Spec.Ports.Replication,
+				Type: "http",
+				Resources: []SynapseListenerResource{
+					{Names: []string{"replication"}},
+				},
+			}
+		}
+		listeners = append(listeners, portListener{
+			name:     "replication",
+			listener: replication,
+		})
+	}
+	if s.Spec.Ports.Metrics != 0 {
+		listeners = append(listeners, portListener{
+			name: "metrics",
+			listener: SynapseListener{
+				Port: s.Spec.Ports.Metrics,
+				Type: "metrics",
+			},
+		})
+	}
+	return listeners
+}
+
+// GetContainerPorts returns ports exposed by synapse container, one for each generated listener
+func (s *Synapse) GetContainerPorts() []corev1.ContainerPort {
+	var ports []corev1.ContainerPort
+	for _, pl := range s.getPortListeners() {
+		ports = append(ports, corev1.ContainerPort{
+			Name:          pl.name,
+			ContainerPort: int32(pl.listener.Port),
+			Protocol:      corev1.ProtocolTCP,
+		})
+	}
+	return ports
+}
+
+// applyPortListeners merges listeners generated from SynapsePorts into the config.
+// Generated listeners replace user-defined listeners on the same port
+func (s *Synapse) applyPortListeners(config map[string]interface{}) {
+	portListeners := s.getPortListeners()
+	generatedPorts := map[int]bool{}
+	listeners := []interface{}{}
+	for _, pl := range portListeners {
+		generatedPorts[pl.listener.Port] = true
+		listeners = append(listeners, pl.listener.toConfig())
+	}
+
+	if existing, ok := config["listeners"].([]interface{}); ok {
+		for _, item := range existing {
+			if listener, ok := item.(map[string]interface{}); ok && generatedPorts[listenerPort(listener)] {
+				continue
+			}
+			listeners = append(listeners, item)
+		}
+	}
+	if len(listeners) > 0 {
+		config["listeners"] = listeners
+	}
+
+	if s.Spec.Ports.HTTPS != 0 {
+		config["tls_certificate_path"] = path.Join(KeysMountPath, "tls.crt")
+		config["tls_private_key_path"] = path.Join(KeysMountPath, "tls.key")
+	}
+	if s.Spec.Ports.Metrics != 0 {
+		config["enable_metrics"] = true
+	}
+}
+
+// listenerPort returns listener port from parsed config, which may be stored as int or float
+func listenerPort(listener map[string]interface{}) int {
+	switch port := listener["port"].(type) {
+	case int:
+		return port
+	case int64:
+		return int(port)
+	case float64:
+		return int(port)
+	}
+	return 0
+}
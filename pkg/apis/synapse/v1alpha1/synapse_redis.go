@@ -0,0 +1,65 @@
+package v1alpha1
+
+import corev1 "k8s.io/api/core/v1"
+
+const (
+	defaultRedisPort = 6379
+	// DefaultRedisImage is used by Redis deployed by operator
+	DefaultRedisImage = "docker.io/library/redis:6.0-alpine"
+)
+
+func (r *SynapseRedis) getPort() int {
+	if r.Port == 0 {
+		return defaultRedisPort
+	}
+	return r.Port
+}
+
+// GetRedisName returns name of Redis deployment and service deployed by operator
+func (s *Synapse) GetRedisName() string {
+	return s.ObjectMeta.Name + "-redis"
+}
+
+// IsRedisManaged returns true if operator should deploy Redis for the instance
+func (s *Synapse) IsRedisManaged() bool {
+	return s.Spec.Redis != nil && s.Spec.Redis.Host == ""
+}
+
+// GetRedisImage returns image of Redis deployed by operator
+func (s *Synapse) GetRedisImage() string {
+	if s.Spec.Redis == nil || s.Spec.Redis.Image == "" {
+		return DefaultRedisImage
+	}
+	return s.Spec.Redis.Image
+}
+
+// getRedisPasswordRef returns reference to external Redis password
+func (s *Synapse) getRedisPasswordRef() *corev1.SecretKeySelector {
+	if s.Spec.Redis == nil {
+		return nil
+	}
+	return s.Spec.Redis.PasswordSecretRef
+}
+
+// getRedisHost returns host of external Redis or service of Redis deployed by operator
+func (s *Synapse) getRedisHost() string {
+	if s.Spec.Redis.Host == "" {
+		return s.GetRedisName()
+	}
+	return s.Spec.Redis.Host
+}
+
+// GetRedisPort returns port of external Redis or Redis deployed by operator
+func (s *Synapse) GetRedisPort() int {
+	return s.Spec.Redis.getPort()
+}
+
+// getRedisConfig returns homeserver redis section. Password is not included,
+// it's rendered from the managed secret into secrets.yaml
+func (s *Synapse) getRedisConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"enabled": true,
+		"host":    s.getRedisHost(),
+		"port":    s.GetRedisPort(),
+	}
+}
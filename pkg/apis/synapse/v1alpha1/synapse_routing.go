@@ -0,0 +1,134 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// RoutingConfigKey is a key of nginx config fragment with location blocks in routing configmap
+	RoutingConfigKey = "routing.conf"
+	// RoutingUpstreamsKey is a key of nginx config fragment with upstream blocks in routing configmap
+	RoutingUpstreamsKey = "upstreams.conf"
+	// DefaultProxyImage is nginx image used by reverse proxy if not set in the CR.
+	// Resolving upstream servers at runtime requires nginx 1.27.3 or newer
+	DefaultProxyImage = "docker.io/library/nginx:1.28-alpine"
+	// DefaultProxyResolver is DNS server used by reverse proxy if not set in the CR
+	DefaultProxyResolver = "kube-dns.kube-system.svc.cluster.local"
+	// DefaultClusterDomain is cluster DNS domain used by reverse proxy if not set in the CR
+	DefaultClusterDomain = "cluster.local"
+
+	// homeserverUpstream is an upstream name of the homeserver service
+	homeserverUpstream = "homeserver"
+)
+
+// upstream is a group of services handling the same endpoints
+type upstream struct {
+	name     string
+	app      string
+	workers  []string
+	patterns []string
+	servers  []string
+}
+
+// GenerateRoutingConfig returns nginx config fragments for routing configmap. Upstream blocks
+// group workers by app, so requests are balanced between all workers of the app. Location blocks
+// route worker endpoints to their app upstream and remaining homeserver paths to the homeserver.
+// Apps are ordered by the first worker name, so if several apps handle the same endpoint the first
+// one takes it. Scaled down workers are skipped, so that homeserver serves their endpoints.
+// Upstream servers are resolved at runtime, so nginx starts before all services are created
+func (s *Synapse) GenerateRoutingConfig(workers []SynapseWorker) map[string]string {
+	sorted := make([]SynapseWorker, 0, len(workers))
+	for _, worker := range workers {
+		if worker.Spec.Synapse == s.Name && worker.Spec.Replicas > 0 {
+			sorted = append(sorted, worker)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	upstreams := []*upstream{}
+	apps := map[string]*upstream{}
+	for _, worker := range sorted {
+		patterns := worker.GetEndpointPatterns()
+		port := worker.GetHTTPPort()
+		if len(patterns) == 0 || port == 0 {
+			continue
+		}
+		u, ok := apps[worker.Spec.Worker]
+		if !ok {
+			u = &upstream{
+				name:     strings.TrimPrefix(worker.Spec.Worker, "synapse.app."),
+				app:      worker.Spec.Worker,
+				patterns: patterns,
+			}
+			apps[worker.Spec.Worker] = u
+			upstreams = append(upstreams, u)
+		}
+		u.workers = append(u.workers, worker.Name)
+		u.servers = append(u.servers, s.getServiceAddress(worker.GetServiceName(), port))
+	}
+	upstreams = append(upstreams, &upstream{
+		name:     homeserverUpstream,
+		app:      s.Name,
+		patterns: []string{"/_matrix", "/_synapse/client"},
+		servers:  []string{s.getServiceAddress(s.GetServiceName(), s.Spec.Ports.HTTP)},
+	})
+
+	var upstreamsConfig, routingConfig strings.Builder
+	for _, u := range upstreams {
+		writeUpstream(&upstreamsConfig, u)
+		if len(u.workers) > 0 {
+			fmt.Fprintf(&routingConfig, "# %s (%s)\n", u.app, strings.Join(u.workers, ", "))
+		} else {
+			fmt.Fprintf(&routingConfig, "# %s\n", u.app)
+		}
+		for _, pattern := range u.patterns {
+			location := pattern
+			if u.name != homeserverUpstream {
+				location = "~ " + pattern
+			}
+			writeLocation(&routingConfig, location, u.name)
+		}
+	}
+	return map[string]string{
+		RoutingUpstreamsKey: upstreamsConfig.String(),
+		RoutingConfigKey:    routingConfig.String(),
+	}
+}
+
+// GetProxyResolver returns DNS server which resolves upstream services in reverse proxy
+func (s *Synapse) GetProxyResolver() string {
+	if s.Spec.Proxy == nil || s.Spec.Proxy.Resolver == "" {
+		return DefaultProxyResolver
+	}
+	return s.Spec.Proxy.Resolver
+}
+
+// getServiceAddress returns fully qualified address of the service, as nginx resolver
+// doesn't use search domains
+func (s *Synapse) getServiceAddress(service string, port int) string {
+	clusterDomain := DefaultClusterDomain
+	if s.Spec.Proxy != nil && s.Spec.Proxy.ClusterDomain != "" {
+		clusterDomain = s.Spec.Proxy.ClusterDomain
+	}
+	return fmt.Sprintf("%s.%s.svc.%s:%d", service, s.Namespace, clusterDomain, port)
+}
+
+// writeUpstream writes upstream block, which resolves its servers at runtime.
+// Shared memory zone is required to update resolved addresses
+func writeUpstream(b *strings.Builder, u *upstream) {
+	fmt.Fprintf(b, "upstream %s {\n    zone %s 64k;\n", u.name, u.name)
+	for _, server := range u.servers {
+		fmt.Fprintf(b, "    server %s resolve;\n", server)
+	}
+	fmt.Fprintf(b, "}\n")
+}
+
+// writeLocation writes location block proxying to the upstream. Upstream name is passed
+// in a variable, so nginx looks it up when handling a request
+func writeLocation(b *strings.Builder, location, upstream string) {
+	fmt.Fprintf(b, "location %s {\n    set $upstream %s;\n    proxy_pass http://$upstream;\n}\n", location, upstream)
+}
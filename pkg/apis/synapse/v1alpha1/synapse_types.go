@@ -1,15 +1,111 @@
 package v1alpha1
 
 import (
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	"github.com/vrutkovs/synapse-operator/pkg/apis/common"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // SynapseConfig contains homeserver configuration
 type SynapseConfig struct {
-	Homeserver string          `json:"homeserver"`
-	Logging    string          `json:"logging"`
-	Volumes    []SynapseVolume `json:"volumes"`
+	// Homeserver is a raw homeserver.yaml used as a base for the rendered config.
+	// Deprecated: use Settings and Overrides instead
+	Homeserver string `json:"homeserver,omitempty"`
+	// Settings are structured homeserver settings rendered into homeserver.yaml
+	Settings *SynapseSettings `json:"settings,omitempty"`
+	// Overrides are merged on top of the rendered homeserver.yaml,
+	// allowing to set options not covered by Settings
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Overrides *runtime.RawExtension `json:"overrides,omitempty"`
+	// Logging is a raw Python logging config, it's generated from LoggingSettings if not set
+	// +optional
+	Logging string `json:"logging"`
+	// LoggingSettings are used to generate logging config of homeserver and its workers
+	LoggingSettings *SynapseLoggingSettings `json:"loggingSettings,omitempty"`
+	Volumes         []SynapseVolume         `json:"volumes,omitempty"`
+}
+
+// SynapseLoggingSettings configure logs written to stdout
+type SynapseLoggingSettings struct {
+	// Level is a root logger level
+	// +kubebuilder:validation:Enum=DEBUG;INFO;WARNING;ERROR;CRITICAL
+	// +kubebuilder:default=INFO
+	Level string `json:"level,omitempty"`
+	// Loggers maps logger names, e.g. synapse.storage.SQL, to their levels
+	Loggers map[string]string `json:"loggers,omitempty"`
+	// Format is either plain text or JSON with one object per line
+	// +kubebuilder:validation:Enum=plain;json
+	// +kubebuilder:default=plain
+	Format string `json:"format,omitempty"`
+}
+
+// SynapseSettings contains structured homeserver settings
+type SynapseSettings struct {
+	PublicBaseURL string                       `json:"publicBaseURL,omitempty"`
+	Listeners     []SynapseListener            `json:"listeners,omitempty"`
+	Database      *SynapseDatabaseSettings     `json:"database,omitempty"`
+	MediaStore    *SynapseMediaStoreSettings   `json:"mediaStore,omitempty"`
+	Registration  *SynapseRegistrationSettings `json:"registration,omitempty"`
+	Federation    *SynapseFederationSettings   `json:"federation,omitempty"`
+	Caches        *SynapseCachesSettings       `json:"caches,omitempty"`
+}
+
+// SynapseListener defines a homeserver listener
+type SynapseListener struct {
+	Port int `json:"port"`
+	// +kubebuilder:validation:Enum=http;manhole;metrics;replication
+	Type          string                    `json:"type"`
+	TLS           bool                      `json:"tls,omitempty"`
+	XForwarded    bool                      `json:"xForwarded,omitempty"`
+	BindAddresses []string                  `json:"bindAddresses,omitempty"`
+	Resources     []SynapseListenerResource `json:"resources,omitempty"`
+}
+
+// SynapseListenerResource defines resources served by the listener
+type SynapseListenerResource struct {
+	Names    []string `json:"names"`
+	Compress bool     `json:"compress,omitempty"`
+}
+
+// SynapseDatabaseSettings contains database connection settings
+type SynapseDatabaseSettings struct {
+	// +kubebuilder:validation:Enum=sqlite3;psycopg2
+	Name string            `json:"name"`
+	Args map[string]string `json:"args,omitempty"`
+}
+
+// SynapseMediaStoreSettings contains media repository settings
+type SynapseMediaStoreSettings struct {
+	Path                       string   `json:"path,omitempty"`
+	MaxUploadSize              string   `json:"maxUploadSize,omitempty"`
+	URLPreviewEnabled          bool     `json:"urlPreviewEnabled,omitempty"`
+	URLPreviewIPRangeBlacklist []string `json:"urlPreviewIPRangeBlacklist,omitempty"`
+}
+
+// SynapseRegistrationSettings contains user registration settings
+type SynapseRegistrationSettings struct {
+	Enabled          bool     `json:"enabled,omitempty"`
+	AllowGuestAccess bool     `json:"allowGuestAccess,omitempty"`
+	RequireThreePID  []string `json:"requireThreePID,omitempty"`
+	AutoJoinRooms    []string `json:"autoJoinRooms,omitempty"`
+}
+
+// SynapseFederationSettings contains federation settings
+type SynapseFederationSettings struct {
+	DomainWhitelist   []string `json:"domainWhitelist,omitempty"`
+	IPRangeBlacklist  []string `json:"ipRangeBlacklist,omitempty"`
+	TrustedKeyServers []string `json:"trustedKeyServers,omitempty"`
+}
+
+// SynapseCachesSettings contains cache size settings.
+// Factors are strings to avoid floats in the API
+type SynapseCachesSettings struct {
+	GlobalFactor    string            `json:"globalFactor,omitempty"`
+	PerCacheFactors map[string]string `json:"perCacheFactors,omitempty"`
+	EventCacheSize  string            `json:"eventCacheSize,omitempty"`
 }
 
 // SynapseVolume defines a volume to be mounted in the synapse container
@@ -18,31 +114,199 @@ type SynapseVolume struct {
 	Mount  corev1.VolumeMount `json:"mount"`
 }
 
-// SynapseSecrets contains all secrets for synapse
+// SynapseSecrets contains all secrets for synapse.
+// Signing key and TLS certificate are generated by the operator if not set
 type SynapseSecrets struct {
-	Cert       string `json:"cert"`
-	Key        string `json:"key"`
-	SigningKey string `json:"signingKey"`
+	Cert       string `json:"cert,omitempty"`
+	Key        string `json:"key,omitempty"`
+	SigningKey string `json:"signingKey,omitempty"`
+
+	// References to keys of existing secrets in the same namespace.
+	// Inline value and reference may not be set for the same key
+	CertRef                     *corev1.SecretKeySelector `json:"certRef,omitempty"`
+	KeyRef                      *corev1.SecretKeySelector `json:"keyRef,omitempty"`
+	SigningKeyRef               *corev1.SecretKeySelector `json:"signingKeyRef,omitempty"`
+	MacaroonSecretKeyRef        *corev1.SecretKeySelector `json:"macaroonSecretKeyRef,omitempty"`
+	FormSecretRef               *corev1.SecretKeySelector `json:"formSecretRef,omitempty"`
+	RegistrationSharedSecretRef *corev1.SecretKeySelector `json:"registrationSharedSecretRef,omitempty"`
+	// DatabasePasswordRef is set as database password in homeserver config
+	DatabasePasswordRef *corev1.SecretKeySelector `json:"databasePasswordRef,omitempty"`
+	// SMTPPasswordRef is set as SMTP password in email section of homeserver config
+	SMTPPasswordRef *corev1.SecretKeySelector `json:"smtpPasswordRef,omitempty"`
 }
 
-// SynapsePorts contains configuration for synapse ports
+// SynapsePorts contains configuration for synapse ports.
+// Homeserver listeners are generated for each non-zero port
 type SynapsePorts struct {
-	HTTP        int `json:"http"`
-	HTTPS       int `json:"https"`
+	// +kubebuilder:default=8008
+	// +optional
+	HTTP int `json:"http"`
+	// +kubebuilder:default=8448
+	// +optional
+	HTTPS int `json:"https"`
+	// +kubebuilder:default=9093
+	// +optional
 	Replication int `json:"replication"`
+	Metrics     int `json:"metrics,omitempty"`
 }
 
 // SynapseSpec defines the desired state of Synapse
 type SynapseSpec struct {
+	// Image is Synapse image, DefaultImage is used if not set
+	// +optional
 	Image      string         `json:"image"`
 	ServerName string         `json:"serverName"`
 	Config     SynapseConfig  `json:"configuration"`
-	Secrets    SynapseSecrets `json:"secrets"`
-	Ports      SynapsePorts   `json:"ports"`
+	Secrets    SynapseSecrets `json:"secrets,omitempty"`
+	// +optional
+	Ports SynapsePorts `json:"ports"`
+	// Database configures PostgreSQL database used by homeserver.
+	// It takes precedence over database in Settings
+	Database *SynapseDatabase `json:"database,omitempty"`
+	// Storage configures persistent volume claim for the media store
+	Storage *SynapseStorage `json:"storage,omitempty"`
+	// Ingress exposes client and federation APIs via Ingress or OpenShift Route
+	Ingress *SynapseIngress `json:"ingress,omitempty"`
+	// Proxy deploys nginx in front of the homeserver, routing worker endpoints to SynapseWorkers.
+	// Ingress sends traffic to the proxy if it's enabled
+	Proxy *SynapseProxy `json:"proxy,omitempty"`
+	// WellKnown deploys a static responder serving /.well-known/matrix delegation files,
+	// so that user IDs could use serverName while homeserver is hosted elsewhere
+	WellKnown *SynapseWellKnown `json:"wellKnown,omitempty"`
+	// Redis enables Redis-based replication between homeserver and workers.
+	// Operator deploys a single-instance Redis unless host is set
+	Redis *SynapseRedis `json:"redis,omitempty"`
+	// PodTemplate overrides resources, scheduling and security settings of homeserver pods.
+	// Proxy, well-known responder and Redis pods get the same scheduling settings only
+	PodTemplate *common.PodTemplate `json:"podTemplate,omitempty"`
 }
 
+// SynapseRedis configures Redis used for worker replication
+type SynapseRedis struct {
+	// Host of external Redis, operator deploys Redis if not set
+	Host string `json:"host,omitempty"`
+	// Port of external Redis or Redis deployed by operator
+	// +kubebuilder:default=6379
+	Port int `json:"port,omitempty"`
+	// PasswordSecretRef references a key of existing secret with external Redis password
+	PasswordSecretRef *corev1.SecretKeySelector `json:"passwordSecretRef,omitempty"`
+	// Image is used by Redis deployed by operator, DefaultRedisImage is used if not set
+	Image string `json:"image,omitempty"`
+}
+
+// SynapseWellKnown configures .well-known delegation responder
+type SynapseWellKnown struct {
+	// Image is nginx image used by responder, DefaultProxyImage is used if not set
+	Image string `json:"image,omitempty"`
+	// Server is a host:port delegated federation server, generated from public base URL if not set
+	Server string `json:"server,omitempty"`
+	// Ingress exposes /.well-known/matrix on the host, serverName is used if host is not set
+	Ingress *SynapseIngress `json:"ingress,omitempty"`
+}
+
+// SynapseProxy configures reverse proxy deployment
+type SynapseProxy struct {
+	// Image is nginx image used by proxy, DefaultProxyImage is used if not set
+	Image string `json:"image,omitempty"`
+	// +kubebuilder:default=1
+	Replicas int32 `json:"replicas,omitempty"`
+	// Resolver is DNS server resolving homeserver and worker services at runtime,
+	// DefaultProxyResolver is used if not set
+	Resolver string `json:"resolver,omitempty"`
+	// ClusterDomain is cluster DNS domain of services, DefaultClusterDomain is used if not set
+	ClusterDomain string `json:"clusterDomain,omitempty"`
+}
+
+// SynapseIngress configures external access to the homeserver.
+// Paths /_matrix and /_synapse/client are routed to the homeserver service
+type SynapseIngress struct {
+	// Host is a public hostname, serverName is used if not set
+	Host string `json:"host,omitempty"`
+	// TLSSecretName is a secret with TLS certificate and key for the host.
+	// Ingress controller or router default certificate is used if not set
+	TLSSecretName string `json:"tlsSecretName,omitempty"`
+	// Annotations are added to generated Ingress or Routes
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// SynapseStorage contains settings of persistent volume claim created for the media store
+type SynapseStorage struct {
+	Size resource.Quantity `json:"size"`
+	// AllowExpansion expands the claim when requested size grows. Its storage class must allow
+	// volume expansion, otherwise reconcile fails. The claim keeps its size if not set
+	AllowExpansion bool `json:"allowExpansion,omitempty"`
+	// StorageClassName is a storage class of the claim, cluster default is used if not set
+	StorageClassName *string `json:"storageClassName,omitempty"`
+	// AccessModes of the claim, ReadWriteOnce by default. The claim is also mounted by
+	// synapse.app.media_repository workers: use ReadWriteMany if they may run on other nodes
+	AccessModes []corev1.PersistentVolumeAccessMode `json:"accessModes,omitempty"`
+	// DeletionPolicy sets whether the claim is deleted along with Synapse
+	// +kubebuilder:default=Retain
+	// +optional
+	DeletionPolicy StorageDeletionPolicy `json:"deletionPolicy"`
+}
+
+// StorageDeletionPolicy describes what happens to the media store claim when Synapse is deleted
+// +kubebuilder:validation:Enum=Retain;Delete
+type StorageDeletionPolicy string
+
+const (
+	// StorageDeletionPolicyRetain keeps the claim when Synapse is deleted, so that media is not lost
+	StorageDeletionPolicyRetain StorageDeletionPolicy = "Retain"
+	// StorageDeletionPolicyDelete makes Synapse an owner of the claim, so that it's garbage collected with Synapse
+	StorageDeletionPolicyDelete StorageDeletionPolicy = "Delete"
+)
+
+// SynapseDatabase contains PostgreSQL connection settings
+type SynapseDatabase struct {
+	Host string `json:"host"`
+	// +kubebuilder:default=5432
+	Port int    `json:"port,omitempty"`
+	Name string `json:"name"`
+	User string `json:"user"`
+	// PasswordSecretRef references a key of existing secret with database password
+	PasswordSecretRef *corev1.SecretKeySelector `json:"passwordSecretRef,omitempty"`
+	// SSLMode is passed to psycopg2 as sslmode
+	SSLMode string `json:"sslMode,omitempty"`
+	// Minimum and maximum number of connections in the pool
+	// +kubebuilder:default=5
+	CPMin int `json:"cpMin,omitempty"`
+	// +kubebuilder:default=10
+	CPMax int `json:"cpMax,omitempty"`
+	// WaitImage is used by init container which waits for database to accept connections
+	WaitImage string `json:"waitImage,omitempty"`
+}
+
+// SynapsePhase is a simple, high-level summary of where the Synapse is in its lifecycle
+type SynapsePhase string
+
+const (
+	// SynapsePhasePending means managed resources are created, but homeserver is not ready yet
+	SynapsePhasePending SynapsePhase = "Pending"
+	// SynapsePhaseRunning means homeserver deployment is ready
+	SynapsePhaseRunning SynapsePhase = "Running"
+	// SynapsePhaseFailed means last reconcile of managed resources has failed
+	SynapsePhaseFailed SynapsePhase = "Failed"
+)
+
+const (
+	// SynapseConditionReady is true when homeserver deployment has all replicas ready
+	SynapseConditionReady status.ConditionType = "Ready"
+	// SynapseConditionProgressing is true when homeserver deployment is being rolled out
+	SynapseConditionProgressing status.ConditionType = "Progressing"
+	// SynapseConditionDegraded is true when managed resources could not be reconciled
+	SynapseConditionDegraded status.ConditionType = "Degraded"
+	// SynapseConditionFieldConflict is true when fields of managed objects are left to other field managers
+	SynapseConditionFieldConflict status.ConditionType = "FieldConflict"
+)
+
 // SynapseStatus defines the observed state of Synapse
 type SynapseStatus struct {
+	Phase              SynapsePhase      `json:"phase,omitempty"`
+	ObservedGeneration int64             `json:"observedGeneration,omitempty"`
+	ReadyReplicas      int32             `json:"readyReplicas"`
+	LastError          string            `json:"lastError,omitempty"`
+	Conditions         status.Conditions `json:"conditions,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -50,6 +314,10 @@ type SynapseStatus struct {
 // Synapse is the Schema for the synapses API
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:path=synapses,scope=Namespaced
+// +kubebuilder:printcolumn:name="Server Name",type=string,JSONPath=`.spec.serverName`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Ready",type=integer,JSONPath=`.status.readyReplicas`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 type Synapse struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -2,6 +2,22 @@ package v1alpha1
 
 import corev1 "k8s.io/api/core/v1"
 
+// Keys stored in the managed secret
+const (
+	SecretKeyCert                     = "cert"
+	SecretKeyKey                      = "key"
+	SecretKeySigningKey               = "signingKey"
+	SecretKeyMacaroonSecretKey        = "macaroonSecretKey"
+	SecretKeyFormSecret               = "formSecret"
+	SecretKeyRegistrationSharedSecret = "registrationSharedSecret"
+	SecretKeyDatabasePassword         = "databasePassword"
+	SecretKeySMTPPassword             = "smtpPassword"
+	SecretKeyRedisPassword            = "redisPassword"
+	// SecretKeySecretsConfig is a config file with sensitive settings,
+	// synapse loads it from keys dir after homeserver.yaml
+	SecretKeySecretsConfig = "secrets.yaml"
+)
+
 func (cr *Synapse) getUserVolumes() []corev1.Volume {
 	volumes := []corev1.Volume{}
 	for _, volume := range cr.Spec.Config.Volumes {
@@ -27,7 +43,7 @@ func (cr *Synapse) getSecretAndConfigVolumes() []corev1.Volume {
 						},
 						{
 							Key:  "logging",
-							Path: cr.Spec.ServerName + ".log.config",
+							Path: cr.getLogConfigFileName(),
 						},
 					},
 					DefaultMode: &mode,
@@ -41,17 +57,21 @@ func (cr *Synapse) getSecretAndConfigVolumes() []corev1.Volume {
 					SecretName: cr.GetSecretName(),
 					Items: []corev1.KeyToPath{
 						{
-							Key:  "signingKey",
-							Path: cr.Spec.ServerName + ".signing.key",
+							Key:  SecretKeySigningKey,
+							Path: cr.getSigningKeyFileName(),
 						},
 						{
-							Key:  "cert",
+							Key:  SecretKeyCert,
 							Path: "tls.crt",
 						},
 						{
-							Key:  "key",
+							Key:  SecretKeyKey,
 							Path: "tls.key",
 						},
+						{
+							Key:  SecretKeySecretsConfig,
+							Path: "secrets.yaml",
+						},
 					},
 					DefaultMode: &mode,
 				},
@@ -60,20 +80,50 @@ func (cr *Synapse) getSecretAndConfigVolumes() []corev1.Volume {
 	}
 }
 
+func (cr *Synapse) getStorageVolumes() []corev1.Volume {
+	if cr.Spec.Storage == nil {
+		return []corev1.Volume{}
+	}
+	return []corev1.Volume{
+		{
+			Name: "media-store",
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: cr.GetMediaStorePVCName(),
+				},
+			},
+		},
+	}
+}
+
 // GetVolumes returns a list of volumes mounted in synapse container
 func (cr *Synapse) GetVolumes() []corev1.Volume {
-	return append(cr.getSecretAndConfigVolumes(), cr.getUserVolumes()...)
+	volumes := append(cr.getSecretAndConfigVolumes(), cr.getStorageVolumes()...)
+	return append(volumes, cr.getUserVolumes()...)
+}
+
+// mediaRepositoryApp is a worker app serving media, it's the only worker using the media store
+const mediaRepositoryApp = "synapse.app.media_repository"
+
+// GetWorkerVolumes returns a list of volumes mounted in worker container.
+// Media store is only mounted by media repository workers
+func (cr *Synapse) GetWorkerVolumes(app string) []corev1.Volume {
+	volumes := cr.getSecretAndConfigVolumes()
+	if app == mediaRepositoryApp {
+		volumes = append(volumes, cr.getStorageVolumes()...)
+	}
+	return append(volumes, cr.getUserVolumes()...)
 }
 
 func (cr *Synapse) getSecretsVolumeMounts() []corev1.VolumeMount {
 	return []corev1.VolumeMount{
 		{
 			Name:      "config",
-			MountPath: "/synapse/config",
+			MountPath: ConfigMountPath,
 		},
 		{
 			Name:      "keys",
-			MountPath: "/synapse/keys",
+			MountPath: KeysMountPath,
 		},
 	}
 }
@@ -86,7 +136,29 @@ func (cr *Synapse) getUserVolumeMounts() []corev1.VolumeMount {
 	return volumeMounts
 }
 
+func (cr *Synapse) getStorageVolumeMounts() []corev1.VolumeMount {
+	if cr.Spec.Storage == nil {
+		return []corev1.VolumeMount{}
+	}
+	return []corev1.VolumeMount{
+		{
+			Name:      "media-store",
+			MountPath: MediaStoreMountPath,
+		},
+	}
+}
+
 // GetVolumeMounts returns a list of volume mounts in synapse container
 func (cr *Synapse) GetVolumeMounts() []corev1.VolumeMount {
-	return append(cr.getSecretsVolumeMounts(), cr.getUserVolumeMounts()...)
+	volumeMounts := append(cr.getSecretsVolumeMounts(), cr.getStorageVolumeMounts()...)
+	return append(volumeMounts, cr.getUserVolumeMounts()...)
+}
+
+// GetWorkerVolumeMounts returns a list of volume mounts in worker container
+func (cr *Synapse) GetWorkerVolumeMounts(app string) []corev1.VolumeMount {
+	volumeMounts := cr.getSecretsVolumeMounts()
+	if app == mediaRepositoryApp {
+		volumeMounts = append(volumeMounts, cr.getStorageVolumeMounts()...)
+	}
+	return append(volumeMounts, cr.getUserVolumeMounts()...)
 }
@@ -0,0 +1,224 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"gopkg.in/yaml.v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// SetupWebhookWithManager registers Synapse webhooks in the manager webhook server
+func (s *Synapse) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(s).Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-synapse-vrutkovs-eu-v1alpha1-synapse,mutating=true,failurePolicy=fail,groups=synapse.vrutkovs.eu,resources=synapses,verbs=create;update,versions=v1alpha1,name=msynapse.vrutkovs.eu
+
+var _ webhook.Defaulter = &Synapse{}
+
+// Default implements webhook.Defaulter. Zero ports are invalid, so these are replaced with standard ones
+func (s *Synapse) Default() {
+	if s.Spec.Image == "" {
+		s.Spec.Image = DefaultImage
+	}
+	if s.Spec.Ports.HTTP == 0 {
+		s.Spec.Ports.HTTP = DefaultHTTPPort
+	}
+	if s.Spec.Ports.HTTPS == 0 {
+		s.Spec.Ports.HTTPS = DefaultHTTPSPort
+	}
+	if s.Spec.Ports.Replication == 0 {
+		s.Spec.Ports.Replication = DefaultReplicationPort
+	}
+	if s.Spec.Storage != nil && s.Spec.Storage.DeletionPolicy == "" {
+		s.Spec.Storage.DeletionPolicy = StorageDeletionPolicyRetain
+	}
+}
+
+// +kubebuilder:webhook:path=/validate-synapse-vrutkovs-eu-v1alpha1-synapse,mutating=false,failurePolicy=fail,groups=synapse.vrutkovs.eu,resources=synapses,verbs=create;update,versions=v1alpha1,name=vsynapse.vrutkovs.eu
+
+var _ webhook.Validator = &Synapse{}
+
+// ValidateCreate implements webhook.Validator
+func (s *Synapse) ValidateCreate() error {
+	return s.validate()
+}
+
+// ValidateUpdate implements webhook.Validator
+func (s *Synapse) ValidateUpdate(old runtime.Object) error {
+	return s.validate()
+}
+
+// ValidateDelete implements webhook.Validator
+func (s *Synapse) ValidateDelete() error {
+	return nil
+}
+
+func (s *Synapse) validate() error {
+	specPath := field.NewPath("spec")
+	allErrs := validateServerName(specPath.Child("serverName"), s.Spec.ServerName)
+	allErrs = append(allErrs, s.validatePorts(specPath.Child("ports"))...)
+	allErrs = append(allErrs, s.validateConfig(specPath.Child("configuration"))...)
+	allErrs = append(allErrs, s.validateSecrets(specPath.Child("secrets"))...)
+	allErrs = append(allErrs, s.validateRedis(specPath.Child("redis"))...)
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(SchemeGroupVersion.WithKind("Synapse").GroupKind(), s.Name, allErrs)
+}
+
+// validateServerName checks that server name is a DNS name or IP address with optional port
+func validateServerName(fldPath *field.Path, serverName string) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if serverName == "" {
+		return append(allErrs, field.Required(fldPath, ""))
+	}
+	host := serverName
+	if h, port, err := net.SplitHostPort(serverName); err == nil {
+		host = h
+		if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+			allErrs = append(allErrs, field.Invalid(fldPath, serverName, "port must be a number between 1 and 65535"))
+		}
+	}
+	if net.ParseIP(host) != nil {
+		return allErrs
+	}
+	for _, msg := range validation.IsDNS1123Subdomain(host) {
+		allErrs = append(allErrs, field.Invalid(fldPath, serverName, msg))
+	}
+	return allErrs
+}
+
+// validatePorts checks that required ports are set and all ports are distinct
+func (s *Synapse) validatePorts(fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	ports := []struct {
+		name     string
+		port     int
+		required bool
+	}{
+		{"http", s.Spec.Ports.HTTP, true},
+		{"https", s.Spec.Ports.HTTPS, true},
+		{"replication", s.Spec.Ports.Replication, true},
+		{"metrics", s.Spec.Ports.Metrics, false},
+	}
+	seen := map[int]string{}
+	for _, p := range ports {
+		if p.port == 0 {
+			if p.required {
+				allErrs = append(allErrs, field.Required(fldPath.Child(p.name), "port must be non-zero"))
+			}
+			continue
+		}
+		for _, msg := range validation.IsValidPortNum(p.port) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child(p.name), p.port, msg))
+		}
+		if other, ok := seen[p.port]; ok {
+			allErrs = append(allErrs, field.Duplicate(fldPath.Child(p.name), fmt.Sprintf("%d is already used by %s port", p.port, other)))
+			continue
+		}
+		seen[p.port] = p.name
+	}
+	return allErrs
+}
+
+// validateConfig checks that homeserver config could be rendered and logging config is valid YAML.
+// Each source of homeserver config is checked separately, so that errors point to the failed field
+func (s *Synapse) validateConfig(fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	config := s.Spec.Config
+	if _, err := config.parseHomeserver(); err != nil {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("homeserver"), config.Homeserver, err.Error()))
+	}
+	if config.Settings != nil && config.Settings.Caches != nil {
+		allErrs = append(allErrs, config.Settings.Caches.validate(fldPath.Child("settings", "caches"))...)
+	}
+	if _, err := config.parseOverrides(); err != nil {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("overrides"), string(config.Overrides.Raw), err.Error()))
+	}
+	if len(allErrs) == 0 {
+		if _, err := s.GenerateHomeserverConfig(nil); err != nil {
+			allErrs = append(allErrs, field.InternalError(fldPath, fmt.Errorf("failed to render homeserver config: %v", err)))
+		}
+	}
+	logging := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(s.Spec.Config.Logging), &logging); err != nil {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("logging"), s.Spec.Config.Logging, fmt.Sprintf("failed to parse logging config: %v", err)))
+	}
+	if s.Spec.Config.Logging != "" && s.Spec.Config.LoggingSettings != nil {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("loggingSettings"), "may not be set along with raw logging config"))
+	}
+	return allErrs
+}
+
+// validate checks that cache factors are numbers
+func (c *SynapseCachesSettings) validate(fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if c.GlobalFactor != "" {
+		if _, err := parseFactor("globalFactor", c.GlobalFactor); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("globalFactor"), c.GlobalFactor, err.Error()))
+		}
+	}
+	for name, value := range c.PerCacheFactors {
+		if _, err := parseFactor(name, value); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("perCacheFactors").Key(name), value, err.Error()))
+		}
+	}
+	return allErrs
+}
+
+// validateSecrets checks that inline values and references are not set for the same key,
+// and that TLS certificate and key are set together
+func (s *Synapse) validateSecrets(fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	secrets := s.Spec.Secrets
+	inline := []struct {
+		name  string
+		value string
+		ref   *corev1.SecretKeySelector
+	}{
+		{"cert", secrets.Cert, secrets.CertRef},
+		{"key", secrets.Key, secrets.KeyRef},
+		{"signingKey", secrets.SigningKey, secrets.SigningKeyRef},
+	}
+	for _, i := range inline {
+		if i.value != "" && i.ref != nil {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child(i.name), fmt.Sprintf("may not be set along with %sRef", i.name)))
+		}
+	}
+	hasCert := secrets.Cert != "" || secrets.CertRef != nil
+	hasKey := secrets.Key != "" || secrets.KeyRef != nil
+	if hasCert && !hasKey {
+		allErrs = append(allErrs, field.Required(fldPath.Child("key"), "TLS key must be set along with certificate"))
+	}
+	if hasKey && !hasCert {
+		allErrs = append(allErrs, field.Required(fldPath.Child("cert"), "TLS certificate must be set along with key"))
+	}
+	return allErrs
+}
+
+// validateRedis checks Redis port and that password is only set for external Redis,
+// as operator deploys Redis without auth
+func (s *Synapse) validateRedis(fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if s.Spec.Redis == nil {
+		return allErrs
+	}
+	if s.Spec.Redis.Port != 0 {
+		for _, msg := range validation.IsValidPortNum(s.Spec.Redis.Port) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("port"), s.Spec.Redis.Port, msg))
+		}
+	}
+	if s.IsRedisManaged() && s.Spec.Redis.PasswordSecretRef != nil {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("passwordSecretRef"), "may only be set along with external Redis host"))
+	}
+	return allErrs
+}
@@ -0,0 +1,87 @@
+package v1alpha1
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	// WellKnownServerKey is a key of m.server delegation file in well-known configmap
+	WellKnownServerKey = "server"
+	// WellKnownClientKey is a key of m.homeserver discovery file in well-known configmap
+	WellKnownClientKey = "client"
+	// defaultFederationPort is used when HTTPS port is not set
+	defaultFederationPort = 8448
+)
+
+// GetWellKnownName returns name of managed well-known configmap, deployment, service and ingress
+func (s *Synapse) GetWellKnownName() string {
+	return s.ObjectMeta.Name + "-well-known"
+}
+
+// GetWellKnownHost returns hostname well-known files are served on
+func (s *Synapse) GetWellKnownHost() string {
+	if s.Spec.WellKnown != nil && s.Spec.WellKnown.Ingress != nil && s.Spec.WellKnown.Ingress.Host != "" {
+		return s.Spec.WellKnown.Ingress.Host
+	}
+	return s.Spec.ServerName
+}
+
+// GetPublicBaseURL returns URL clients use to reach the homeserver
+func (s *Synapse) GetPublicBaseURL() string {
+	if s.Spec.Config.Settings != nil && s.Spec.Config.Settings.PublicBaseURL != "" {
+		return s.Spec.Config.Settings.PublicBaseURL
+	}
+	return "https://" + s.GetIngressHost()
+}
+
+// getDelegatedServer returns host:port federation traffic is delegated to.
+// Federation is served by ingress on 443 when it's enabled, otherwise HTTPS port is used
+func (s *Synapse) getDelegatedServer() (string, error) {
+	if s.Spec.WellKnown != nil && s.Spec.WellKnown.Server != "" {
+		return s.Spec.WellKnown.Server, nil
+	}
+	publicURL, err := url.Parse(s.GetPublicBaseURL())
+	if err != nil {
+		return "", fmt.Errorf("failed to parse public base URL: %w", err)
+	}
+	host := publicURL.Hostname()
+	if host == "" {
+		return "", fmt.Errorf("public base URL %q has no host", s.GetPublicBaseURL())
+	}
+	port := s.Spec.Ports.HTTPS
+	if s.Spec.Ingress != nil {
+		port = 443
+	} else if port == 0 {
+		port = defaultFederationPort
+	}
+	return fmt.Sprintf("%s:%d", host, port), nil
+}
+
+// GenerateWellKnownServer returns contents of /.well-known/matrix/server
+func (s *Synapse) GenerateWellKnownServer() (string, error) {
+	server, err := s.getDelegatedServer()
+	if err != nil {
+		return "", err
+	}
+	out, err := json.Marshal(map[string]string{"m.server": server})
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// GenerateWellKnownClient returns contents of /.well-known/matrix/client
+func (s *Synapse) GenerateWellKnownClient() (string, error) {
+	out, err := json.Marshal(map[string]interface{}{
+		"m.homeserver": map[string]string{
+			"base_url": strings.TrimSuffix(s.GetPublicBaseURL(), "/"),
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
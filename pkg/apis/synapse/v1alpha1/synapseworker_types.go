@@ -1,17 +1,56 @@
 package v1alpha1
 
 import (
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	"github.com/vrutkovs/synapse-operator/pkg/apis/common"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // SynapseWorkerSpec defines the desired state of SynapseWorker
 type SynapseWorkerSpec struct {
-	Replicas  int                     `json:"replicas"`
-	Synapse   string                  `json:"synapse"`
-	Worker    string                  `json:"worker"`
+	// +kubebuilder:default=1
+	// +optional
+	Replicas int    `json:"replicas"`
+	Synapse  string `json:"synapse"`
+	Worker   string `json:"worker"`
+	// Protocol, Port and Resources define a single worker listener, they are ignored if Listeners are set
+	// +kubebuilder:default=http
+	// +optional
 	Protocol  string                  `json:"protocol"`
+	Port      int                     `json:"port,omitempty"`
+	Resources []SynapseWorkerResource `json:"resources,omitempty"`
+	// Listeners of the worker. Each listener is exposed as a container and service port
+	Listeners []SynapseWorkerListenerSpec `json:"listeners,omitempty"`
+	// Logging overrides logging settings of referenced Synapse for this worker
+	Logging *SynapseLoggingSettings `json:"logging,omitempty"`
+	// SynapseDeletionPolicy sets whether the worker is deleted along with referenced Synapse
+	// +kubebuilder:default=Delete
+	// +optional
+	SynapseDeletionPolicy SynapseDeletionPolicy `json:"synapseDeletionPolicy"`
+	// PodTemplate overrides resources, scheduling and security settings of worker pods
+	PodTemplate *common.PodTemplate `json:"podTemplate,omitempty"`
+}
+
+// SynapseDeletionPolicy describes what happens to the worker when referenced Synapse is deleted
+// +kubebuilder:validation:Enum=Delete;Orphan
+type SynapseDeletionPolicy string
+
+const (
+	// SynapseDeletionPolicyDelete makes Synapse an owner of the worker, so that it's garbage collected with Synapse
+	SynapseDeletionPolicyDelete SynapseDeletionPolicy = "Delete"
+	// SynapseDeletionPolicyOrphan keeps the worker when Synapse is deleted, it waits for Synapse to be recreated
+	SynapseDeletionPolicyOrphan SynapseDeletionPolicy = "Orphan"
+)
+
+// SynapseWorkerListenerSpec defines a worker listener
+type SynapseWorkerListenerSpec struct {
+	// Name of container and service port, listener type is used if not set
+	Name string `json:"name,omitempty"`
+	// +kubebuilder:default=http
+	// +optional
+	Type      string                  `json:"type"`
 	Port      int                     `json:"port"`
-	Resources []SynapseWorkerResource `json:"resources"`
+	Resources []SynapseWorkerResource `json:"resources,omitempty"`
 }
 
 // SynapseWorkerResource defines synapse worker
@@ -19,8 +58,32 @@ type SynapseWorkerResource struct {
 	Names []string `json:"names"`
 }
 
+const (
+	// SynapseWorkerConditionWaitingForSynapse is true when referenced Synapse doesn't exist
+	// or worker waits for Synapse to roll out new config
+	SynapseWorkerConditionWaitingForSynapse status.ConditionType = "WaitingForSynapse"
+	// SynapseWorkerConditionParentNotFound is true when referenced Synapse doesn't exist
+	SynapseWorkerConditionParentNotFound status.ConditionType = "ParentNotFound"
+	// SynapseWorkerConditionConfigOutOfDate is true when worker pods don't run current worker or Synapse config yet
+	SynapseWorkerConditionConfigOutOfDate status.ConditionType = "ConfigOutOfDate"
+	// SynapseWorkerConditionAvailable is true when all desired worker replicas are available
+	SynapseWorkerConditionAvailable status.ConditionType = "Available"
+	// SynapseWorkerConditionFieldConflict is true when fields of managed objects are left to other field managers
+	SynapseWorkerConditionFieldConflict status.ConditionType = "FieldConflict"
+)
+
 // SynapseWorkerStatus defines the observed state of SynapseWorker
 type SynapseWorkerStatus struct {
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Synapse is a name of resolved parent Synapse
+	Synapse string `json:"synapse,omitempty"`
+	// SynapseGeneration is a generation of parent Synapse worker config was rendered from
+	SynapseGeneration int64 `json:"synapseGeneration,omitempty"`
+	Replicas          int32 `json:"replicas"`
+	ReadyReplicas     int32 `json:"readyReplicas"`
+	// Endpoint is a service address of worker HTTP listener
+	Endpoint   string            `json:"endpoint,omitempty"`
+	Conditions status.Conditions `json:"conditions,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -28,6 +91,11 @@ type SynapseWorkerStatus struct {
 // SynapseWorker is the Schema for the synapseworkers API
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:path=synapseworkers,scope=Namespaced
+// +kubebuilder:printcolumn:name="Synapse",type=string,JSONPath=`.spec.synapse`
+// +kubebuilder:printcolumn:name="Worker",type=string,JSONPath=`.spec.worker`
+// +kubebuilder:printcolumn:name="Ready",type=integer,JSONPath=`.status.readyReplicas`
+// +kubebuilder:printcolumn:name="Desired",type=integer,JSONPath=`.status.replicas`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 type SynapseWorker struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -0,0 +1,116 @@
+package v1alpha1
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// SetupWebhookWithManager registers SynapseWorker webhooks in the manager webhook server
+func (w *SynapseWorker) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(w).Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-synapse-vrutkovs-eu-v1alpha1-synapseworker,mutating=true,failurePolicy=fail,groups=synapse.vrutkovs.eu,resources=synapseworkers,verbs=create;update,versions=v1alpha1,name=msynapseworker.vrutkovs.eu
+
+var _ webhook.Defaulter = &SynapseWorker{}
+
+// Default implements webhook.Defaulter. Replicas are defaulted by CRD schema only,
+// as zero replicas can't be told apart from unset ones here
+func (w *SynapseWorker) Default() {
+	if w.Spec.Protocol == "" {
+		w.Spec.Protocol = DefaultWorkerProtocol
+	}
+	for i := range w.Spec.Listeners {
+		if w.Spec.Listeners[i].Type == "" {
+			w.Spec.Listeners[i].Type = DefaultWorkerProtocol
+		}
+	}
+	if w.Spec.SynapseDeletionPolicy == "" {
+		w.Spec.SynapseDeletionPolicy = DefaultSynapseDeletionPolicy
+	}
+}
+
+// +kubebuilder:webhook:path=/validate-synapse-vrutkovs-eu-v1alpha1-synapseworker,mutating=false,failurePolicy=fail,groups=synapse.vrutkovs.eu,resources=synapseworkers,verbs=create;update,versions=v1alpha1,name=vsynapseworker.vrutkovs.eu
+
+var _ webhook.Validator = &SynapseWorker{}
+
+// ValidateCreate implements webhook.Validator
+func (w *SynapseWorker) ValidateCreate() error {
+	return w.validate()
+}
+
+// ValidateUpdate implements webhook.Validator
+func (w *SynapseWorker) ValidateUpdate(old runtime.Object) error {
+	return w.validate()
+}
+
+// ValidateDelete implements webhook.Validator
+func (w *SynapseWorker) ValidateDelete() error {
+	return nil
+}
+
+func (w *SynapseWorker) validate() error {
+	specPath := field.NewPath("spec")
+	allErrs := field.ErrorList{}
+	if w.Spec.Synapse == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("synapse"), "name of Synapse instance is required"))
+	}
+	if !IsKnownWorkerApp(w.Spec.Worker) {
+		allErrs = append(allErrs, field.NotSupported(specPath.Child("worker"), w.Spec.Worker, knownWorkerApps()))
+	}
+	allErrs = append(allErrs, w.validateListeners(specPath)...)
+	if w.Spec.Replicas < 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("replicas"), w.Spec.Replicas, "must be non-negative"))
+	}
+	switch w.Spec.SynapseDeletionPolicy {
+	case "", SynapseDeletionPolicyDelete, SynapseDeletionPolicyOrphan:
+	default:
+		allErrs = append(allErrs, field.NotSupported(specPath.Child("synapseDeletionPolicy"), w.Spec.SynapseDeletionPolicy, []string{string(SynapseDeletionPolicyDelete), string(SynapseDeletionPolicyOrphan)}))
+	}
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(SchemeGroupVersion.WithKind("SynapseWorker").GroupKind(), w.Name, allErrs)
+}
+
+// validateListeners checks that either listeners or legacy port is set and listener ports and names are unique
+func (w *SynapseWorker) validateListeners(specPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if len(w.Spec.Listeners) == 0 {
+		if w.Spec.Port == 0 {
+			return append(allErrs, field.Required(specPath.Child("port"), "port or listeners must be set"))
+		}
+		for _, msg := range validation.IsValidPortNum(w.Spec.Port) {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("port"), w.Spec.Port, msg))
+		}
+		return allErrs
+	}
+
+	if w.Spec.Port != 0 {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("port"), "may not be set along with listeners"))
+	}
+	listenersPath := specPath.Child("listeners")
+	ports := map[int]bool{}
+	names := map[string]bool{}
+	for i, listener := range w.GetListeners() {
+		for _, msg := range validation.IsValidPortNum(listener.Port) {
+			allErrs = append(allErrs, field.Invalid(listenersPath.Index(i).Child("port"), listener.Port, msg))
+		}
+		if ports[listener.Port] {
+			allErrs = append(allErrs, field.Duplicate(listenersPath.Index(i).Child("port"), listener.Port))
+		}
+		ports[listener.Port] = true
+		for _, msg := range validation.IsValidPortName(listener.Name) {
+			allErrs = append(allErrs, field.Invalid(listenersPath.Index(i).Child("name"), listener.Name, msg))
+		}
+		if names[listener.Name] {
+			allErrs = append(allErrs, field.Duplicate(listenersPath.Index(i).Child("name"), listener.Name))
+		}
+		names[listener.Name] = true
+	}
+	return allErrs
+}
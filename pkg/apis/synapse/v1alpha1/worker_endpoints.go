@@ -0,0 +1,175 @@
+package v1alpha1
+
+import "sort"
+
+// workerEndpoints lists URL patterns handled by each worker app, as documented in Synapse workers.md.
+// Apps without HTTP endpoints are listed with no patterns
+var workerEndpoints = map[string][]string{
+	"synapse.app.appservice": {},
+	"synapse.app.client_reader": {
+		"^/_matrix/client/(api/v1|r0|unstable)/publicRooms$",
+		"^/_matrix/client/(api/v1|r0|unstable)/rooms/.*/joined_members$",
+		"^/_matrix/client/(api/v1|r0|unstable)/rooms/.*/context/.*$",
+		"^/_matrix/client/(api/v1|r0|unstable)/rooms/.*/members$",
+		"^/_matrix/client/(api/v1|r0|unstable)/rooms/.*/state$",
+		"^/_matrix/client/(api/v1|r0|unstable)/login$",
+		"^/_matrix/client/(api/v1|r0|unstable)/account/3pid$",
+		"^/_matrix/client/(api/v1|r0|unstable)/keys/query$",
+		"^/_matrix/client/(api/v1|r0|unstable)/keys/changes$",
+		"^/_matrix/client/versions$",
+		"^/_matrix/client/(api/v1|r0|unstable)/voip/turnServer$",
+		"^/_matrix/client/(api/v1|r0|unstable)/joined_groups$",
+		"^/_matrix/client/(api/v1|r0|unstable)/publicised_groups$",
+		"^/_matrix/client/(api/v1|r0|unstable)/publicised_groups/",
+		"^/_matrix/client/(api/v1|r0|unstable)/register$",
+		"^/_matrix/client/(api/v1|r0|unstable)/auth/.*/fallback/web$",
+		"^/_matrix/client/(api/v1|r0|unstable)/rooms/.*/messages$",
+	},
+	"synapse.app.event_creator": {
+		"^/_matrix/client/(api/v1|r0|unstable)/rooms/.*/send",
+		"^/_matrix/client/(api/v1|r0|unstable)/rooms/.*/state/",
+		"^/_matrix/client/(api/v1|r0|unstable)/rooms/.*/(join|invite|leave|ban|unban|kick)$",
+		"^/_matrix/client/(api/v1|r0|unstable)/join/",
+		"^/_matrix/client/(api/v1|r0|unstable)/profile/",
+	},
+	"synapse.app.federation_reader": {
+		"^/_matrix/federation/v1/event/",
+		"^/_matrix/federation/v1/state/",
+		"^/_matrix/federation/v1/state_ids/",
+		"^/_matrix/federation/v1/backfill/",
+		"^/_matrix/federation/v1/get_missing_events/",
+		"^/_matrix/federation/v1/publicRooms",
+		"^/_matrix/federation/v1/query/",
+		"^/_matrix/federation/v1/make_join/",
+		"^/_matrix/federation/v1/make_leave/",
+		"^/_matrix/federation/v1/send_join/",
+		"^/_matrix/federation/v2/send_join/",
+		"^/_matrix/federation/v1/send_leave/",
+		"^/_matrix/federation/v2/send_leave/",
+		"^/_matrix/federation/v1/invite/",
+		"^/_matrix/federation/v2/invite/",
+		"^/_matrix/federation/v1/query_auth/",
+		"^/_matrix/federation/v1/event_auth/",
+		"^/_matrix/federation/v1/exchange_third_party_invite/",
+		"^/_matrix/federation/v1/user/devices/",
+		"^/_matrix/federation/v1/send/",
+		"^/_matrix/federation/v1/get_groups_publicised$",
+		"^/_matrix/key/v2/query",
+	},
+	"synapse.app.federation_sender": {},
+	"synapse.app.frontend_proxy": {
+		"^/_matrix/client/(api/v1|r0|unstable)/keys/upload",
+	},
+	"synapse.app.generic_worker": {
+		// Sync requests
+		"^/_matrix/client/(v2_alpha|r0)/sync$",
+		"^/_matrix/client/(api/v1|v2_alpha|r0)/events$",
+		"^/_matrix/client/(api/v1|r0)/initialSync$",
+		"^/_matrix/client/(api/v1|r0)/rooms/[^/]+/initialSync$",
+		// Federation requests
+		"^/_matrix/federation/v1/event/",
+		"^/_matrix/federation/v1/state/",
+		"^/_matrix/federation/v1/state_ids/",
+		"^/_matrix/federation/v1/backfill/",
+		"^/_matrix/federation/v1/get_missing_events/",
+		"^/_matrix/federation/v1/publicRooms",
+		"^/_matrix/federation/v1/query/",
+		"^/_matrix/federation/v1/make_join/",
+		"^/_matrix/federation/v1/make_leave/",
+		"^/_matrix/federation/v1/send_join/",
+		"^/_matrix/federation/v2/send_join/",
+		"^/_matrix/federation/v1/send_leave/",
+		"^/_matrix/federation/v2/send_leave/",
+		"^/_matrix/federation/v1/invite/",
+		"^/_matrix/federation/v2/invite/",
+		"^/_matrix/federation/v1/query_auth/",
+		"^/_matrix/federation/v1/event_auth/",
+		"^/_matrix/federation/v1/exchange_third_party_invite/",
+		"^/_matrix/federation/v1/user/devices/",
+		"^/_matrix/federation/v1/get_groups_publicised$",
+		"^/_matrix/key/v2/query",
+		"^/_matrix/federation/v1/send/",
+		// Client API requests
+		"^/_matrix/client/(api/v1|r0|unstable)/publicRooms$",
+		"^/_matrix/client/(api/v1|r0|unstable)/rooms/.*/joined_members$",
+		"^/_matrix/client/(api/v1|r0|unstable)/rooms/.*/context/.*$",
+		"^/_matrix/client/(api/v1|r0|unstable)/rooms/.*/members$",
+		"^/_matrix/client/(api/v1|r0|unstable)/rooms/.*/state$",
+		"^/_matrix/client/(api/v1|r0|unstable)/account/3pid$",
+		"^/_matrix/client/(api/v1|r0|unstable)/keys/query$",
+		"^/_matrix/client/(api/v1|r0|unstable)/keys/changes$",
+		"^/_matrix/client/versions$",
+		"^/_matrix/client/(api/v1|r0|unstable)/voip/turnServer$",
+		"^/_matrix/client/(api/v1|r0|unstable)/joined_groups$",
+		"^/_matrix/client/(api/v1|r0|unstable)/publicised_groups$",
+		"^/_matrix/client/(api/v1|r0|unstable)/publicised_groups/",
+		"^/_matrix/client/(api/v1|r0|unstable)/rooms/.*/event/",
+		"^/_matrix/client/(api/v1|r0|unstable)/joined_rooms$",
+		"^/_matrix/client/(api/v1|r0|unstable)/search$",
+		// Registration/login requests
+		"^/_matrix/client/(api/v1|r0|unstable)/login$",
+		"^/_matrix/client/(r0|unstable)/register$",
+		"^/_matrix/client/(r0|unstable)/auth/.*/fallback/web$",
+		// Event sending requests
+		"^/_matrix/client/(api/v1|r0|unstable)/rooms/.*/redact",
+		"^/_matrix/client/(api/v1|r0|unstable)/rooms/.*/send",
+		"^/_matrix/client/(api/v1|r0|unstable)/rooms/.*/state/",
+		"^/_matrix/client/(api/v1|r0|unstable)/rooms/.*/(join|invite|leave|ban|unban|kick)$",
+		"^/_matrix/client/(api/v1|r0|unstable)/join/",
+		"^/_matrix/client/(api/v1|r0|unstable)/profile/",
+	},
+	"synapse.app.media_repository": {
+		"^/_matrix/media/",
+		"^/_synapse/admin/v1/purge_media_cache$",
+		"^/_synapse/admin/v1/room/.*/media.*$",
+		"^/_synapse/admin/v1/user/.*/media.*$",
+		"^/_synapse/admin/v1/media/.*$",
+		"^/_synapse/admin/v1/quarantine_media/.*$",
+	},
+	"synapse.app.pusher": {},
+	"synapse.app.synchrotron": {
+		"^/_matrix/client/(v2_alpha|r0)/sync$",
+		"^/_matrix/client/(api/v1|v2_alpha|r0)/events$",
+		"^/_matrix/client/(api/v1|r0)/initialSync$",
+		"^/_matrix/client/(api/v1|r0)/rooms/[^/]+/initialSync$",
+	},
+	"synapse.app.user_dir": {
+		"^/_matrix/client/(api/v1|r0|unstable)/user_directory/search$",
+	},
+}
+
+// workerMainProcessSettings lists homeserver settings which stop main process from doing
+// the work handed over to the worker app
+var workerMainProcessSettings = map[string]map[string]interface{}{
+	"synapse.app.appservice":        {"notify_appservices": false},
+	"synapse.app.federation_sender": {"send_federation": false},
+	"synapse.app.media_repository":  {"enable_media_repo": false},
+	"synapse.app.pusher":            {"start_pushers": false},
+	"synapse.app.user_dir":          {"update_user_directory": false},
+}
+
+// IsKnownWorkerApp returns true if the app is a known Synapse worker app
+func IsKnownWorkerApp(app string) bool {
+	_, ok := workerEndpoints[app]
+	return ok
+}
+
+// knownWorkerApps returns sorted names of known Synapse worker apps
+func knownWorkerApps() []string {
+	apps := make([]string, 0, len(workerEndpoints))
+	for app := range workerEndpoints {
+		apps = append(apps, app)
+	}
+	sort.Strings(apps)
+	return apps
+}
+
+// GetEndpointPatterns returns URL patterns which should be routed to the worker
+func (w *SynapseWorker) GetEndpointPatterns() []string {
+	return workerEndpoints[w.Spec.Worker]
+}
+
+// GetMainProcessSettings returns homeserver settings required to run the worker
+func (w *SynapseWorker) GetMainProcessSettings() map[string]interface{} {
+	return workerMainProcessSettings[w.Spec.Worker]
+}
@@ -2,12 +2,23 @@ package v1alpha1
 
 import (
 	"context"
+	"fmt"
+	"path"
 
 	"gopkg.in/yaml.v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+const (
+	// WorkerConfigMountPath is a path where worker configmap is mounted in worker container
+	WorkerConfigMountPath = "/synapse/worker"
+	// WorkerConfigFileName is a name of worker config in worker configmap
+	WorkerConfigFileName = "worker.yaml"
+	// WorkerLogConfigFileName is a name of worker logging config in worker configmap
+	WorkerLogConfigFileName = "worker.log.config"
+)
+
 // GetConfigMapName returns SynapseWorker configmap name
 func (w *SynapseWorker) GetConfigMapName() string {
 	return w.ObjectMeta.Name + "-config"
@@ -30,10 +41,12 @@ func (w *SynapseWorker) GetServiceName() string {
 
 // SynapseWorkerConfig represents a worker config
 type SynapseWorkerConfig struct {
-	App             string                  `yaml:"worker_app"`
-	ReplicationHost string                  `yaml:"worker_replication_host"`
-	ReplicationPort int                     `yaml:"worker_replication_port"`
-	Listeners       []SynapseWorkerListener `yaml:"worker_listeners"`
+	App                 string                  `yaml:"worker_app"`
+	ReplicationHost     string                  `yaml:"worker_replication_host"`
+	ReplicationPort     int                     `yaml:"worker_replication_port,omitempty"`
+	ReplicationHTTPPort int                     `yaml:"worker_replication_http_port,omitempty"`
+	Listeners           []SynapseWorkerListener `yaml:"worker_listeners"`
+	LogConfig           string                  `yaml:"worker_log_config"`
 }
 
 // SynapseWorkerListener represents listener config
@@ -43,17 +56,78 @@ type SynapseWorkerListener struct {
 	Resources []SynapseWorkerResource `yaml:"resources"`
 }
 
-// GenerateConfig returns string config of the worker based on SynapseWorker config
+// GetListeners returns worker listeners with type and port names set. Legacy protocol, port and resources
+// are used if listeners are not set
+func (w *SynapseWorker) GetListeners() []SynapseWorkerListenerSpec {
+	if len(w.Spec.Listeners) == 0 {
+		if w.Spec.Port == 0 {
+			return nil
+		}
+		return []SynapseWorkerListenerSpec{{
+			Name:      "http",
+			Type:      getListenerType(w.Spec.Protocol),
+			Port:      w.Spec.Port,
+			Resources: w.Spec.Resources,
+		}}
+	}
+
+	typeCount := map[string]int{}
+	for _, listener := range w.Spec.Listeners {
+		typeCount[getListenerType(listener.Type)]++
+	}
+	listeners := make([]SynapseWorkerListenerSpec, len(w.Spec.Listeners))
+	for i, listener := range w.Spec.Listeners {
+		listeners[i] = listener
+		listeners[i].Type = getListenerType(listener.Type)
+		if listener.Name != "" {
+			continue
+		}
+		// Type alone is ambiguous if several listeners have the same type
+		listeners[i].Name = listeners[i].Type
+		if typeCount[listeners[i].Type] > 1 {
+			listeners[i].Name = fmt.Sprintf("%s-%d", listeners[i].Type, i)
+		}
+	}
+	return listeners
+}
+
+func getListenerType(listenerType string) string {
+	if listenerType == "" {
+		return DefaultWorkerProtocol
+	}
+	return listenerType
+}
+
+// GetHTTPPort returns port of the first HTTP listener, which serves worker endpoints
+func (w *SynapseWorker) GetHTTPPort() int {
+	for _, listener := range w.GetListeners() {
+		if listener.Type == "http" {
+			return listener.Port
+		}
+	}
+	return 0
+}
+
+// GenerateConfig returns string config of the worker based on SynapseWorker config.
+// With Redis enabled workers use HTTP replication, Redis settings are loaded from homeserver config and secrets
 func (w *SynapseWorker) GenerateConfig(s *Synapse) ([]byte, error) {
 	workerConfig := SynapseWorkerConfig{
 		App:             w.Spec.Worker,
 		ReplicationHost: s.GetServiceName(),
-		ReplicationPort: s.Spec.Ports.Replication,
-		Listeners: []SynapseWorkerListener{{
-			Protocol:  w.Spec.Protocol,
-			Port:      w.Spec.Port,
-			Resources: w.Spec.Resources,
-		}},
+		Listeners:       []SynapseWorkerListener{},
+		LogConfig:       path.Join(WorkerConfigMountPath, WorkerLogConfigFileName),
+	}
+	for _, listener := range w.GetListeners() {
+		workerConfig.Listeners = append(workerConfig.Listeners, SynapseWorkerListener{
+			Protocol:  listener.Type,
+			Port:      listener.Port,
+			Resources: listener.Resources,
+		})
+	}
+	if s.Spec.Redis != nil {
+		workerConfig.ReplicationHTTPPort = s.Spec.Ports.Replication
+	} else {
+		workerConfig.ReplicationPort = s.Spec.Ports.Replication
 	}
 
 	return yaml.Marshal(workerConfig)
@@ -5,7 +5,10 @@
 package v1alpha1
 
 import (
-	runtime "k8s.io/apimachinery/pkg/runtime"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	"github.com/vrutkovs/synapse-operator/pkg/apis/common"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -14,7 +17,7 @@ func (in *Synapse) DeepCopyInto(out *Synapse) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -36,9 +39,47 @@ func (in *Synapse) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseCachesSettings) DeepCopyInto(out *SynapseCachesSettings) {
+	*out = *in
+	if in.PerCacheFactors != nil {
+		in, out := &in.PerCacheFactors, &out.PerCacheFactors
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseCachesSettings.
+func (in *SynapseCachesSettings) DeepCopy() *SynapseCachesSettings {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseCachesSettings)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SynapseConfig) DeepCopyInto(out *SynapseConfig) {
 	*out = *in
+	if in.Settings != nil {
+		in, out := &in.Settings, &out.Settings
+		*out = new(SynapseSettings)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Overrides != nil {
+		in, out := &in.Overrides, &out.Overrides
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LoggingSettings != nil {
+		in, out := &in.LoggingSettings, &out.LoggingSettings
+		*out = new(SynapseLoggingSettings)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Volumes != nil {
 		in, out := &in.Volumes, &out.Volumes
 		*out = make([]SynapseVolume, len(*in))
@@ -59,6 +100,104 @@ func (in *SynapseConfig) DeepCopy() *SynapseConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseDatabase) DeepCopyInto(out *SynapseDatabase) {
+	*out = *in
+	if in.PasswordSecretRef != nil {
+		in, out := &in.PasswordSecretRef, &out.PasswordSecretRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseDatabase.
+func (in *SynapseDatabase) DeepCopy() *SynapseDatabase {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseDatabase)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseDatabaseSettings) DeepCopyInto(out *SynapseDatabaseSettings) {
+	*out = *in
+	if in.Args != nil {
+		in, out := &in.Args, &out.Args
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseDatabaseSettings.
+func (in *SynapseDatabaseSettings) DeepCopy() *SynapseDatabaseSettings {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseDatabaseSettings)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseFederationSettings) DeepCopyInto(out *SynapseFederationSettings) {
+	*out = *in
+	if in.DomainWhitelist != nil {
+		in, out := &in.DomainWhitelist, &out.DomainWhitelist
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.IPRangeBlacklist != nil {
+		in, out := &in.IPRangeBlacklist, &out.IPRangeBlacklist
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.TrustedKeyServers != nil {
+		in, out := &in.TrustedKeyServers, &out.TrustedKeyServers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseFederationSettings.
+func (in *SynapseFederationSettings) DeepCopy() *SynapseFederationSettings {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseFederationSettings)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseIngress) DeepCopyInto(out *SynapseIngress) {
+	*out = *in
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseIngress.
+func (in *SynapseIngress) DeepCopy() *SynapseIngress {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseIngress)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SynapseList) DeepCopyInto(out *SynapseList) {
 	*out = *in
@@ -92,6 +231,99 @@ func (in *SynapseList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseListener) DeepCopyInto(out *SynapseListener) {
+	*out = *in
+	if in.BindAddresses != nil {
+		in, out := &in.BindAddresses, &out.BindAddresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]SynapseListenerResource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseListener.
+func (in *SynapseListener) DeepCopy() *SynapseListener {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseListener)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseListenerResource) DeepCopyInto(out *SynapseListenerResource) {
+	*out = *in
+	if in.Names != nil {
+		in, out := &in.Names, &out.Names
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseListenerResource.
+func (in *SynapseListenerResource) DeepCopy() *SynapseListenerResource {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseListenerResource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseLoggingSettings) DeepCopyInto(out *SynapseLoggingSettings) {
+	*out = *in
+	if in.Loggers != nil {
+		in, out := &in.Loggers, &out.Loggers
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseLoggingSettings.
+func (in *SynapseLoggingSettings) DeepCopy() *SynapseLoggingSettings {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseLoggingSettings)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseMediaStoreSettings) DeepCopyInto(out *SynapseMediaStoreSettings) {
+	*out = *in
+	if in.URLPreviewIPRangeBlacklist != nil {
+		in, out := &in.URLPreviewIPRangeBlacklist, &out.URLPreviewIPRangeBlacklist
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseMediaStoreSettings.
+func (in *SynapseMediaStoreSettings) DeepCopy() *SynapseMediaStoreSettings {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseMediaStoreSettings)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SynapsePorts) DeepCopyInto(out *SynapsePorts) {
 	*out = *in
@@ -108,9 +340,112 @@ func (in *SynapsePorts) DeepCopy() *SynapsePorts {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseProxy) DeepCopyInto(out *SynapseProxy) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseProxy.
+func (in *SynapseProxy) DeepCopy() *SynapseProxy {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseProxy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseRedis) DeepCopyInto(out *SynapseRedis) {
+	*out = *in
+	if in.PasswordSecretRef != nil {
+		in, out := &in.PasswordSecretRef, &out.PasswordSecretRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseRedis.
+func (in *SynapseRedis) DeepCopy() *SynapseRedis {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseRedis)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseRegistrationSettings) DeepCopyInto(out *SynapseRegistrationSettings) {
+	*out = *in
+	if in.RequireThreePID != nil {
+		in, out := &in.RequireThreePID, &out.RequireThreePID
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AutoJoinRooms != nil {
+		in, out := &in.AutoJoinRooms, &out.AutoJoinRooms
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseRegistrationSettings.
+func (in *SynapseRegistrationSettings) DeepCopy() *SynapseRegistrationSettings {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseRegistrationSettings)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SynapseSecrets) DeepCopyInto(out *SynapseSecrets) {
 	*out = *in
+	if in.CertRef != nil {
+		in, out := &in.CertRef, &out.CertRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.KeyRef != nil {
+		in, out := &in.KeyRef, &out.KeyRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SigningKeyRef != nil {
+		in, out := &in.SigningKeyRef, &out.SigningKeyRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MacaroonSecretKeyRef != nil {
+		in, out := &in.MacaroonSecretKeyRef, &out.MacaroonSecretKeyRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.FormSecretRef != nil {
+		in, out := &in.FormSecretRef, &out.FormSecretRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RegistrationSharedSecretRef != nil {
+		in, out := &in.RegistrationSharedSecretRef, &out.RegistrationSharedSecretRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DatabasePasswordRef != nil {
+		in, out := &in.DatabasePasswordRef, &out.DatabasePasswordRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SMTPPasswordRef != nil {
+		in, out := &in.SMTPPasswordRef, &out.SMTPPasswordRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -124,12 +459,95 @@ func (in *SynapseSecrets) DeepCopy() *SynapseSecrets {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseSettings) DeepCopyInto(out *SynapseSettings) {
+	*out = *in
+	if in.Listeners != nil {
+		in, out := &in.Listeners, &out.Listeners
+		*out = make([]SynapseListener, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Database != nil {
+		in, out := &in.Database, &out.Database
+		*out = new(SynapseDatabaseSettings)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MediaStore != nil {
+		in, out := &in.MediaStore, &out.MediaStore
+		*out = new(SynapseMediaStoreSettings)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Registration != nil {
+		in, out := &in.Registration, &out.Registration
+		*out = new(SynapseRegistrationSettings)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Federation != nil {
+		in, out := &in.Federation, &out.Federation
+		*out = new(SynapseFederationSettings)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Caches != nil {
+		in, out := &in.Caches, &out.Caches
+		*out = new(SynapseCachesSettings)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseSettings.
+func (in *SynapseSettings) DeepCopy() *SynapseSettings {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseSettings)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SynapseSpec) DeepCopyInto(out *SynapseSpec) {
 	*out = *in
 	in.Config.DeepCopyInto(&out.Config)
-	out.Secrets = in.Secrets
+	in.Secrets.DeepCopyInto(&out.Secrets)
 	out.Ports = in.Ports
+	if in.Database != nil {
+		in, out := &in.Database, &out.Database
+		*out = new(SynapseDatabase)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Storage != nil {
+		in, out := &in.Storage, &out.Storage
+		*out = new(SynapseStorage)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Ingress != nil {
+		in, out := &in.Ingress, &out.Ingress
+		*out = new(SynapseIngress)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Proxy != nil {
+		in, out := &in.Proxy, &out.Proxy
+		*out = new(SynapseProxy)
+		**out = **in
+	}
+	if in.WellKnown != nil {
+		in, out := &in.WellKnown, &out.WellKnown
+		*out = new(SynapseWellKnown)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Redis != nil {
+		in, out := &in.Redis, &out.Redis
+		*out = new(SynapseRedis)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PodTemplate != nil {
+		in, out := &in.PodTemplate, &out.PodTemplate
+		*out = new(common.PodTemplate)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -146,6 +564,13 @@ func (in *SynapseSpec) DeepCopy() *SynapseSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SynapseStatus) DeepCopyInto(out *SynapseStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(status.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -159,6 +584,33 @@ func (in *SynapseStatus) DeepCopy() *SynapseStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseStorage) DeepCopyInto(out *SynapseStorage) {
+	*out = *in
+	out.Size = in.Size.DeepCopy()
+	if in.StorageClassName != nil {
+		in, out := &in.StorageClassName, &out.StorageClassName
+		*out = new(string)
+		**out = **in
+	}
+	if in.AccessModes != nil {
+		in, out := &in.AccessModes, &out.AccessModes
+		*out = make([]v1.PersistentVolumeAccessMode, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseStorage.
+func (in *SynapseStorage) DeepCopy() *SynapseStorage {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseStorage)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SynapseVolume) DeepCopyInto(out *SynapseVolume) {
 	*out = *in
@@ -177,13 +629,34 @@ func (in *SynapseVolume) DeepCopy() *SynapseVolume {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseWellKnown) DeepCopyInto(out *SynapseWellKnown) {
+	*out = *in
+	if in.Ingress != nil {
+		in, out := &in.Ingress, &out.Ingress
+		*out = new(SynapseIngress)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseWellKnown.
+func (in *SynapseWellKnown) DeepCopy() *SynapseWellKnown {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseWellKnown)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SynapseWorker) DeepCopyInto(out *SynapseWorker) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -284,6 +757,29 @@ func (in *SynapseWorkerListener) DeepCopy() *SynapseWorkerListener {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseWorkerListenerSpec) DeepCopyInto(out *SynapseWorkerListenerSpec) {
+	*out = *in
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]SynapseWorkerResource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseWorkerListenerSpec.
+func (in *SynapseWorkerListenerSpec) DeepCopy() *SynapseWorkerListenerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseWorkerListenerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SynapseWorkerResource) DeepCopyInto(out *SynapseWorkerResource) {
 	*out = *in
@@ -315,6 +811,23 @@ func (in *SynapseWorkerSpec) DeepCopyInto(out *SynapseWorkerSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Listeners != nil {
+		in, out := &in.Listeners, &out.Listeners
+		*out = make([]SynapseWorkerListenerSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Logging != nil {
+		in, out := &in.Logging, &out.Logging
+		*out = new(SynapseLoggingSettings)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PodTemplate != nil {
+		in, out := &in.PodTemplate, &out.PodTemplate
+		*out = new(common.PodTemplate)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -331,6 +844,13 @@ func (in *SynapseWorkerSpec) DeepCopy() *SynapseWorkerSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SynapseWorkerStatus) DeepCopyInto(out *SynapseWorkerStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(status.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -0,0 +1,200 @@
+package apply
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// FieldManager owns all fields set by the operator. It matches the binary name, so fields written
+// by updates of previous operator versions are recognised as operator-owned
+const FieldManager = "synapse-operator"
+
+// conflictManagerRe extracts field manager name from conflict cause message
+var conflictManagerRe = regexp.MustCompile(`conflict with "([^"]*)"`)
+
+// Conflict is a field of managed object owned by another field manager
+type Conflict struct {
+	Kind    string
+	Name    string
+	Field   string
+	Manager string
+}
+
+func (c Conflict) String() string {
+	return fmt.Sprintf("%s %s: %s is managed by %q", c.Kind, c.Name, c.Field, c.Manager)
+}
+
+// ConflictCondition returns status condition of conditionType, which is true if there are conflicts
+func ConflictCondition(conditionType status.ConditionType, conflicts []Conflict) status.Condition {
+	if len(conflicts) == 0 {
+		return status.Condition{
+			Type:   conditionType,
+			Status: corev1.ConditionFalse,
+			Reason: "NoConflicts",
+		}
+	}
+	messages := make([]string, len(conflicts))
+	for i, conflict := range conflicts {
+		messages[i] = conflict.String()
+	}
+	return status.Condition{
+		Type:    conditionType,
+		Status:  corev1.ConditionTrue,
+		Reason:  "FieldManagerConflict",
+		Message: strings.Join(messages, "; "),
+	}
+}
+
+// Applier applies managed objects with operator field manager and records conflicts found for each owner
+type Applier struct {
+	client client.Client
+	scheme *runtime.Scheme
+
+	mu        sync.Mutex
+	conflicts map[types.NamespacedName][]Conflict
+}
+
+// NewApplier returns Applier using the client
+func NewApplier(c client.Client, scheme *runtime.Scheme) *Applier {
+	return &Applier{
+		client:    c,
+		scheme:    scheme,
+		conflicts: map[types.NamespacedName][]Conflict{},
+	}
+}
+
+// Reset forgets conflicts recorded for the owner, it's called before owned objects are applied
+func (a *Applier) Reset(owner metav1.Object) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.conflicts, getKey(owner))
+}
+
+// Conflicts returns conflicts recorded for the owner since last reset
+func (a *Applier) Conflicts(owner metav1.Object) []Conflict {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]Conflict(nil), a.conflicts[getKey(owner)]...)
+}
+
+func getKey(owner metav1.Object) types.NamespacedName {
+	return types.NamespacedName{Name: owner.GetName(), Namespace: owner.GetNamespace()}
+}
+
+// Apply creates or updates obj via server-side apply. Every field set in obj is enforced, unless
+// another field manager has set it to a different value: such fields are left to that manager
+// and recorded as conflicts of the owner
+func (a *Applier) Apply(owner metav1.Object, obj runtime.Object) error {
+	u, err := a.toUnstructured(obj)
+	if err != nil {
+		return err
+	}
+	err = a.client.Patch(context.TODO(), u, client.Apply, client.FieldOwner(FieldManager))
+	if !apierrors.IsConflict(err) {
+		return err
+	}
+
+	// Drop fields owned by others and take over the rest, which were written by previous operator versions
+	conflicts := []Conflict{}
+	for _, cause := range getConflictCauses(err) {
+		manager := ""
+		if match := conflictManagerRe.FindStringSubmatch(cause.Message); match != nil {
+			manager = match[1]
+		}
+		if manager == FieldManager {
+			continue
+		}
+		// Forced apply would take over the field if it's not found in the applied object
+		removed, err := RemoveField(u.Object, cause.Field)
+		if err != nil {
+			return fmt.Errorf("failed to leave %s to %q: %w", cause.Field, manager, err)
+		}
+		if !removed {
+			return fmt.Errorf("failed to leave %s to %q: field not found in %s %s", cause.Field, manager, u.GetKind(), u.GetName())
+		}
+		conflicts = append(conflicts, Conflict{
+			Kind:    u.GetKind(),
+			Name:    u.GetName(),
+			Field:   cause.Field,
+			Manager: manager,
+		})
+	}
+	a.mu.Lock()
+	a.conflicts[getKey(owner)] = append(a.conflicts[getKey(owner)], conflicts...)
+	a.mu.Unlock()
+	return a.client.Patch(context.TODO(), u, client.Apply, client.FieldOwner(FieldManager), client.ForceOwnership)
+}
+
+// Delete removes obj if it's controlled by the owner, e.g. when the component is disabled. Objects are looked up
+// by obj name and namespace, objects not found or not controlled by the owner are left intact.
+// Returns true if the object was deleted
+func (a *Applier) Delete(owner metav1.Object, obj runtime.Object) (bool, error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return false, err
+	}
+	key := types.NamespacedName{Name: accessor.GetName(), Namespace: accessor.GetNamespace()}
+	if err := a.client.Get(context.TODO(), key, obj); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+	if !metav1.IsControlledBy(accessor, owner) {
+		return false, nil
+	}
+	if err := a.client.Delete(context.TODO(), obj); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+	return true, nil
+}
+
+// toUnstructured converts obj to unstructured apply configuration with kind and API version set
+func (a *Applier) toUnstructured(obj runtime.Object) (*unstructured.Unstructured, error) {
+	gvk, err := apiutil.GVKForObject(obj, a.scheme)
+	if err != nil {
+		return nil, err
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	// Content of unstructured objects is not copied by the converter, e.g. for Routes
+	if _, ok := obj.(runtime.Unstructured); ok {
+		content = runtime.DeepCopyJSON(content)
+	}
+	u := &unstructured.Unstructured{Object: content}
+	u.SetGroupVersionKind(gvk)
+	// Server fills these in, they must not be part of apply configuration
+	unstructured.RemoveNestedField(u.Object, "metadata", "creationTimestamp")
+	unstructured.RemoveNestedField(u.Object, "spec", "template", "metadata", "creationTimestamp")
+	unstructured.RemoveNestedField(u.Object, "metadata", "resourceVersion")
+	unstructured.RemoveNestedField(u.Object, "status")
+	return u, nil
+}
+
+func getConflictCauses(err error) []metav1.StatusCause {
+	statusErr, ok := err.(apierrors.APIStatus)
+	if !ok || statusErr.Status().Details == nil {
+		return nil
+	}
+	causes := []metav1.StatusCause{}
+	for _, cause := range statusErr.Status().Details.Causes {
+		if cause.Type == metav1.CauseTypeFieldManagerConflict {
+			causes = append(causes, cause)
+		}
+	}
+	return causes
+}
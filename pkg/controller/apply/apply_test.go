@@ -0,0 +1,206 @@
+package apply
+
+import (
+	"context"
+	"os"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	g "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+)
+
+// newDeployment returns a deployment as the operator would apply it
+func newDeployment(name, ns string, labels map[string]string) *appsv1.Deployment {
+	replicas := int32(1)
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ns,
+			Labels:    labels,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"app": name},
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"app": name},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{Name: "synapse", Image: "synapse:v1"},
+					},
+				},
+			},
+		},
+	}
+}
+
+// conflictClient fails non-forced applies with a conflict on the field and records forced applies
+type conflictClient struct {
+	client.Client
+	field  string
+	forced bool
+}
+
+func (c *conflictClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	options := (&client.PatchOptions{}).ApplyOptions(opts)
+	if options.Force != nil && *options.Force {
+		c.forced = true
+		return nil
+	}
+	return apierrors.NewApplyConflict([]metav1.StatusCause{
+		{
+			Type:    metav1.CauseTypeFieldManagerConflict,
+			Message: `conflict with "kubectl" using apps/v1`,
+			Field:   c.field,
+		},
+	}, "Apply failed with 1 conflict")
+}
+
+var _ = ginkgo.Describe("[apply] Applier conflicts", func() {
+	name := "synapse"
+	ns := "default"
+
+	table.DescribeTable("should force apply only when conflicting fields are left to others",
+		func(field string, expectedForced bool) {
+			cl := &conflictClient{field: field}
+			owner := &metav1.ObjectMeta{Name: name, Namespace: ns}
+			applier := NewApplier(cl, scheme.Scheme)
+			err := applier.Apply(owner, newDeployment(name, ns, map[string]string{"app": name}))
+			g.Expect(cl.forced).To(g.Equal(expectedForced))
+			if !expectedForced {
+				g.Expect(err).To(g.HaveOccurred())
+				g.Expect(err.Error()).To(g.ContainSubstring(field))
+				g.Expect(applier.Conflicts(owner)).To(g.BeEmpty())
+				return
+			}
+			g.Expect(err).NotTo(g.HaveOccurred())
+			g.Expect(applier.Conflicts(owner)).To(g.Equal([]Conflict{
+				{Kind: "Deployment", Name: name, Field: field, Manager: "kubectl"},
+			}))
+		},
+		table.Entry("applied field", ".spec.replicas", true),
+		table.Entry("applied field of list item", `.spec.template.spec.containers[name="synapse"].image`, true),
+		table.Entry("field missing in applied object", ".spec.paused", false),
+		table.Entry("field of missing list item", `.spec.template.spec.containers[name="sidecar"].image`, false),
+		table.Entry("malformed field", "spec.replicas", false),
+	)
+})
+
+var _ = ginkgo.Describe("[apply] Applier delete", func() {
+	ns := "default"
+
+	ginkgo.It("should delete only objects controlled by the owner", func() {
+		controller := true
+		owner := &metav1.ObjectMeta{Name: "synapse", Namespace: ns, UID: "synapse-uid"}
+		controlled := newDeployment("controlled", ns, nil)
+		controlled.OwnerReferences = []metav1.OwnerReference{
+			{APIVersion: "synapse.vrutkovs.eu/v1alpha1", Kind: "Synapse", Name: owner.Name, UID: owner.UID, Controller: &controller},
+		}
+		other := newDeployment("other", ns, nil)
+		cl := fake.NewFakeClientWithScheme(scheme.Scheme, controlled, other)
+		applier := NewApplier(cl, scheme.Scheme)
+
+		for name, expectedDeleted := range map[string]bool{"controlled": true, "other": false, "missing": false} {
+			deleted, err := applier.Delete(owner, &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns}})
+			g.Expect(err).NotTo(g.HaveOccurred())
+			g.Expect(deleted).To(g.Equal(expectedDeleted), name)
+		}
+		err := cl.Get(context.TODO(), types.NamespacedName{Name: "controlled", Namespace: ns}, &appsv1.Deployment{})
+		g.Expect(apierrors.IsNotFound(err)).To(g.BeTrue())
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: "other", Namespace: ns}, &appsv1.Deployment{})
+		g.Expect(err).NotTo(g.HaveOccurred())
+	})
+})
+
+// Applier is tested against a real API server, as the fake client only emulates server-side apply.
+// These tests are skipped locally unless envtest binaries are installed
+var _ = ginkgo.Describe("[apply] Applier", func() {
+	var (
+		testEnv *envtest.Environment
+		cl      client.Client
+	)
+	name := "synapse"
+	ns := "default"
+
+	ginkgo.BeforeEach(func() {
+		if _, err := os.Stat("/usr/local/kubebuilder/bin"); os.Getenv("KUBEBUILDER_ASSETS") == "" && err != nil {
+			// CI installs the binaries, so the test must run there
+			if os.Getenv("CI") != "" {
+				ginkgo.Fail("envtest binaries are required in CI, set KUBEBUILDER_ASSETS")
+			}
+			ginkgo.Skip("envtest binaries are not installed, set KUBEBUILDER_ASSETS")
+		}
+		testEnv = &envtest.Environment{}
+		cfg, err := testEnv.Start()
+		g.Expect(err).NotTo(g.HaveOccurred())
+		cl, err = client.New(cfg, client.Options{Scheme: scheme.Scheme})
+		g.Expect(err).NotTo(g.HaveOccurred())
+	})
+
+	ginkgo.AfterEach(func() {
+		if testEnv != nil {
+			g.Expect(testEnv.Stop()).To(g.Succeed())
+			testEnv = nil
+		}
+	})
+
+	ginkgo.It("should leave fields managed by others and prune removed fields", func() {
+		owner := &metav1.ObjectMeta{Name: name, Namespace: ns}
+		applier := NewApplier(cl, scheme.Scheme)
+		err := applier.Apply(owner, newDeployment(name, ns, map[string]string{"app": name, "foo": "bar"}))
+		g.Expect(err).NotTo(g.HaveOccurred())
+
+		// Replicas are scaled by an autoscaler and a sidecar is injected
+		deployment := &appsv1.Deployment{}
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: ns}, deployment)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		replicas := int32(3)
+		deployment.Spec.Replicas = &replicas
+		err = cl.Update(context.TODO(), deployment, client.FieldOwner("kube-controller-manager"))
+		g.Expect(err).NotTo(g.HaveOccurred())
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: ns}, deployment)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		deployment.Spec.Template.Spec.Containers = append(deployment.Spec.Template.Spec.Containers, corev1.Container{
+			Name:  "sidecar",
+			Image: "sidecar:v1",
+		})
+		err = cl.Update(context.TODO(), deployment, client.FieldOwner("sidecar-injector"))
+		g.Expect(err).NotTo(g.HaveOccurred())
+
+		// Label is no longer set by the operator
+		applier.Reset(owner)
+		err = applier.Apply(owner, newDeployment(name, ns, map[string]string{"app": name}))
+		g.Expect(err).NotTo(g.HaveOccurred())
+
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: ns}, deployment)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(deployment.Spec.Replicas).To(g.Equal(&replicas))
+		containerNames := []string{}
+		for _, container := range deployment.Spec.Template.Spec.Containers {
+			containerNames = append(containerNames, container.Name)
+		}
+		g.Expect(containerNames).To(g.ConsistOf("synapse", "sidecar"))
+		g.Expect(deployment.Labels).To(g.Equal(map[string]string{"app": name}))
+		g.Expect(applier.Conflicts(owner)).To(g.Equal([]Conflict{
+			{
+				Kind:    "Deployment",
+				Name:    name,
+				Field:   ".spec.replicas",
+				Manager: "kube-controller-manager",
+			},
+		}))
+	})
+})
@@ -0,0 +1,159 @@
+package fake
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/vrutkovs/synapse-operator/pkg/controller/apply"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Client emulates server-side apply on top of controller-runtime fake client, which doesn't support it.
+// Apply patches create missing objects and are merged into existing ones. Fields applied previously
+// and missing in the patch are removed, unless they are owned by another manager. Lists are replaced,
+// so list items set by other managers are not kept
+type Client struct {
+	client.Client
+	scheme *runtime.Scheme
+	// ForeignFields maps object kind and name, e.g. "Deployment/example", to fields owned by another
+	// manager. Applying a different value without forcing ownership results in a conflict
+	ForeignFields map[string][]string
+	// applied maps object kind, namespace and name to the last applied configuration
+	applied map[string]map[string]interface{}
+}
+
+// ForeignManager is a field manager owning ForeignFields
+const ForeignManager = "kube-controller-manager"
+
+// NewClient wraps fake client c
+func NewClient(c client.Client, scheme *runtime.Scheme) *Client {
+	return &Client{
+		Client:        c,
+		scheme:        scheme,
+		ForeignFields: map[string][]string{},
+		applied:       map[string]map[string]interface{}{},
+	}
+}
+
+// Patch implements client.Client
+func (c *Client) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return fmt.Errorf("apply is only emulated for unstructured objects")
+	}
+	patchOptions := &client.PatchOptions{}
+	patchOptions.ApplyOptions(opts)
+
+	existing, err := c.newObject(u)
+	if err != nil {
+		return err
+	}
+	appliedKey := u.GetKind() + "/" + u.GetNamespace() + "/" + u.GetName()
+	foreignFields := c.ForeignFields[u.GetKind()+"/"+u.GetName()]
+	err = c.Client.Get(ctx, types.NamespacedName{Name: u.GetName(), Namespace: u.GetNamespace()}, existing)
+	if err != nil && apierrors.IsNotFound(err) {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, existing); err != nil {
+			return err
+		}
+		if err := c.Client.Create(ctx, existing); err != nil {
+			return err
+		}
+		c.applied[appliedKey] = runtime.DeepCopyJSON(u.Object)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if patchOptions.Force == nil || !*patchOptions.Force {
+		existingContent, err := runtime.DefaultUnstructuredConverter.ToUnstructured(existing)
+		if err != nil {
+			return err
+		}
+		causes := []metav1.StatusCause{}
+		for _, field := range foreignFields {
+			if isConflict(existingContent, u.Object, field) {
+				causes = append(causes, metav1.StatusCause{
+					Type:    metav1.CauseTypeFieldManagerConflict,
+					Message: fmt.Sprintf("conflict with %q using %s", ForeignManager, u.GetAPIVersion()),
+					Field:   field,
+				})
+			}
+		}
+		if len(causes) > 0 {
+			return apierrors.NewApplyConflict(causes, fmt.Sprintf("Apply failed with %d conflicts", len(causes)))
+		}
+	}
+
+	mergePatch := runtime.DeepCopyJSON(u.Object)
+	if applied, ok := c.applied[appliedKey]; ok {
+		addRemovedFields(mergePatch, applied, u.Object)
+	}
+	// Fields owned by another manager are not pruned
+	for _, field := range foreignFields {
+		if value, found, _ := apply.GetField(mergePatch, field); found && value == nil {
+			apply.RemoveField(mergePatch, field)
+		}
+	}
+	data, err := json.Marshal(mergePatch)
+	if err != nil {
+		return err
+	}
+	if err := c.Client.Patch(ctx, existing, client.RawPatch(types.MergePatchType, data)); err != nil {
+		return err
+	}
+	c.applied[appliedKey] = runtime.DeepCopyJSON(u.Object)
+	return nil
+}
+
+// newObject returns an empty typed object of u kind, or unstructured one if the kind is not registered
+func (c *Client) newObject(u *unstructured.Unstructured) (runtime.Object, error) {
+	obj, err := c.scheme.New(u.GroupVersionKind())
+	if runtime.IsNotRegisteredError(err) {
+		existing := &unstructured.Unstructured{}
+		existing.SetGroupVersionKind(u.GroupVersionKind())
+		return existing, nil
+	}
+	return obj, err
+}
+
+// isConflict returns true if applied object sets field to a value other than existing one
+func isConflict(existing, applied map[string]interface{}, field string) bool {
+	appliedValue, found, _ := apply.GetField(applied, field)
+	if !found {
+		return false
+	}
+	existingValue, found, _ := apply.GetField(existing, field)
+	if !found {
+		return true
+	}
+	existingJSON, _ := json.Marshal(existingValue)
+	appliedJSON, _ := json.Marshal(appliedValue)
+	return string(existingJSON) != string(appliedJSON)
+}
+
+// addRemovedFields sets fields present in previously applied object and missing in the new one to null,
+// so that merge patch removes them
+func addRemovedFields(patch, previous, current map[string]interface{}) {
+	for key, previousValue := range previous {
+		currentValue, found := current[key]
+		if !found {
+			patch[key] = nil
+			continue
+		}
+		previousMap, previousIsMap := previousValue.(map[string]interface{})
+		currentMap, currentIsMap := currentValue.(map[string]interface{})
+		if previousIsMap && currentIsMap {
+			addRemovedFields(patch[key].(map[string]interface{}), previousMap, currentMap)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package apply
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pathElement is a step of field path: a field name, a list item selected by key fields or by value,
+// or a list index
+type pathElement struct {
+	field string
+	keys  map[string]interface{}
+	value interface{}
+	index int
+	kind  elementKind
+}
+
+type elementKind int
+
+const (
+	fieldElement elementKind = iota
+	keyElement
+	valueElement
+	indexElement
+)
+
+// RemoveField removes a field from the object. Path is formatted like in server-side apply conflicts,
+// e.g. `.spec.template.spec.containers[name="synapse"].image`. Returns false if the field is not set
+func RemoveField(object map[string]interface{}, path string) (bool, error) {
+	elements, err := parsePath(path)
+	if err != nil {
+		return false, err
+	}
+	if len(elements) == 0 {
+		return false, fmt.Errorf("empty field path")
+	}
+	_, removed := removeElements(object, elements)
+	return removed, nil
+}
+
+// GetField returns value of the field in the object. Path is formatted like in RemoveField.
+// Returns false if the field is not set
+func GetField(object map[string]interface{}, path string) (interface{}, bool, error) {
+	elements, err := parsePath(path)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(elements) == 0 {
+		return nil, false, fmt.Errorf("empty field path")
+	}
+	value, found := getElements(object, elements)
+	return value, found, nil
+}
+
+// getElements returns the field of parent
+func getElements(parent interface{}, elements []pathElement) (interface{}, bool) {
+	if len(elements) == 0 {
+		return parent, true
+	}
+	switch container := parent.(type) {
+	case map[string]interface{}:
+		if elements[0].kind != fieldElement {
+			return nil, false
+		}
+		for n := len(elements); n > 0; n-- {
+			key, ok := joinFields(elements[:n])
+			if !ok {
+				continue
+			}
+			if child, found := container[key]; found {
+				return getElements(child, elements[n:])
+			}
+		}
+	case []interface{}:
+		for i, item := range container {
+			if elements[0].matches(i, item) {
+				return getElements(item, elements[1:])
+			}
+		}
+	}
+	return nil, false
+}
+
+// removeElements removes the field from parent, returning updated parent, as removing a list item
+// creates a new slice
+func removeElements(parent interface{}, elements []pathElement) (interface{}, bool) {
+	switch container := parent.(type) {
+	case map[string]interface{}:
+		if elements[0].kind != fieldElement {
+			return parent, false
+		}
+		// Map keys may contain dots, e.g. labels, so the longest key present in the map is taken
+		for n := len(elements); n > 0; n-- {
+			key, ok := joinFields(elements[:n])
+			if !ok {
+				continue
+			}
+			child, found := container[key]
+			if !found {
+				continue
+			}
+			if n == len(elements) {
+				delete(container, key)
+				return container, true
+			}
+			child, removed := removeElements(child, elements[n:])
+			container[key] = child
+			return container, removed
+		}
+	case []interface{}:
+		for i, item := range container {
+			if !elements[0].matches(i, item) {
+				continue
+			}
+			if len(elements) == 1 {
+				return append(container[:i:i], container[i+1:]...), true
+			}
+			item, removed := removeElements(item, elements[1:])
+			container[i] = item
+			return container, removed
+		}
+	}
+	return parent, false
+}
+
+// joinFields joins consecutive field elements with dots
+func joinFields(elements []pathElement) (string, bool) {
+	names := make([]string, len(elements))
+	for i, element := range elements {
+		if element.kind != fieldElement {
+			return "", false
+		}
+		names[i] = element.field
+	}
+	return strings.Join(names, "."), true
+}
+
+func (e pathElement) matches(index int, item interface{}) bool {
+	switch e.kind {
+	case indexElement:
+		return e.index == index
+	case valueElement:
+		return fmt.Sprint(item) == fmt.Sprint(e.value)
+	case keyElement:
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, value := range e.keys {
+			// Numbers are float64 in the path and int64 in the object, so they are compared as text
+			if fmt.Sprint(fields[key]) != fmt.Sprint(value) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// parsePath splits field path into elements. Besides conflict format, elements may be written
+// as in managed fields: `.f:spec`, `.k:{"name":"synapse"}`, `.v:"value"` and `.i:0`
+func parsePath(path string) ([]pathElement, error) {
+	elements := []pathElement{}
+	for len(path) > 0 {
+		var element pathElement
+		var rest string
+		var err error
+		switch path[0] {
+		case '.':
+			element, rest, err = parseSegment(path[1:])
+		case '[':
+			element, rest, err = parseSelector(path[1:])
+		default:
+			err = fmt.Errorf("unexpected %q", path[0])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid field path %q: %w", path, err)
+		}
+		elements = append(elements, element)
+		path = rest
+	}
+	return elements, nil
+}
+
+// parseSegment parses path element following a dot, up to the next dot or bracket
+func parseSegment(s string) (pathElement, string, error) {
+	switch {
+	case strings.HasPrefix(s, "k:"):
+		value, rest, err := parseValue(s[2:], ".[")
+		if err != nil {
+			return pathElement{}, "", err
+		}
+		keys, ok := value.(map[string]interface{})
+		if !ok || len(keys) == 0 {
+			return pathElement{}, "", fmt.Errorf("list item key must be a non-empty object")
+		}
+		return pathElement{kind: keyElement, keys: keys}, rest, nil
+	case strings.HasPrefix(s, "v:"):
+		value, rest, err := parseValue(s[2:], ".[")
+		if err != nil {
+			return pathElement{}, "", err
+		}
+		return pathElement{kind: valueElement, value: value}, rest, nil
+	case strings.HasPrefix(s, "i:"):
+		name, rest := splitFieldName(s[2:])
+		index, err := strconv.Atoi(name)
+		if err != nil {
+			return pathElement{}, "", fmt.Errorf("invalid list index: %w", err)
+		}
+		return pathElement{kind: indexElement, index: index}, rest, nil
+	}
+	name, rest := splitFieldName(strings.TrimPrefix(s, "f:"))
+	if name == "" {
+		return pathElement{}, "", fmt.Errorf("empty field name")
+	}
+	return pathElement{kind: fieldElement, field: name}, rest, nil
+}
+
+// splitFieldName splits s at the next dot or bracket
+func splitFieldName(s string) (string, string) {
+	end := strings.IndexAny(s, ".[")
+	if end == -1 {
+		end = len(s)
+	}
+	return s[:end], s[end:]
+}
+
+// parseSelector parses list item selector up to the closing bracket: `name="synapse"`,
+// `containerPort=8008,protocol="TCP"`, `="value"` or `0`
+func parseSelector(s string) (pathElement, string, error) {
+	if end := strings.IndexByte(s, ']'); end > 0 {
+		if index, err := strconv.Atoi(s[:end]); err == nil {
+			return pathElement{kind: indexElement, index: index}, s[end+1:], nil
+		}
+	}
+	keys := map[string]interface{}{}
+	for {
+		eq := strings.IndexByte(s, '=')
+		if eq == -1 {
+			return pathElement{}, "", fmt.Errorf("missing value in list selector")
+		}
+		key := s[:eq]
+		value, rest, err := parseValue(s[eq+1:], ",]")
+		if err != nil {
+			return pathElement{}, "", err
+		}
+		if key == "" {
+			if !strings.HasPrefix(rest, "]") {
+				return pathElement{}, "", fmt.Errorf("unterminated list selector")
+			}
+			return pathElement{kind: valueElement, value: value}, rest[1:], nil
+		}
+		keys[key] = value
+		switch {
+		case strings.HasPrefix(rest, ","):
+			s = rest[1:]
+		case strings.HasPrefix(rest, "]"):
+			return pathElement{kind: keyElement, keys: keys}, rest[1:], nil
+		default:
+			return pathElement{}, "", fmt.Errorf("unterminated list selector")
+		}
+	}
+}
+
+// parseValue parses JSON value at the start of s, returning the rest of the string.
+// Scalars other than strings end at any of terminators
+func parseValue(s, terminators string) (interface{}, string, error) {
+	end := jsonValueEnd(s, terminators)
+	if end <= 0 || end > len(s) {
+		return nil, "", fmt.Errorf("invalid value in list selector")
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(s[:end]), &value); err != nil {
+		return nil, "", fmt.Errorf("invalid value in list selector: %w", err)
+	}
+	return value, s[end:], nil
+}
+
+// jsonValueEnd returns length of JSON value at the start of s, or -1 if it's not terminated.
+// Objects, arrays and strings end at their closing character, so they may contain terminators
+func jsonValueEnd(s, terminators string) int {
+	if s == "" {
+		return -1
+	}
+	if s[0] != '"' && s[0] != '{' && s[0] != '[' {
+		end := strings.IndexAny(s, terminators)
+		if end == -1 {
+			return len(s)
+		}
+		return end
+	}
+	depth := 0
+	inString := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case inString && c == '\\':
+			i++
+		case c == '"':
+			inString = !inString
+			if !inString && depth == 0 {
+				return i + 1
+			}
+		case inString:
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,145 @@
+package apply
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	g "github.com/onsi/gomega"
+)
+
+func TestGinkgo(t *testing.T) {
+	g.RegisterFailHandler(ginkgo.Fail)
+	ginkgo.RunSpecs(t, "unit tests")
+}
+
+// newObject returns a deployment with a container, ports, args and labels with dots in keys
+func newObject() map[string]interface{} {
+	object := map[string]interface{}{}
+	err := json.Unmarshal([]byte(`{
+		"metadata": {
+			"labels": {"app": "synapse", "app.kubernetes.io/name": "synapse"}
+		},
+		"spec": {
+			"replicas": 1,
+			"template": {
+				"spec": {
+					"containers": [
+						{
+							"name": "synapse",
+							"image": "synapse:v1",
+							"args": ["--save", "--appendonly"],
+							"ports": [
+								{"containerPort": 8008, "protocol": "TCP", "name": "http"},
+								{"containerPort": 8008, "protocol": "UDP", "name": "udp"}
+							]
+						},
+						{"name": "sidecar", "image": "sidecar:v1"}
+					]
+				}
+			}
+		}
+	}`), &object)
+	g.Expect(err).NotTo(g.HaveOccurred())
+	return object
+}
+
+var _ = ginkgo.Describe("[apply]", func() {
+	table.DescribeTable("should remove fields",
+		func(path string, expectedFound bool, check func(object map[string]interface{})) {
+			object := newObject()
+			value, found, err := GetField(object, path)
+			g.Expect(err).NotTo(g.HaveOccurred())
+			g.Expect(found).To(g.Equal(expectedFound))
+
+			removed, err := RemoveField(object, path)
+			g.Expect(err).NotTo(g.HaveOccurred())
+			g.Expect(removed).To(g.Equal(expectedFound))
+			if !expectedFound {
+				g.Expect(value).To(g.BeNil())
+				g.Expect(object).To(g.Equal(newObject()))
+				return
+			}
+			_, found, err = GetField(object, path)
+			g.Expect(err).NotTo(g.HaveOccurred())
+			g.Expect(found).To(g.BeFalse())
+			check(object)
+		},
+		table.Entry("top level field", ".spec.replicas", true, func(object map[string]interface{}) {
+			g.Expect(object["spec"]).NotTo(g.HaveKey("replicas"))
+		}),
+		table.Entry("map key with dots", ".metadata.labels.app.kubernetes.io/name", true, func(object map[string]interface{}) {
+			g.Expect(object["metadata"]).To(g.HaveKeyWithValue("labels", map[string]interface{}{"app": "synapse"}))
+		}),
+		table.Entry("field of list item selected by key", `.spec.template.spec.containers[name="x"].image`, false, nil),
+		table.Entry("field of container", `.spec.template.spec.containers[name="sidecar"].image`, true, func(object map[string]interface{}) {
+			containers := getContainers(object)
+			g.Expect(containers[0]).To(g.HaveKey("image"))
+			g.Expect(containers[1]).To(g.Equal(map[string]interface{}{"name": "sidecar"}))
+		}),
+		table.Entry("list item selected by several keys", `.spec.template.spec.containers[name="synapse"].ports[containerPort=8008,protocol="UDP"]`, true, func(object map[string]interface{}) {
+			ports := getContainers(object)[0]["ports"].([]interface{})
+			g.Expect(ports).To(g.HaveLen(1))
+			g.Expect(ports[0]).To(g.HaveKeyWithValue("protocol", "TCP"))
+		}),
+		table.Entry("list item selected by value", `.spec.template.spec.containers[name="synapse"].args[="--save"]`, true, func(object map[string]interface{}) {
+			g.Expect(getContainers(object)[0]["args"]).To(g.Equal([]interface{}{"--appendonly"}))
+		}),
+		table.Entry("list item selected by index", `.spec.template.spec.containers[1]`, true, func(object map[string]interface{}) {
+			g.Expect(getContainers(object)).To(g.HaveLen(1))
+		}),
+		table.Entry("managed fields key", `.f:spec.f:template.f:spec.f:containers.k:{"name":"sidecar"}.f:image`, true, func(object map[string]interface{}) {
+			g.Expect(getContainers(object)[1]).NotTo(g.HaveKey("image"))
+		}),
+		table.Entry("managed fields key with several fields", `.spec.template.spec.containers.k:{"name":"synapse"}.ports.k:{"containerPort":8008,"protocol":"TCP"}`, true, func(object map[string]interface{}) {
+			ports := getContainers(object)[0]["ports"].([]interface{})
+			g.Expect(ports).To(g.HaveLen(1))
+			g.Expect(ports[0]).To(g.HaveKeyWithValue("protocol", "UDP"))
+		}),
+		table.Entry("managed fields key with dots in value", `.spec.template.spec.containers.k:{"name":"a.b[0]"}`, false, nil),
+		table.Entry("managed fields value", `.spec.template.spec.containers.k:{"name":"synapse"}.args.v:"--appendonly"`, true, func(object map[string]interface{}) {
+			g.Expect(getContainers(object)[0]["args"]).To(g.Equal([]interface{}{"--save"}))
+		}),
+		table.Entry("managed fields index", `.spec.template.spec.containers.i:0.image`, true, func(object map[string]interface{}) {
+			g.Expect(getContainers(object)[0]).NotTo(g.HaveKey("image"))
+		}),
+		table.Entry("missing field", ".spec.paused", false, nil),
+		table.Entry("field of scalar", ".spec.replicas.value", false, nil),
+	)
+
+	table.DescribeTable("should reject malformed paths",
+		func(path string) {
+			object := newObject()
+			_, err := RemoveField(object, path)
+			g.Expect(err).To(g.HaveOccurred())
+			_, _, err = GetField(object, path)
+			g.Expect(err).To(g.HaveOccurred())
+			g.Expect(object).To(g.Equal(newObject()))
+		},
+		table.Entry("empty path", ""),
+		table.Entry("missing leading dot", "spec.replicas"),
+		table.Entry("empty field name", ".spec..replicas"),
+		table.Entry("trailing dot", ".spec."),
+		table.Entry("unterminated selector", `.spec.template.spec.containers[name="synapse"`),
+		table.Entry("selector without value", `.spec.template.spec.containers[name]`),
+		table.Entry("unterminated string in selector", `.spec.template.spec.containers[name="synapse]`),
+		table.Entry("invalid JSON in selector", `.spec.template.spec.containers[name=synapse]`),
+		table.Entry("unterminated key", `.spec.template.spec.containers.k:{"name":"synapse"`),
+		table.Entry("key which is not an object", `.spec.template.spec.containers.k:"synapse"`),
+		table.Entry("empty key", `.spec.template.spec.containers.k:{}`),
+		table.Entry("invalid value", `.spec.template.spec.containers.k:{"name":"synapse"}.args.v:--save`),
+		table.Entry("invalid index", `.spec.template.spec.containers.i:first`),
+	)
+})
+
+func getContainers(object map[string]interface{}) []map[string]interface{} {
+	value, found, err := GetField(object, ".spec.template.spec.containers")
+	g.Expect(err).NotTo(g.HaveOccurred())
+	g.Expect(found).To(g.BeTrue())
+	containers := []map[string]interface{}{}
+	for _, container := range value.([]interface{}) {
+		containers = append(containers, container.(map[string]interface{}))
+	}
+	return containers
+}
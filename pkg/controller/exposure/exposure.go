@@ -0,0 +1,216 @@
+package exposure
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/vrutkovs/synapse-operator/pkg/controller/apply"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// RouteGVK is a kind of OpenShift Routes. These are managed as unstructured objects
+// to avoid depending on OpenShift API
+var RouteGVK = schema.GroupVersionKind{Group: "route.openshift.io", Version: "v1", Kind: "Route"}
+
+// routeNameReplacer converts ingress path to a valid route name suffix
+var routeNameReplacer = strings.NewReplacer("/_", "-", "/", "-")
+
+// IsRouteAPIAvailable checks if the cluster serves OpenShift Route API
+func IsRouteAPIAvailable(mgr manager.Manager) bool {
+	_, err := mgr.GetRESTMapper().RESTMapping(RouteGVK.GroupKind(), RouteGVK.Version)
+	return err == nil
+}
+
+// Exposure describes paths on a public host routed to a service
+type Exposure struct {
+	// Name of the Ingress. Routes are named after it, with path suffix if there are several paths
+	Name        string
+	Namespace   string
+	Labels      map[string]string
+	Annotations map[string]string
+	Host        string
+	Paths       []string
+	ServiceName string
+	// TLSSecretName is a secret with TLS certificate and key for the host
+	TLSSecretName string
+}
+
+// Reconciler applies Ingress, or a Route for each path on OpenShift
+type Reconciler struct {
+	Client  client.Client
+	Scheme  *runtime.Scheme
+	Applier *apply.Applier
+	// RoutesAvailable is set when OpenShift Routes are used instead of Ingress
+	RoutesAvailable bool
+}
+
+// Reconcile exposes the service with objects owned by the owner
+func (r *Reconciler) Reconcile(owner metav1.Object, e *Exposure, reqLogger logr.Logger) error {
+	if !r.RoutesAvailable {
+		ingress := NewIngress(e)
+		reqLogger.Info("Applying Ingress", "Ingress.Namespace", ingress.Namespace, "Ingress.Name", ingress.Name)
+		return r.apply(owner, ingress)
+	}
+	tls, err := r.getRouteTLS(e)
+	if err != nil {
+		return err
+	}
+	for _, route := range NewRoutes(e, tls) {
+		reqLogger.Info("Applying Route", "Route.Namespace", route.GetNamespace(), "Route.Name", route.GetName())
+		if err := r.apply(owner, route); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes Ingress or Routes exposing the service, e.g. when exposure is disabled. Only name,
+// namespace and paths of the exposure are used
+func (r *Reconciler) Delete(owner metav1.Object, e *Exposure, reqLogger logr.Logger) error {
+	if !r.RoutesAvailable {
+		ingress := &networkingv1beta1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: e.Name, Namespace: e.Namespace},
+		}
+		deleted, err := r.Applier.Delete(owner, ingress)
+		if deleted {
+			reqLogger.Info("Deleted Ingress", "Ingress.Namespace", e.Namespace, "Ingress.Name", e.Name)
+		}
+		return err
+	}
+	for _, route := range NewRoutes(e, nil) {
+		name := route.GetName()
+		deleted, err := r.Applier.Delete(owner, route)
+		if err != nil {
+			return err
+		}
+		if deleted {
+			reqLogger.Info("Deleted Route", "Route.Namespace", e.Namespace, "Route.Name", name)
+		}
+	}
+	return nil
+}
+
+// apply sets the owner as controller of obj and applies it
+func (r *Reconciler) apply(owner metav1.Object, obj runtime.Object) error {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+	if err := controllerutil.SetControllerReference(owner, accessor, r.Scheme); err != nil {
+		return err
+	}
+	return r.Applier.Apply(owner, obj)
+}
+
+// getRouteTLS returns edge termination settings. Routes embed certificates,
+// so these are copied from TLS secret if it's set
+func (r *Reconciler) getRouteTLS(e *Exposure) (map[string]interface{}, error) {
+	tls := map[string]interface{}{
+		"termination":                   "edge",
+		"insecureEdgeTerminationPolicy": "Redirect",
+	}
+	if e.TLSSecretName == "" {
+		return tls, nil
+	}
+	secret := &corev1.Secret{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: e.TLSSecretName, Namespace: e.Namespace}, secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ingress TLS secret: %w", err)
+	}
+	tls["certificate"] = string(secret.Data[corev1.TLSCertKey])
+	tls["key"] = string(secret.Data[corev1.TLSPrivateKeyKey])
+	return tls, nil
+}
+
+// NewIngress returns an ingress routing paths on the host to the service
+func NewIngress(e *Exposure) *networkingv1beta1.Ingress {
+	paths := []networkingv1beta1.HTTPIngressPath{}
+	for _, path := range e.Paths {
+		paths = append(paths, networkingv1beta1.HTTPIngressPath{
+			Path: path,
+			Backend: networkingv1beta1.IngressBackend{
+				ServiceName: e.ServiceName,
+				ServicePort: intstr.FromString("http"),
+			},
+		})
+	}
+	return &networkingv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        e.Name,
+			Namespace:   e.Namespace,
+			Labels:      e.Labels,
+			Annotations: e.Annotations,
+		},
+		Spec: networkingv1beta1.IngressSpec{
+			TLS: []networkingv1beta1.IngressTLS{
+				{
+					Hosts:      []string{e.Host},
+					SecretName: e.TLSSecretName,
+				},
+			},
+			Rules: []networkingv1beta1.IngressRule{
+				{
+					Host: e.Host,
+					IngressRuleValue: networkingv1beta1.IngressRuleValue{
+						HTTP: &networkingv1beta1.HTTPIngressRuleValue{
+							Paths: paths,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// NewRoutes returns a route for each path, as Routes support a single path only
+func NewRoutes(e *Exposure, tls map[string]interface{}) []*unstructured.Unstructured {
+	routes := []*unstructured.Unstructured{}
+	for _, path := range e.Paths {
+		spec := map[string]interface{}{
+			"host": e.Host,
+			"to": map[string]interface{}{
+				"kind":   "Service",
+				"name":   e.ServiceName,
+				"weight": int64(100),
+			},
+			"port": map[string]interface{}{
+				"targetPort": "http",
+			},
+			"tls":            tls,
+			"wildcardPolicy": "None",
+		}
+		// Root path is the default
+		if path != "/" {
+			spec["path"] = path
+		}
+		route := &unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"spec": spec,
+			},
+		}
+		route.SetGroupVersionKind(RouteGVK)
+		route.SetName(e.Name)
+		if len(e.Paths) > 1 {
+			route.SetName(e.Name + "-" + strings.Trim(routeNameReplacer.Replace(path), "-"))
+		}
+		route.SetNamespace(e.Namespace)
+		route.SetLabels(e.Labels)
+		route.SetAnnotations(e.Annotations)
+		routes = append(routes, route)
+	}
+	return routes
+}
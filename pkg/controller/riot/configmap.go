@@ -1,67 +1,45 @@
 package riot
 
 import (
-	"context"
-	"reflect"
-
 	"github.com/go-logr/logr"
 	riotv1alphav1 "github.com/vrutkovs/synapse-operator/pkg/apis/riot/v1alpha1"
+	synapsev1alpha1 "github.com/vrutkovs/synapse-operator/pkg/apis/synapse/v1alpha1"
 
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
-func (r *ReconcileRiot) reconcileConfigMap(request reconcile.Request, instance *riotv1alphav1.Riot, reqLogger logr.Logger) (reconcile.Result, bool, error) {
-	configMap := newConfigMapForCR(instance)
+func (r *ReconcileRiot) reconcileConfigMap(request reconcile.Request, instance *riotv1alphav1.Riot, reqLogger logr.Logger, s *synapsev1alpha1.Synapse) (reconcile.Result, error) {
+	expectedData, err := getExpectedConfigmapData(instance, s)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	configMap := newConfigMapForCR(instance, expectedData)
 
 	// Set Riot instance as the owner and controller
 	if err := controllerutil.SetControllerReference(instance, configMap, r.scheme); err != nil {
-		return reconcile.Result{}, false, err
+		return reconcile.Result{}, err
 	}
 
-	// Check if this ConfigMap already exists
-	found := &corev1.ConfigMap{}
-	err := r.client.Get(context.TODO(), types.NamespacedName{Name: configMap.Name, Namespace: configMap.Namespace}, found)
-	if err != nil && errors.IsNotFound(err) {
-		reqLogger.Info("Creating a new ConfigMap", "ConfigMap.Namespace", configMap.Namespace, "ConfigMap.Name", configMap.Name)
-		err = r.client.Create(context.TODO(), configMap)
-		if err != nil {
-			return reconcile.Result{}, false, err
-		}
-
-		// ConfigMap created successfully - don't requeue
-		return reconcile.Result{}, true, nil
-	} else if err != nil {
-		// Unknown error - requeue
-		reqLogger.Info("ConfigMap reconcile error", "ConfigMap.Namespace", found.Namespace, "ConfigMap.Name", found.Name, "Error", err)
-		return reconcile.Result{Requeue: true}, false, nil
-	} else if err == nil {
-		// Check if configmap fields haven't change
-		expectedData := instance.GetExpectedConfigmapData()
-		if !reflect.DeepEqual(found.Data, expectedData) {
-			found.ObjectMeta = configMap.ObjectMeta
-			controllerutil.SetControllerReference(instance, found, r.scheme)
-			found.Data = expectedData
-			err = r.client.Update(context.TODO(), found)
-			if err != nil {
-				return reconcile.Result{Requeue: true}, false, err
-			}
-			reqLogger.Info("ConfigMap contents updated", "ConfigMap.Namespace", found.Namespace, "ConfigMap.Name", found.Name)
-			return reconcile.Result{}, true, nil
-		}
+	reqLogger.Info("Applying ConfigMap", "ConfigMap.Namespace", configMap.Namespace, "ConfigMap.Name", configMap.Name)
+	if err := r.applier.Apply(instance, configMap); err != nil {
+		return reconcile.Result{}, err
 	}
+	return reconcile.Result{}, nil
+}
 
-	// ConfigMap already exists - don't requeue
-	reqLogger.Info("Skip reconcile: ConfigMap already exists", "ConfigMap.Namespace", found.Namespace, "ConfigMap.Name", found.Name)
-	return reconcile.Result{}, false, nil
+// getExpectedConfigmapData returns configmap data with referenced Synapse set as default homeserver
+func getExpectedConfigmapData(cr *riotv1alphav1.Riot, s *synapsev1alpha1.Synapse) (map[string]string, error) {
+	if s == nil {
+		return cr.GetExpectedConfigmapData("", "")
+	}
+	return cr.GetExpectedConfigmapData(s.GetPublicBaseURL(), s.Spec.ServerName)
 }
 
 // newConfigMapForCR returns a busybox pod with the same name/namespace as the cr
-func newConfigMapForCR(cr *riotv1alphav1.Riot) *corev1.ConfigMap {
+func newConfigMapForCR(cr *riotv1alphav1.Riot, data map[string]string) *corev1.ConfigMap {
 	labels := map[string]string{
 		"app": cr.Name,
 	}
@@ -71,6 +49,6 @@ func newConfigMapForCR(cr *riotv1alphav1.Riot) *corev1.ConfigMap {
 			Namespace: cr.Namespace,
 			Labels:    labels,
 		},
-		Data: cr.GetExpectedConfigmapData(),
+		Data: data,
 	}
 }
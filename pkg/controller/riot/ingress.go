@@ -0,0 +1,70 @@
+package riot
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	riotv1alphav1 "github.com/vrutkovs/synapse-operator/pkg/apis/riot/v1alpha1"
+	"github.com/vrutkovs/synapse-operator/pkg/controller/exposure"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// ingressPaths are Riot paths exposed via ingress
+var ingressPaths = []string{"/"}
+
+func (r *ReconcileRiot) reconcileIngress(request reconcile.Request, instance *riotv1alphav1.Riot, reqLogger logr.Logger) (reconcile.Result, error) {
+	reconciler := &exposure.Reconciler{
+		Client:          r.client,
+		Scheme:          r.scheme,
+		Applier:         r.applier,
+		RoutesAvailable: r.routesAvailable,
+	}
+	if instance.Spec.Ingress == nil {
+		// Stop serving traffic once ingress is disabled
+		e := &exposure.Exposure{Name: instance.GetIngressName(), Namespace: instance.Namespace, Paths: ingressPaths}
+		return reconcile.Result{}, reconciler.Delete(instance, e, reqLogger)
+	}
+	if err := reconciler.Reconcile(instance, newExposureForCR(instance), reqLogger); err != nil {
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{}, nil
+}
+
+// newExposureForCR returns all paths on the host routed to Riot service
+func newExposureForCR(cr *riotv1alphav1.Riot) *exposure.Exposure {
+	return &exposure.Exposure{
+		Name:      cr.GetIngressName(),
+		Namespace: cr.Namespace,
+		Labels: map[string]string{
+			"app": cr.Name,
+		},
+		Annotations:   cr.Spec.Ingress.Annotations,
+		Host:          cr.Spec.Ingress.Host,
+		Paths:         ingressPaths,
+		ServiceName:   cr.GetServiceName(),
+		TLSSecretName: cr.Spec.Ingress.TLSSecretName,
+	}
+}
+
+// getRiotsReferencingTLSSecret maps a secret to Riot instances in the same namespace using it for ingress TLS.
+// Routes embed certificates, so they are updated when the certificate is rotated
+func getRiotsReferencingTLSSecret(c client.Client, a handler.MapObject) []reconcile.Request {
+	riots := &riotv1alphav1.RiotList{}
+	if err := c.List(context.TODO(), riots, client.InNamespace(a.Meta.GetNamespace())); err != nil {
+		log.Error(err, "Failed to list Riot instances", "Secret.Namespace", a.Meta.GetNamespace(), "Secret.Name", a.Meta.GetName())
+		return nil
+	}
+	requests := []reconcile.Request{}
+	for _, riot := range riots.Items {
+		if riot.Spec.Ingress != nil && riot.Spec.Ingress.TLSSecretName == a.Meta.GetName() {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: riot.Name, Namespace: riot.Namespace},
+			})
+		}
+	}
+	return requests
+}
@@ -3,10 +3,16 @@ package riot
 import (
 	"context"
 
+	"github.com/go-logr/logr"
 	riotv1alpha1 "github.com/vrutkovs/synapse-operator/pkg/apis/riot/v1alpha1"
+	synapsev1alpha1 "github.com/vrutkovs/synapse-operator/pkg/apis/synapse/v1alpha1"
+	"github.com/vrutkovs/synapse-operator/pkg/controller/apply"
+	"github.com/vrutkovs/synapse-operator/pkg/controller/exposure"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
@@ -27,7 +33,12 @@ func Add(mgr manager.Manager) error {
 
 // newReconciler returns a new reconcile.Reconciler
 func newReconciler(mgr manager.Manager) reconcile.Reconciler {
-	return &ReconcileRiot{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+	return &ReconcileRiot{
+		client:          mgr.GetClient(),
+		scheme:          mgr.GetScheme(),
+		applier:         apply.NewApplier(mgr.GetClient(), mgr.GetScheme()),
+		routesAvailable: exposure.IsRouteAPIAvailable(mgr),
+	}
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
@@ -68,6 +79,46 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		return err
 	}
 
+	err = c.Watch(&source.Kind{Type: &networkingv1beta1.Ingress{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &riotv1alpha1.Riot{},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to referenced Synapse, so that default homeserver is updated
+	err = c.Watch(&source.Kind{Type: &synapsev1alpha1.Synapse{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(a handler.MapObject) []reconcile.Request {
+			return getRiotsForSynapseObject(mgr.GetClient(), a)
+		}),
+	})
+	if err != nil {
+		return err
+	}
+
+	if exposure.IsRouteAPIAvailable(mgr) {
+		route := &unstructured.Unstructured{}
+		route.SetGroupVersionKind(exposure.RouteGVK)
+		err = c.Watch(&source.Kind{Type: route}, &handler.EnqueueRequestForOwner{
+			IsController: true,
+			OwnerType:    &riotv1alpha1.Riot{},
+		})
+		if err != nil {
+			return err
+		}
+
+		// Watch for changes to ingress TLS secrets, as certificates are copied to Routes
+		err = c.Watch(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(func(a handler.MapObject) []reconcile.Request {
+				return getRiotsReferencingTLSSecret(mgr.GetClient(), a)
+			}),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -80,6 +131,10 @@ type ReconcileRiot struct {
 	// that reads objects from the cache and writes to the apiserver
 	client client.Client
 	scheme *runtime.Scheme
+	// applier manages configmaps, deployments, services, ingresses and routes via server-side apply
+	applier *apply.Applier
+	// routesAvailable is set when OpenShift Routes are used instead of Ingress
+	routesAvailable bool
 }
 
 // Reconcile reads that state of the cluster for a Riot object and makes changes based on the state read
@@ -107,24 +162,48 @@ func (r *ReconcileRiot) Reconcile(request reconcile.Request) (reconcile.Result,
 		return reconcile.Result{}, err
 	}
 
-	result, cmUpdated, err := r.reconcileConfigMap(request, instance, reqLogger)
+	// Find referenced Synapse object
+	s, err := r.findReferencedSynapse(instance)
+	if err != nil && errors.IsNotFound(err) {
+		// Synapse watch would enqueue Riot when Synapse is created
+		reqLogger.Info("Waiting for referenced Synapse", "Synapse.Namespace", instance.Namespace, "Synapse.Name", instance.Spec.SynapseRef.Name)
+		return reconcile.Result{}, r.updateStatus(instance, false, reqLogger)
+	} else if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	r.applier.Reset(instance)
+	result, err := r.reconcileResources(request, instance, reqLogger, s)
+
+	// Record the outcome in status, e.g. invalid config, the reconcile error takes precedence over status update error
+	if statusErr := r.updateStatus(instance, true, reqLogger); statusErr != nil {
+		reqLogger.Info("Failed to update Riot status", "Error", statusErr)
+		if err == nil {
+			return reconcile.Result{}, statusErr
+		}
+	}
+
+	return result, err
+}
+
+// reconcileResources creates or updates all resources managed by Riot instance
+func (r *ReconcileRiot) reconcileResources(request reconcile.Request, instance *riotv1alpha1.Riot, reqLogger logr.Logger, s *synapsev1alpha1.Synapse) (reconcile.Result, error) {
+	result, err := r.reconcileConfigMap(request, instance, reqLogger, s)
 	if err != nil {
 		return result, err
 	}
 
-	result, created, err := r.reconcileDeployment(request, instance, reqLogger)
+	result, err = r.reconcileDeployment(request, instance, reqLogger)
 	if err != nil {
 		return result, err
 	}
 
-	// If either of configMap or secret has been updated force rollout
-	if cmUpdated && !created {
-		if result, err := r.forceDeploymentRollout(request, instance, reqLogger); err != nil {
-			return result, err
-		}
+	result, err = r.reconcileService(request, instance, reqLogger)
+	if err != nil {
+		return result, err
 	}
 
-	result, err = r.reconcileService(request, instance, reqLogger)
+	result, err = r.reconcileIngress(request, instance, reqLogger)
 	if err != nil {
 		return result, err
 	}
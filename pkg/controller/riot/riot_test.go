@@ -1,6 +1,8 @@
 package riot
 
 import (
+	"context"
+	"encoding/json"
 	"flag"
 	"testing"
 	"time"
@@ -8,10 +10,22 @@ import (
 	"github.com/onsi/ginkgo"
 	g "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/kubectl/pkg/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	riotv1alpha1 "github.com/vrutkovs/synapse-operator/pkg/apis/riot/v1alpha1"
+	"github.com/vrutkovs/synapse-operator/pkg/controller/apply"
+	applyfake "github.com/vrutkovs/synapse-operator/pkg/controller/apply/fake"
+	"github.com/vrutkovs/synapse-operator/pkg/controller/exposure"
+	"github.com/vrutkovs/synapse-operator/pkg/controller/rollout"
 )
 
 var (
@@ -52,7 +66,7 @@ var _ = ginkgo.Describe("[riot]", func() {
 
 	ginkgo.It("should create configmap", func() {
 		spec := riotv1alpha1.RiotSpec{
-			Config: "{foo: bar}",
+			Config: `{"foo": "bar"}`,
 		}
 		instance := initFakeRiot(t, name, ns, &spec)
 		cl = initFakeClient(t, instance, name, ns)
@@ -60,7 +74,7 @@ var _ = ginkgo.Describe("[riot]", func() {
 		g.Expect(cm.Name).To(g.Equal(instance.GetConfigMapName()))
 		g.Expect(cm.Labels).To(g.Equal(map[string]string{"app": name}))
 		g.Expect(cm.Data).To(g.Equal(map[string]string{
-			"config.json": "{foo: bar}",
+			"config.json": "{\n  \"foo\": \"bar\"\n}",
 		}))
 	})
 
@@ -146,4 +160,304 @@ var _ = ginkgo.Describe("[riot]", func() {
 			},
 		}))
 	})
+
+	ginkgo.It("should roll out deployment when config changes", func() {
+		spec := riotv1alpha1.RiotSpec{
+			Config: "{}",
+		}
+		instance := initFakeRiot(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns)
+		deployment := getDeployment(t, instance, cl, ns)
+		configHash := deployment.Spec.Template.Annotations[rollout.ConfigHashAnnotation]
+		g.Expect(configHash).NotTo(g.BeEmpty())
+
+		err := cl.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: ns}, instance)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		instance.Spec.Config = `{"brand": "Riot"}`
+		err = cl.Update(context.TODO(), instance)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		reconcileRiot(t, cl, name, ns)
+		deployment = getDeployment(t, instance, cl, ns)
+		g.Expect(deployment.Spec.Template.Annotations[rollout.ConfigHashAnnotation]).NotTo(g.Equal(configHash))
+	})
+
+	ginkgo.It("should update deployment image", func() {
+		spec := riotv1alpha1.RiotSpec{
+			Replicas: 1,
+		}
+		instance := initFakeRiot(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns)
+		deployment := getDeployment(t, instance, cl, ns)
+		g.Expect(deployment.Spec.Template.Spec.Containers[0].Image).To(g.Equal(riotv1alpha1.DefaultImage))
+
+		err := cl.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: ns}, instance)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		instance.Spec.Image = "docker.io/vectorim/riot-web:v1.7.6"
+		err = cl.Update(context.TODO(), instance)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		reconcileRiot(t, cl, name, ns)
+		deployment = getDeployment(t, instance, cl, ns)
+		g.Expect(deployment.Spec.Template.Spec.Containers[0].Image).To(g.Equal("docker.io/vectorim/riot-web:v1.7.6"))
+	})
+
+	ginkgo.It("should leave fields managed by others", func() {
+		spec := riotv1alpha1.RiotSpec{
+			Replicas: 1,
+		}
+		instance := initFakeRiot(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns)
+
+		// Replicas are scaled by an autoscaler
+		deployment := getDeployment(t, instance, cl, ns)
+		replicas := int32(3)
+		deployment.Spec.Replicas = &replicas
+		err := cl.Update(context.TODO(), deployment)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		cl.(*applyfake.Client).ForeignFields["Deployment/"+deployment.Name] = []string{".spec.replicas"}
+		reconcileRiot(t, cl, name, ns)
+
+		deployment = getDeployment(t, instance, cl, ns)
+		g.Expect(deployment.Spec.Replicas).To(g.Equal(&replicas))
+		found := &riotv1alpha1.Riot{}
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: ns}, found)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(found.Status.Conditions.IsTrueFor(riotv1alpha1.RiotConditionFieldConflict)).To(g.BeTrue())
+		condition := found.Status.Conditions.GetCondition(riotv1alpha1.RiotConditionFieldConflict)
+		g.Expect(condition.Message).To(g.ContainSubstring(".spec.replicas"))
+
+		// Conflict is cleared once the field is no longer managed by others
+		delete(cl.(*applyfake.Client).ForeignFields, "Deployment/"+deployment.Name)
+		reconcileRiot(t, cl, name, ns)
+		deployment = getDeployment(t, instance, cl, ns)
+		g.Expect(*deployment.Spec.Replicas).To(g.Equal(int32(1)))
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: ns}, found)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(found.Status.Conditions.IsFalseFor(riotv1alpha1.RiotConditionFieldConflict)).To(g.BeTrue())
+	})
+
+	ginkgo.It("should create ingress", func() {
+		spec := riotv1alpha1.RiotSpec{
+			Ingress: &riotv1alpha1.RiotIngress{
+				Host:        "riot.foo.bar",
+				Annotations: map[string]string{"foo": "bar"},
+			},
+		}
+		instance := initFakeRiot(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns)
+		ingress := &networkingv1beta1.Ingress{}
+		err := cl.Get(context.TODO(), types.NamespacedName{Name: instance.GetIngressName(), Namespace: ns}, ingress)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(ingress.Annotations).To(g.Equal(map[string]string{"foo": "bar"}))
+		g.Expect(ingress.Spec.TLS).To(g.Equal([]networkingv1beta1.IngressTLS{
+			{Hosts: []string{"riot.foo.bar"}},
+		}))
+		g.Expect(ingress.Spec.Rules).To(g.HaveLen(1))
+		g.Expect(ingress.Spec.Rules[0].Host).To(g.Equal("riot.foo.bar"))
+		g.Expect(ingress.Spec.Rules[0].HTTP.Paths).To(g.Equal([]networkingv1beta1.HTTPIngressPath{
+			{
+				Path: "/",
+				Backend: networkingv1beta1.IngressBackend{
+					ServiceName: instance.GetServiceName(),
+					ServicePort: intstr.FromString("http"),
+				},
+			},
+		}))
+	})
+
+	ginkgo.It("should update route certificate when TLS secret changes", func() {
+		tlsSecret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "riot-tls", Namespace: ns},
+			Data: map[string][]byte{
+				corev1.TLSCertKey:       []byte("foo"),
+				corev1.TLSPrivateKeyKey: []byte("bar"),
+			},
+		}
+		spec := riotv1alpha1.RiotSpec{
+			Ingress: &riotv1alpha1.RiotIngress{
+				Host:          "riot.foo.bar",
+				TLSSecretName: "riot-tls",
+			},
+		}
+		instance := initFakeRiot(t, name, ns, &spec)
+		cl = newFakeClient(t, instance, tlsSecret)
+		r := &ReconcileRiot{client: cl, scheme: scheme.Scheme, applier: apply.NewApplier(cl, scheme.Scheme), routesAvailable: true}
+		req := reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: ns}}
+		_, err := r.Reconcile(req)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(getRouteCertificate(t, instance, cl, ns)).To(g.Equal("foo"))
+
+		requests := getRiotsReferencingTLSSecret(cl, handler.MapObject{Meta: tlsSecret, Object: tlsSecret})
+		g.Expect(requests).To(g.Equal([]reconcile.Request{req}))
+		other := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: ns}}
+		g.Expect(getRiotsReferencingTLSSecret(cl, handler.MapObject{Meta: other, Object: other})).To(g.BeEmpty())
+
+		// Rotated certificate is copied to the route
+		tlsSecret.Data[corev1.TLSCertKey] = []byte("baz")
+		err = cl.Update(context.TODO(), tlsSecret)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		_, err = r.Reconcile(req)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(getRouteCertificate(t, instance, cl, ns)).To(g.Equal("baz"))
+
+		// Route is removed once ingress is disabled
+		err = cl.Get(context.TODO(), req.NamespacedName, instance)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		instance.Spec.Ingress = nil
+		err = cl.Update(context.TODO(), instance)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		_, err = r.Reconcile(req)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		route := &unstructured.Unstructured{}
+		route.SetGroupVersionKind(exposure.RouteGVK)
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: instance.GetIngressName(), Namespace: ns}, route)
+		g.Expect(errors.IsNotFound(err)).To(g.BeTrue())
+	})
+
+	ginkgo.It("should report status", func() {
+		spec := riotv1alpha1.RiotSpec{
+			Replicas: 1,
+			Image:    riotv1alpha1.DefaultImage,
+			Config:   `{"brand": "Riot"}`,
+			Ingress: &riotv1alpha1.RiotIngress{
+				Host: "riot.foo.bar",
+			},
+		}
+		instance := initFakeRiot(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns)
+		found := &riotv1alpha1.Riot{}
+		err := cl.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: ns}, found)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(found.Status.Version).To(g.Equal("v1.7.5"))
+		g.Expect(found.Status.Replicas).To(g.Equal(int32(1)))
+		g.Expect(found.Status.ReadyReplicas).To(g.Equal(int32(0)))
+		g.Expect(found.Status.Service).To(g.Equal("example-riot-service.synapse.svc"))
+		g.Expect(found.Status.URL).To(g.Equal("https://riot.foo.bar/"))
+		g.Expect(found.Status.Conditions.IsFalseFor(riotv1alpha1.RiotConditionAvailable)).To(g.BeTrue())
+		g.Expect(found.Status.Conditions.IsTrueFor(riotv1alpha1.RiotConditionConfigValid)).To(g.BeTrue())
+		config := getConfigMap(t, instance, cl, ns).Data["config.json"]
+
+		// Invalid config is reported and never reaches the configmap
+		found.Spec.Config = `{"brand": "Riot",}`
+		err = cl.Update(context.TODO(), found)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		r := &ReconcileRiot{client: cl, scheme: scheme.Scheme, applier: apply.NewApplier(cl, scheme.Scheme)}
+		_, err = r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: ns}})
+		g.Expect(err).To(g.HaveOccurred())
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: ns}, found)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(found.Status.Conditions.IsFalseFor(riotv1alpha1.RiotConditionConfigValid)).To(g.BeTrue())
+		g.Expect(getConfigMap(t, instance, cl, ns).Data["config.json"]).To(g.Equal(config))
+
+		found.Spec.Config = `{"brand": "Riot"}`
+		err = cl.Update(context.TODO(), found)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		deployment := getDeployment(t, instance, cl, ns)
+		deployment.Status.ReadyReplicas = 1
+		deployment.Status.AvailableReplicas = 1
+		err = cl.Update(context.TODO(), deployment)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		reconcileRiot(t, cl, name, ns)
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: ns}, found)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(found.Status.ReadyReplicas).To(g.Equal(int32(1)))
+		g.Expect(found.Status.Conditions.IsTrueFor(riotv1alpha1.RiotConditionAvailable)).To(g.BeTrue())
+		g.Expect(found.Status.Conditions.IsTrueFor(riotv1alpha1.RiotConditionConfigValid)).To(g.BeTrue())
+	})
+
+	ginkgo.It("should report invalid config merged with typed settings", func() {
+		spec := riotv1alpha1.RiotSpec{
+			Replicas:   1,
+			SynapseRef: &corev1.LocalObjectReference{Name: "example-synapse"},
+			Brand:      "Element",
+			Config:     `{"brand": "Riot",}`,
+		}
+		instance := initFakeRiot(t, name, ns, &spec)
+		cl = newFakeClient(t, instance, initFakeSynapse(t, "example-synapse", ns))
+		r := &ReconcileRiot{client: cl, scheme: scheme.Scheme, applier: apply.NewApplier(cl, scheme.Scheme)}
+		_, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: ns}})
+		g.Expect(err).To(g.HaveOccurred())
+
+		found := &riotv1alpha1.Riot{}
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: ns}, found)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(found.Status.Conditions.IsFalseFor(riotv1alpha1.RiotConditionConfigValid)).To(g.BeTrue())
+		g.Expect(found.Status.Conditions.IsFalseFor(riotv1alpha1.RiotConditionSynapseNotFound)).To(g.BeTrue())
+
+		// Status is updated once config is fixed
+		found.Spec.Config = `{"brand": "Riot"}`
+		err = cl.Update(context.TODO(), found)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		reconcileRiot(t, cl, name, ns)
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: ns}, found)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(found.Status.Conditions.IsTrueFor(riotv1alpha1.RiotConditionConfigValid)).To(g.BeTrue())
+	})
+
+	ginkgo.It("should generate config from referenced Synapse", func() {
+		spec := riotv1alpha1.RiotSpec{
+			SynapseRef:           &corev1.LocalObjectReference{Name: "example-synapse"},
+			Brand:                "Element",
+			DefaultTheme:         "dark",
+			Features:             map[string]string{"feature_pinning": "labs"},
+			RoomDirectoryServers: []string{"foo.bar", "matrix.org"},
+			Jitsi:                &riotv1alpha1.RiotJitsi{PreferredDomain: "jitsi.foo.bar"},
+			Integrations: &riotv1alpha1.RiotIntegrations{
+				UIURL:   "https://scalar.vector.im/",
+				RestURL: "https://scalar.vector.im/api",
+			},
+			Config: `{"brand": "Riot", "default_server_config": {"m.identity_server": {"base_url": "https://vector.im"}}, "jitsi": null}`,
+		}
+		instance := initFakeRiot(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns, initFakeSynapse(t, "example-synapse", ns))
+		cm := getConfigMap(t, instance, cl, ns)
+		config := map[string]interface{}{}
+		g.Expect(json.Unmarshal([]byte(cm.Data["config.json"]), &config)).To(g.Succeed())
+		g.Expect(config).To(g.Equal(map[string]interface{}{
+			"default_server_config": map[string]interface{}{
+				"m.homeserver": map[string]interface{}{
+					"base_url":    "https://matrix.foo.bar",
+					"server_name": "foo.bar",
+				},
+				"m.identity_server": map[string]interface{}{
+					"base_url": "https://vector.im",
+				},
+			},
+			"brand":         "Riot",
+			"default_theme": "dark",
+			"features": map[string]interface{}{
+				"feature_pinning": "labs",
+			},
+			"roomDirectory": map[string]interface{}{
+				"servers": []interface{}{"foo.bar", "matrix.org"},
+			},
+			"integrations_ui_url":   "https://scalar.vector.im/",
+			"integrations_rest_url": "https://scalar.vector.im/api",
+		}))
+
+		found := &riotv1alpha1.Riot{}
+		err := cl.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: ns}, found)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(found.Status.Conditions.IsFalseFor(riotv1alpha1.RiotConditionSynapseNotFound)).To(g.BeTrue())
+	})
+
+	ginkgo.It("should wait for referenced Synapse", func() {
+		spec := riotv1alpha1.RiotSpec{
+			SynapseRef: &corev1.LocalObjectReference{Name: "example-synapse"},
+		}
+		instance := initFakeRiot(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns)
+		cm := &corev1.ConfigMap{}
+		err := cl.Get(context.TODO(), types.NamespacedName{Name: instance.GetConfigMapName(), Namespace: ns}, cm)
+		g.Expect(errors.IsNotFound(err)).To(g.BeTrue())
+		found := &riotv1alpha1.Riot{}
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: ns}, found)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(found.Status.Conditions.IsTrueFor(riotv1alpha1.RiotConditionSynapseNotFound)).To(g.BeTrue())
+
+		err = cl.Create(context.TODO(), initFakeSynapse(t, "example-synapse", ns))
+		g.Expect(err).NotTo(g.HaveOccurred())
+		reconcileRiot(t, cl, name, ns)
+		cm = getConfigMap(t, instance, cl, ns)
+		g.Expect(cm.Data["config.json"]).To(g.ContainSubstring(`"base_url": "https://matrix.foo.bar"`))
+	})
 })
@@ -0,0 +1,97 @@
+package riot
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	riotv1alpha1 "github.com/vrutkovs/synapse-operator/pkg/apis/riot/v1alpha1"
+	"github.com/vrutkovs/synapse-operator/pkg/controller/apply"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// updateStatus records deployed version, replica readiness, addresses, config validity and
+// whether referenced Synapse was found in Riot status
+func (r *ReconcileRiot) updateStatus(instance *riotv1alpha1.Riot, synapseFound bool, reqLogger logr.Logger) error {
+	newStatus := instance.Status.DeepCopy()
+	newStatus.ObservedGeneration = instance.Generation
+
+	// Fetch deployment to find out deployed image and how many replicas are ready
+	found := &appsv1.Deployment{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: instance.GetDeploymentName(), Namespace: instance.Namespace}, found)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	deploymentFound := err == nil
+	newStatus.Version = ""
+	if deploymentFound && len(found.Spec.Template.Spec.Containers) > 0 {
+		newStatus.Version = riotv1alpha1.GetImageTag(found.Spec.Template.Spec.Containers[0].Image)
+	}
+	newStatus.Replicas = int32(instance.Spec.Replicas)
+	newStatus.ReadyReplicas = found.Status.ReadyReplicas
+	newStatus.Service = instance.GetServiceAddress()
+	newStatus.URL = instance.GetURL()
+
+	if deploymentFound && found.Status.AvailableReplicas >= newStatus.Replicas {
+		newStatus.Conditions.SetCondition(status.Condition{
+			Type:    riotv1alpha1.RiotConditionAvailable,
+			Status:  corev1.ConditionTrue,
+			Reason:  "DeploymentAvailable",
+			Message: "All Riot replicas are available",
+		})
+	} else {
+		newStatus.Conditions.SetCondition(status.Condition{
+			Type:    riotv1alpha1.RiotConditionAvailable,
+			Status:  corev1.ConditionFalse,
+			Reason:  "DeploymentNotAvailable",
+			Message: "Waiting for Riot replicas to become available",
+		})
+	}
+
+	newStatus.Conditions.SetCondition(apply.ConflictCondition(riotv1alpha1.RiotConditionFieldConflict, r.applier.Conflicts(instance)))
+
+	if instance.Spec.SynapseRef == nil {
+		newStatus.Conditions.RemoveCondition(riotv1alpha1.RiotConditionSynapseNotFound)
+	} else if !synapseFound {
+		newStatus.Conditions.SetCondition(status.Condition{
+			Type:    riotv1alpha1.RiotConditionSynapseNotFound,
+			Status:  corev1.ConditionTrue,
+			Reason:  "SynapseNotFound",
+			Message: "Referenced Synapse " + instance.Spec.SynapseRef.Name + " doesn't exist",
+		})
+	} else {
+		newStatus.Conditions.SetCondition(status.Condition{
+			Type:   riotv1alpha1.RiotConditionSynapseNotFound,
+			Status: corev1.ConditionFalse,
+			Reason: "SynapseFound",
+		})
+	}
+
+	if _, err := instance.ParseConfig(); err != nil {
+		newStatus.Conditions.SetCondition(status.Condition{
+			Type:    riotv1alpha1.RiotConditionConfigValid,
+			Status:  corev1.ConditionFalse,
+			Reason:  "InvalidJSON",
+			Message: err.Error(),
+		})
+	} else {
+		newStatus.Conditions.SetCondition(status.Condition{
+			Type:   riotv1alpha1.RiotConditionConfigValid,
+			Status: corev1.ConditionTrue,
+			Reason: "ValidJSON",
+		})
+	}
+
+	// Skip the update if nothing has changed to avoid reconcile loops
+	if reflect.DeepEqual(&instance.Status, newStatus) {
+		return nil
+	}
+	instance.Status = *newStatus
+	reqLogger.Info("Updating Riot status", "Version", newStatus.Version, "ReadyReplicas", newStatus.ReadyReplicas)
+	return r.client.Status().Update(context.TODO(), instance)
+}
@@ -0,0 +1,44 @@
+package riot
+
+import (
+	"context"
+
+	riotv1alpha1 "github.com/vrutkovs/synapse-operator/pkg/apis/riot/v1alpha1"
+	synapsev1alpha1 "github.com/vrutkovs/synapse-operator/pkg/apis/synapse/v1alpha1"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// getRiotsForSynapseObject maps Synapse to Riot instances referencing it
+func getRiotsForSynapseObject(c client.Client, a handler.MapObject) []reconcile.Request {
+	riots := &riotv1alpha1.RiotList{}
+	if err := c.List(context.TODO(), riots, client.InNamespace(a.Meta.GetNamespace())); err != nil {
+		log.Error(err, "Failed to list Riot instances", "Synapse.Namespace", a.Meta.GetNamespace(), "Synapse.Name", a.Meta.GetName())
+		return nil
+	}
+	requests := []reconcile.Request{}
+	for _, riot := range riots.Items {
+		if riot.Spec.SynapseRef != nil && riot.Spec.SynapseRef.Name == a.Meta.GetName() {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: riot.Name, Namespace: riot.Namespace},
+			})
+		}
+	}
+	return requests
+}
+
+// findReferencedSynapse returns Synapse referenced by Riot instance, nil is returned if it's not referenced
+func (r *ReconcileRiot) findReferencedSynapse(instance *riotv1alpha1.Riot) (*synapsev1alpha1.Synapse, error) {
+	if instance.Spec.SynapseRef == nil {
+		return nil, nil
+	}
+	synapse := &synapsev1alpha1.Synapse{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: instance.Spec.SynapseRef.Name, Namespace: instance.Namespace}, synapse)
+	if err != nil {
+		return nil, err
+	}
+	return synapse, nil
+}
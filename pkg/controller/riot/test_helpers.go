@@ -7,6 +7,7 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/kubectl/pkg/scheme"
@@ -15,6 +16,10 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	riotv1alpha1 "github.com/vrutkovs/synapse-operator/pkg/apis/riot/v1alpha1"
+	synapsev1alpha1 "github.com/vrutkovs/synapse-operator/pkg/apis/synapse/v1alpha1"
+	"github.com/vrutkovs/synapse-operator/pkg/controller/apply"
+	applyfake "github.com/vrutkovs/synapse-operator/pkg/controller/apply/fake"
+	"github.com/vrutkovs/synapse-operator/pkg/controller/exposure"
 
 	g "github.com/onsi/gomega"
 )
@@ -29,14 +34,38 @@ func initFakeRiot(t *testing.T, name, ns string, spec *riotv1alpha1.RiotSpec) *r
 	}
 }
 
-func initFakeClient(t *testing.T, riot *riotv1alpha1.Riot, name, ns string) client.Client {
-	objs := []runtime.Object{riot}
+func initFakeSynapse(t *testing.T, name, ns string) *synapsev1alpha1.Synapse {
+	return &synapsev1alpha1.Synapse{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ns,
+		},
+		Spec: synapsev1alpha1.SynapseSpec{
+			ServerName: "foo.bar",
+			Ingress: &synapsev1alpha1.SynapseIngress{
+				Host: "matrix.foo.bar",
+			},
+		},
+	}
+}
+
+func initFakeClient(t *testing.T, riot *riotv1alpha1.Riot, name, ns string, extraObjs ...runtime.Object) client.Client {
+	cl := newFakeClient(t, riot, extraObjs...)
+	reconcileRiot(t, cl, name, ns)
+	return cl
+}
+
+// newFakeClient returns a client with the objects, which haven't been reconciled yet
+func newFakeClient(t *testing.T, riot *riotv1alpha1.Riot, extraObjs ...runtime.Object) client.Client {
+	objs := append([]runtime.Object{riot}, extraObjs...)
 	s := scheme.Scheme
-	s.AddKnownTypes(riotv1alpha1.SchemeGroupVersion, objs...)
+	s.AddKnownTypes(riotv1alpha1.SchemeGroupVersion, &riotv1alpha1.Riot{}, &riotv1alpha1.RiotList{})
+	s.AddKnownTypes(synapsev1alpha1.SchemeGroupVersion, &synapsev1alpha1.Synapse{}, &synapsev1alpha1.SynapseList{})
+	return applyfake.NewClient(fake.NewFakeClientWithScheme(s, objs...), s)
+}
 
-	// Reconcile
-	cl := fake.NewFakeClientWithScheme(s, objs...)
-	r := &ReconcileRiot{client: cl, scheme: s}
+func reconcileRiot(t *testing.T, cl client.Client, name, ns string) {
+	r := &ReconcileRiot{client: cl, scheme: scheme.Scheme, applier: apply.NewApplier(cl, scheme.Scheme)}
 	req := reconcile.Request{
 		NamespacedName: types.NamespacedName{
 			Name:      name,
@@ -46,7 +75,6 @@ func initFakeClient(t *testing.T, riot *riotv1alpha1.Riot, name, ns string) clie
 	res, err := r.Reconcile(req)
 	g.Expect(err).NotTo(g.HaveOccurred(), "failed to reconcile")
 	g.Expect(res).To(g.Equal(reconcile.Result{}), "reconcile did not return an empty Result")
-	return cl
 }
 
 func getConfigMap(t *testing.T, riot *riotv1alpha1.Riot, cl client.Client, ns string) *corev1.ConfigMap {
@@ -69,3 +97,13 @@ func getDeployment(t *testing.T, riot *riotv1alpha1.Riot, cl client.Client, ns s
 	g.Expect(err).NotTo(g.HaveOccurred(), "failed to get deployment")
 	return dep
 }
+
+func getRouteCertificate(t *testing.T, riot *riotv1alpha1.Riot, cl client.Client, ns string) string {
+	route := &unstructured.Unstructured{}
+	route.SetGroupVersionKind(exposure.RouteGVK)
+	err := cl.Get(context.TODO(), types.NamespacedName{Name: riot.GetIngressName(), Namespace: ns}, route)
+	g.Expect(err).NotTo(g.HaveOccurred(), "failed to get route")
+	certificate, _, err := unstructured.NestedString(route.Object, "spec", "tls", "certificate")
+	g.Expect(err).NotTo(g.HaveOccurred())
+	return certificate
+}
@@ -0,0 +1,104 @@
+package rollout
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"sort"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ConfigHashAnnotation is a pod template annotation with a hash of mounted configmaps and secrets.
+// Pods are rolled out when it changes
+const ConfigHashAnnotation = "synapse-operator/config-hash"
+
+// SynapseConfigHashAnnotation is a worker pod template annotation with a hash of Synapse config and secret.
+// Workers are rolled out when it changes and Synapse has finished its own rollout
+const SynapseConfigHashAnnotation = "synapse-operator/synapse-config-hash"
+
+// SynapseGenerationAnnotation is a worker configmap annotation with a generation of Synapse
+// the config was rendered from
+const SynapseGenerationAnnotation = "synapse-operator/synapse-generation"
+
+// ConfigHash returns SHA-256 of configmaps and secrets data. Keys are sorted, so the hash is stable
+func ConfigHash(configMaps []*corev1.ConfigMap, secrets []*corev1.Secret) string {
+	h := sha256.New()
+	for _, cm := range configMaps {
+		writeString(h, "configmap/"+cm.Name)
+		keys := make([]string, 0, len(cm.Data))
+		for key := range cm.Data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			writeString(h, key)
+			writeString(h, cm.Data[key])
+		}
+	}
+	for _, secret := range secrets {
+		writeString(h, "secret/"+secret.Name)
+		keys := make([]string, 0, len(secret.Data))
+		for key := range secret.Data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			writeString(h, key)
+			writeString(h, string(secret.Data[key]))
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeString writes a null-terminated string, so that adjacent values can't be confused
+func writeString(h hash.Hash, s string) {
+	h.Write([]byte(s))
+	h.Write([]byte{0})
+}
+
+// GetConfigHash fetches configmaps and secrets and returns hash of their data
+func GetConfigHash(c client.Client, namespace string, configMapNames, secretNames []string) (string, error) {
+	configMaps := []*corev1.ConfigMap{}
+	for _, name := range configMapNames {
+		cm := &corev1.ConfigMap{}
+		if err := c.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: namespace}, cm); err != nil {
+			return "", err
+		}
+		configMaps = append(configMaps, cm)
+	}
+	secrets := []*corev1.Secret{}
+	for _, name := range secretNames {
+		secret := &corev1.Secret{}
+		if err := c.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: namespace}, secret); err != nil {
+			return "", err
+		}
+		secrets = append(secrets, secret)
+	}
+	return ConfigHash(configMaps, secrets), nil
+}
+
+// SetConfigHash sets config hash annotation on the pod template
+func SetConfigHash(template *corev1.PodTemplateSpec, configHash string) {
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[ConfigHashAnnotation] = configHash
+}
+
+// IsComplete returns true if all deployment replicas are updated and available
+func IsComplete(deployment *appsv1.Deployment) bool {
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	status := deployment.Status
+	return status.ObservedGeneration >= deployment.Generation &&
+		status.UpdatedReplicas == replicas &&
+		status.AvailableReplicas == replicas &&
+		status.Replicas == replicas
+}
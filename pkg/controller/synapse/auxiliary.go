@@ -0,0 +1,187 @@
+package synapse
+
+import (
+	"reflect"
+
+	"github.com/go-logr/logr"
+	synapsev1alpha1 "github.com/vrutkovs/synapse-operator/pkg/apis/synapse/v1alpha1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// reconcileAuxiliaryConfigMap creates or updates configmap of auxiliary component, e.g. reverse proxy
+func (r *ReconcileSynapse) reconcileAuxiliaryConfigMap(instance *synapsev1alpha1.Synapse, configMap *corev1.ConfigMap, reqLogger logr.Logger) (reconcile.Result, error) {
+	// Set Synapse instance as the owner and controller
+	if err := controllerutil.SetControllerReference(instance, configMap, r.scheme); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	reqLogger.Info("Applying ConfigMap", "ConfigMap.Namespace", configMap.Namespace, "ConfigMap.Name", configMap.Name)
+	if err := r.applier.Apply(instance, configMap); err != nil {
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{}, nil
+}
+
+// reconcileAuxiliaryDeployment creates or updates deployment of auxiliary component
+func (r *ReconcileSynapse) reconcileAuxiliaryDeployment(instance *synapsev1alpha1.Synapse, deployment *appsv1.Deployment, reqLogger logr.Logger) (reconcile.Result, error) {
+	// Set Synapse instance as the owner and controller
+	if err := controllerutil.SetControllerReference(instance, deployment, r.scheme); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	reqLogger.Info("Applying Deployment", "Deployment.Namespace", deployment.Namespace, "Deployment.Name", deployment.Name)
+	if err := r.applier.Apply(instance, deployment); err != nil {
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{}, nil
+}
+
+// reconcileAuxiliaryService creates or updates service of auxiliary component
+func (r *ReconcileSynapse) reconcileAuxiliaryService(instance *synapsev1alpha1.Synapse, service *corev1.Service, reqLogger logr.Logger) (reconcile.Result, error) {
+	// Set Synapse instance as the owner and controller
+	if err := controllerutil.SetControllerReference(instance, service, r.scheme); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	reqLogger.Info("Applying Service", "Service.Namespace", service.Namespace, "Service.Name", service.Name)
+	if err := r.applier.Apply(instance, service); err != nil {
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{}, nil
+}
+
+// deleteAuxiliaryObjects removes configmap, deployment and service of auxiliary component once it's disabled
+func (r *ReconcileSynapse) deleteAuxiliaryObjects(instance *synapsev1alpha1.Synapse, name string, reqLogger logr.Logger) error {
+	objectMeta := metav1.ObjectMeta{Name: name, Namespace: instance.Namespace}
+	objs := []runtime.Object{
+		&appsv1.Deployment{ObjectMeta: objectMeta},
+		&corev1.Service{ObjectMeta: objectMeta},
+		&corev1.ConfigMap{ObjectMeta: objectMeta},
+	}
+	for _, obj := range objs {
+		deleted, err := r.applier.Delete(instance, obj)
+		if err != nil {
+			return err
+		}
+		if deleted {
+			reqLogger.Info("Deleted "+reflect.TypeOf(obj).Elem().Name(), "Namespace", instance.Namespace, "Name", name)
+		}
+	}
+	return nil
+}
+
+// nginxPort is a port nginx listens on in auxiliary deployments
+const nginxPort = 8080
+
+func getAuxiliaryLabels(name string) map[string]string {
+	return map[string]string{
+		"app": name,
+	}
+}
+
+// newConfigMapVolume returns a volume with configmap contents, mode is set explicitly to match apiserver defaults
+func newConfigMapVolume(name, configMapName string, items []corev1.KeyToPath) corev1.Volume {
+	mode := int32(420)
+	return corev1.Volume{
+		Name: name,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: configMapName},
+				Items:                items,
+				DefaultMode:          &mode,
+			},
+		},
+	}
+}
+
+// newNginxDeployment returns nginx deployment for auxiliary component. Nginx config is expected
+// to serve /healthz on nginxPort
+func newNginxDeployment(cr *synapsev1alpha1.Synapse, name, image string, replicas int32, volumes []corev1.Volume, volumeMounts []corev1.VolumeMount) *appsv1.Deployment {
+	if image == "" {
+		image = synapsev1alpha1.DefaultProxyImage
+	}
+	probe := &corev1.Probe{
+		Handler: corev1.Handler{
+			HTTPGet: &corev1.HTTPGetAction{
+				Path:   "/healthz",
+				Port:   intstr.FromString("http"),
+				Scheme: "HTTP",
+			},
+		},
+	}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: cr.Namespace,
+			Labels:    getAuxiliaryLabels(name),
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: getAuxiliaryLabels(name),
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: getAuxiliaryLabels(name),
+				},
+				Spec: corev1.PodSpec{
+					Volumes: volumes,
+					Containers: []corev1.Container{
+						{
+							Name:  "nginx",
+							Image: image,
+							Ports: []corev1.ContainerPort{
+								{
+									Name:          "http",
+									ContainerPort: nginxPort,
+									Protocol:      corev1.ProtocolTCP,
+								},
+							},
+							ReadinessProbe: probe,
+							LivenessProbe:  probe,
+							VolumeMounts:   volumeMounts,
+						},
+					},
+				},
+			},
+		},
+	}
+	applyAuxiliaryPodTemplate(cr, &deployment.Spec.Template.Spec)
+	return deployment
+}
+
+// applyAuxiliaryPodTemplate schedules proxy, well-known responder and Redis pods like the homeserver.
+// Resources and security settings are meant for the homeserver image, so these are not applied
+func applyAuxiliaryPodTemplate(cr *synapsev1alpha1.Synapse, spec *corev1.PodSpec) {
+	cr.Spec.PodTemplate.ApplyScheduling(spec)
+}
+
+// newAuxiliaryService returns a service for auxiliary deployment
+func newAuxiliaryService(cr *synapsev1alpha1.Synapse, name string) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: cr.Namespace,
+			Labels:    getAuxiliaryLabels(name),
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: getAuxiliaryLabels(name),
+			Type:     corev1.ServiceTypeClusterIP,
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "http",
+					Protocol:   corev1.ProtocolTCP,
+					TargetPort: intstr.FromString("http"),
+					Port:       80,
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,55 @@
+package synapse
+
+import (
+	"github.com/go-logr/logr"
+	synapsev1alpha1 "github.com/vrutkovs/synapse-operator/pkg/apis/synapse/v1alpha1"
+	"github.com/vrutkovs/synapse-operator/pkg/controller/exposure"
+
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// ingressPaths are homeserver paths exposed via ingress
+var ingressPaths = []string{"/_matrix", "/_synapse/client"}
+
+func (r *ReconcileSynapse) reconcileIngress(request reconcile.Request, instance *synapsev1alpha1.Synapse, reqLogger logr.Logger) (reconcile.Result, error) {
+	if instance.Spec.Ingress == nil {
+		// Stop serving traffic once ingress is disabled
+		e := &exposure.Exposure{Name: instance.GetIngressName(), Namespace: instance.Namespace, Paths: ingressPaths}
+		return reconcile.Result{}, r.getExposureReconciler().Delete(instance, e, reqLogger)
+	}
+	if err := r.getExposureReconciler().Reconcile(instance, newExposureForCR(instance), reqLogger); err != nil {
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{}, nil
+}
+
+// getExposureReconciler returns reconciler of Ingress or Routes owned by Synapse
+func (r *ReconcileSynapse) getExposureReconciler() *exposure.Reconciler {
+	return &exposure.Reconciler{
+		Client:          r.client,
+		Scheme:          r.scheme,
+		Applier:         r.applier,
+		RoutesAvailable: r.routesAvailable,
+	}
+}
+
+// newExposure returns paths on the host routed to the service
+func newExposure(cr *synapsev1alpha1.Synapse, name string, ingressSpec *synapsev1alpha1.SynapseIngress, host string, paths []string, serviceName string) *exposure.Exposure {
+	return &exposure.Exposure{
+		Name:      name,
+		Namespace: cr.Namespace,
+		Labels: map[string]string{
+			"app": cr.Name,
+		},
+		Annotations:   ingressSpec.Annotations,
+		Host:          host,
+		Paths:         paths,
+		ServiceName:   serviceName,
+		TLSSecretName: ingressSpec.TLSSecretName,
+	}
+}
+
+// newExposureForCR returns homeserver paths routed to the homeserver or proxy service
+func newExposureForCR(cr *synapsev1alpha1.Synapse) *exposure.Exposure {
+	return newExposure(cr, cr.GetIngressName(), cr.Spec.Ingress, cr.GetIngressHost(), ingressPaths, cr.GetIngressServiceName())
+}
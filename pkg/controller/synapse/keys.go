@@ -0,0 +1,86 @@
+package synapse
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+const (
+	// Synapse generates signing key versions from ascii letters
+	keyVersionAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	keyVersionLength   = 4
+	sharedSecretLength = 48
+	// Self-signed certificate validity
+	certificateValidity = 10 * 365 * 24 * time.Hour
+)
+
+// generateSigningKey returns a new ed25519 signing key in Synapse format:
+// "ed25519 a_<version> <unpadded base64 of the seed>"
+func generateSigningKey() ([]byte, error) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	version := make([]byte, keyVersionLength)
+	for i := range version {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(keyVersionAlphabet))))
+		if err != nil {
+			return nil, err
+		}
+		version[i] = keyVersionAlphabet[n.Int64()]
+	}
+	seed := base64.RawStdEncoding.EncodeToString(privateKey.Seed())
+	return []byte(fmt.Sprintf("ed25519 a_%s %s", version, seed)), nil
+}
+
+// generateSharedSecret returns a random string suitable for macaroon, form and registration secrets
+func generateSharedSecret() ([]byte, error) {
+	secret := make([]byte, sharedSecretLength)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return []byte(base64.RawURLEncoding.EncodeToString(secret)), nil
+}
+
+// generateSelfSignedCertificate returns PEM-encoded certificate and key for serverName
+func generateSelfSignedCertificate(serverName string) ([]byte, []byte, error) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	notBefore := time.Now()
+	template := x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{CommonName: serverName},
+		DNSNames:              []string{serverName},
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.Add(certificateValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, &template, &template, &privateKey.PublicKey, privateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	key := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return cert, key, nil
+}
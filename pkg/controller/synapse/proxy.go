@@ -0,0 +1,104 @@
+package synapse
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/go-logr/logr"
+	synapsev1alpha1 "github.com/vrutkovs/synapse-operator/pkg/apis/synapse/v1alpha1"
+	"github.com/vrutkovs/synapse-operator/pkg/controller/rollout"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	proxyRoutingPath  = "/etc/nginx/routing"
+	proxyServerConfig = `resolver %s;
+include %s;
+
+server {
+    listen %d;
+    client_max_body_size 0;
+    proxy_http_version 1.1;
+    proxy_set_header Host $host;
+    proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
+
+    location = /healthz {
+        return 200;
+    }
+
+    include %s;
+}
+`
+)
+
+// reconcileProxy creates or updates reverse proxy configmap, deployment and service, these are removed
+// once proxy is disabled. Proxy pods are rolled out when either its config or worker routing changes
+func (r *ReconcileSynapse) reconcileProxy(request reconcile.Request, instance *synapsev1alpha1.Synapse, reqLogger logr.Logger) (reconcile.Result, error) {
+	if instance.Spec.Proxy == nil {
+		return reconcile.Result{}, r.deleteAuxiliaryObjects(instance, instance.GetProxyName(), reqLogger)
+	}
+
+	result, err := r.reconcileAuxiliaryConfigMap(instance, newProxyConfigMapForCR(instance), reqLogger)
+	if err != nil {
+		return result, err
+	}
+
+	configHash, err := rollout.GetConfigHash(r.client, instance.Namespace, []string{instance.GetProxyName(), instance.GetRoutingConfigMapName()}, nil)
+	if err != nil && errors.IsNotFound(err) {
+		// Cache has not seen just created configmaps yet - requeue
+		return reconcile.Result{Requeue: true}, nil
+	} else if err != nil {
+		return reconcile.Result{}, err
+	}
+	deployment := newProxyDeploymentForCR(instance)
+	rollout.SetConfigHash(&deployment.Spec.Template, configHash)
+	result, err = r.reconcileAuxiliaryDeployment(instance, deployment, reqLogger)
+	if err != nil {
+		return result, err
+	}
+
+	return r.reconcileAuxiliaryService(instance, newAuxiliaryService(instance, instance.GetProxyName()), reqLogger)
+}
+
+// newProxyConfigMapForCR returns nginx server config, which includes worker routing upstreams and locations
+func newProxyConfigMapForCR(cr *synapsev1alpha1.Synapse) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cr.GetProxyName(),
+			Namespace: cr.Namespace,
+			Labels:    getAuxiliaryLabels(cr.GetProxyName()),
+		},
+		Data: map[string]string{
+			"default.conf": fmt.Sprintf(proxyServerConfig, cr.GetProxyResolver(), path.Join(proxyRoutingPath, synapsev1alpha1.RoutingUpstreamsKey),
+				nginxPort, path.Join(proxyRoutingPath, synapsev1alpha1.RoutingConfigKey)),
+		},
+	}
+}
+
+// newProxyDeploymentForCR returns nginx deployment with server and routing configs mounted
+func newProxyDeploymentForCR(cr *synapsev1alpha1.Synapse) *appsv1.Deployment {
+	replicas := cr.Spec.Proxy.Replicas
+	if replicas == 0 {
+		replicas = 1
+	}
+	volumes := []corev1.Volume{
+		newConfigMapVolume("config", cr.GetProxyName(), nil),
+		newConfigMapVolume("routing", cr.GetRoutingConfigMapName(), nil),
+	}
+	volumeMounts := []corev1.VolumeMount{
+		{
+			Name:      "config",
+			MountPath: "/etc/nginx/conf.d",
+		},
+		{
+			Name:      "routing",
+			MountPath: proxyRoutingPath,
+		},
+	}
+	return newNginxDeployment(cr, cr.GetProxyName(), cr.Spec.Proxy.Image, replicas, volumes, volumeMounts)
+}
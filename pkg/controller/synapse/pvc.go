@@ -0,0 +1,92 @@
+package synapse
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	synapsev1alpha1 "github.com/vrutkovs/synapse-operator/pkg/apis/synapse/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// reconcilePVC creates or updates media store claim. The claim is expanded only if expansion is allowed
+// in the CR, as its storage class may not support it
+func (r *ReconcileSynapse) reconcilePVC(request reconcile.Request, instance *synapsev1alpha1.Synapse, reqLogger logr.Logger) (reconcile.Result, error) {
+	if instance.Spec.Storage == nil {
+		return reconcile.Result{}, nil
+	}
+
+	pvc := newPVCForCR(instance)
+	found := &corev1.PersistentVolumeClaim{}
+	expanding := false
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: pvc.Name, Namespace: pvc.Namespace}, found)
+	if err != nil && !errors.IsNotFound(err) {
+		return reconcile.Result{}, err
+	} else if err == nil {
+		// Claims can only be expanded, other fields are immutable. These are applied as they are,
+		// so that spec changes don't make apply fail
+		pvc.Spec.AccessModes = found.Spec.AccessModes
+		pvc.Spec.StorageClassName = found.Spec.StorageClassName
+		actualSize := found.Spec.Resources.Requests[corev1.ResourceStorage]
+		switch expectedSize := instance.Spec.Storage.Size; {
+		case expectedSize.Cmp(actualSize) < 0:
+			reqLogger.Info("PersistentVolumeClaim cannot be shrunk", "PVC.Namespace", found.Namespace, "PVC.Name", found.Name, "actual", actualSize.String(), "expected", expectedSize.String())
+			pvc.Spec.Resources.Requests[corev1.ResourceStorage] = actualSize
+		case expectedSize.Cmp(actualSize) > 0 && !instance.Spec.Storage.AllowExpansion:
+			reqLogger.Info("PersistentVolumeClaim expansion is not allowed", "PVC.Namespace", found.Namespace, "PVC.Name", found.Name, "actual", actualSize.String(), "expected", expectedSize.String())
+			pvc.Spec.Resources.Requests[corev1.ResourceStorage] = actualSize
+		case expectedSize.Cmp(actualSize) > 0:
+			expanding = true
+		}
+	}
+
+	// Synapse is the controller of the claim when deletion policy is Delete, so that media store
+	// is garbage collected with Synapse. Otherwise the reference is not applied and gets removed
+	if instance.Spec.Storage.DeletionPolicy == synapsev1alpha1.StorageDeletionPolicyDelete {
+		if err := controllerutil.SetControllerReference(instance, pvc, r.scheme); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	reqLogger.Info("Applying PersistentVolumeClaim", "PVC.Namespace", pvc.Namespace, "PVC.Name", pvc.Name, "DeletionPolicy", instance.Spec.Storage.DeletionPolicy)
+	if err := r.applier.Apply(instance, pvc); err != nil {
+		if expanding {
+			return reconcile.Result{}, fmt.Errorf("failed to expand claim to %s, storage class of the claim must allow volume expansion: %w", instance.Spec.Storage.Size.String(), err)
+		}
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{}, nil
+}
+
+// newPVCForCR returns a media store persistent volume claim for the cr
+func newPVCForCR(cr *synapsev1alpha1.Synapse) *corev1.PersistentVolumeClaim {
+	labels := map[string]string{
+		"app": cr.Name,
+	}
+	accessModes := cr.Spec.Storage.AccessModes
+	if len(accessModes) == 0 {
+		accessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+	}
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cr.GetMediaStorePVCName(),
+			Namespace: cr.Namespace,
+			Labels:    labels,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      accessModes,
+			StorageClassName: cr.Spec.Storage.StorageClassName,
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: cr.Spec.Storage.Size,
+				},
+			},
+		},
+	}
+}
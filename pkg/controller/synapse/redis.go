@@ -0,0 +1,108 @@
+package synapse
+
+import (
+	"strconv"
+
+	"github.com/go-logr/logr"
+	synapsev1alpha1 "github.com/vrutkovs/synapse-operator/pkg/apis/synapse/v1alpha1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// reconcileRedis creates or updates single-instance Redis deployment and service
+// if Redis is enabled and external host is not set, and removes them otherwise
+func (r *ReconcileSynapse) reconcileRedis(request reconcile.Request, instance *synapsev1alpha1.Synapse, reqLogger logr.Logger) (reconcile.Result, error) {
+	if !instance.IsRedisManaged() {
+		return reconcile.Result{}, r.deleteAuxiliaryObjects(instance, instance.GetRedisName(), reqLogger)
+	}
+
+	result, err := r.reconcileAuxiliaryDeployment(instance, newRedisDeploymentForCR(instance), reqLogger)
+	if err != nil {
+		return result, err
+	}
+
+	return r.reconcileAuxiliaryService(instance, newRedisServiceForCR(instance), reqLogger)
+}
+
+// newRedisDeploymentForCR returns Redis deployment. Redis is only used as a replication stream,
+// so data is not persisted
+func newRedisDeploymentForCR(cr *synapsev1alpha1.Synapse) *appsv1.Deployment {
+	name := cr.GetRedisName()
+	replicas := int32(1)
+	probe := &corev1.Probe{
+		Handler: corev1.Handler{
+			TCPSocket: &corev1.TCPSocketAction{
+				Port: intstr.FromString("redis"),
+			},
+		},
+	}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: cr.Namespace,
+			Labels:    getAuxiliaryLabels(name),
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: getAuxiliaryLabels(name),
+			},
+			Strategy: appsv1.DeploymentStrategy{
+				Type: appsv1.RecreateDeploymentStrategyType,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: getAuxiliaryLabels(name),
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:  "redis",
+							Image: cr.GetRedisImage(),
+							Args:  []string{"--port", strconv.Itoa(cr.GetRedisPort()), "--save", "", "--appendonly", "no"},
+							Ports: []corev1.ContainerPort{
+								{
+									Name:          "redis",
+									ContainerPort: int32(cr.GetRedisPort()),
+									Protocol:      corev1.ProtocolTCP,
+								},
+							},
+							ReadinessProbe: probe,
+							LivenessProbe:  probe,
+						},
+					},
+				},
+			},
+		},
+	}
+	applyAuxiliaryPodTemplate(cr, &deployment.Spec.Template.Spec)
+	return deployment
+}
+
+// newRedisServiceForCR returns a service for Redis deployment
+func newRedisServiceForCR(cr *synapsev1alpha1.Synapse) *corev1.Service {
+	name := cr.GetRedisName()
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: cr.Namespace,
+			Labels:    getAuxiliaryLabels(name),
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: getAuxiliaryLabels(name),
+			Type:     corev1.ServiceTypeClusterIP,
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "redis",
+					Protocol:   corev1.ProtocolTCP,
+					TargetPort: intstr.FromString("redis"),
+					Port:       int32(cr.GetRedisPort()),
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,77 @@
+package synapse
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	synapsev1alpha1 "github.com/vrutkovs/synapse-operator/pkg/apis/synapse/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// reconcileRoutingConfigMap keeps worker routing config in sync with SynapseWorkers referencing the instance
+func (r *ReconcileSynapse) reconcileRoutingConfigMap(request reconcile.Request, instance *synapsev1alpha1.Synapse, reqLogger logr.Logger) (reconcile.Result, error) {
+	workers, err := r.getWorkers(instance)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	configMap := newRoutingConfigMapForCR(instance, workers)
+
+	// Set Synapse instance as the owner and controller
+	if err := controllerutil.SetControllerReference(instance, configMap, r.scheme); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	reqLogger.Info("Applying routing ConfigMap", "ConfigMap.Namespace", configMap.Namespace, "ConfigMap.Name", configMap.Name)
+	if err := r.applier.Apply(instance, configMap); err != nil {
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{}, nil
+}
+
+// getWorkers returns SynapseWorkers referencing the instance
+func (r *ReconcileSynapse) getWorkers(instance *synapsev1alpha1.Synapse) ([]synapsev1alpha1.SynapseWorker, error) {
+	workerList := &synapsev1alpha1.SynapseWorkerList{}
+	if err := r.client.List(context.TODO(), workerList, client.InNamespace(instance.Namespace)); err != nil {
+		return nil, err
+	}
+	workers := []synapsev1alpha1.SynapseWorker{}
+	for _, worker := range workerList.Items {
+		if worker.Spec.Synapse == instance.Name {
+			workers = append(workers, worker)
+		}
+	}
+	return workers, nil
+}
+
+// getReferencedSynapse maps a SynapseWorker to its Synapse instance
+func getReferencedSynapse(a handler.MapObject) []reconcile.Request {
+	worker, ok := a.Object.(*synapsev1alpha1.SynapseWorker)
+	if !ok || worker.Spec.Synapse == "" {
+		return nil
+	}
+	return []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Name: worker.Spec.Synapse, Namespace: worker.Namespace}},
+	}
+}
+
+// newRoutingConfigMapForCR returns a configmap with nginx routing config for the cr and its workers
+func newRoutingConfigMapForCR(cr *synapsev1alpha1.Synapse, workers []synapsev1alpha1.SynapseWorker) *corev1.ConfigMap {
+	labels := map[string]string{
+		"app": cr.Name,
+	}
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cr.GetRoutingConfigMapName(),
+			Namespace: cr.Namespace,
+			Labels:    labels,
+		},
+		Data: cr.GenerateRoutingConfig(workers),
+	}
+}
@@ -2,7 +2,7 @@ package synapse
 
 import (
 	"context"
-	"reflect"
+	"fmt"
 
 	"github.com/go-logr/logr"
 	synapsev1alpha1 "github.com/vrutkovs/synapse-operator/pkg/apis/synapse/v1alpha1"
@@ -11,73 +11,184 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
-func (r *ReconcileSynapse) reconcileSecret(request reconcile.Request, instance *synapsev1alpha1.Synapse, reqLogger logr.Logger) (reconcile.Result, bool, error) {
-	// Check if this Secret already exists
-	secret := newSecretForCR(instance)
+func (r *ReconcileSynapse) reconcileSecret(request reconcile.Request, instance *synapsev1alpha1.Synapse, reqLogger logr.Logger) (reconcile.Result, error) {
+	// Fetch values from referenced secrets
+	referenced, err := r.getReferencedSecretData(instance)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	// Previously generated keys are preserved. The secret is read from the apiserver, as keys would be
+	// regenerated if the cache hasn't caught up with the secret applied by previous reconcile
+	found := &corev1.Secret{}
+	err = r.reader.Get(context.TODO(), types.NamespacedName{Name: instance.GetSecretName(), Namespace: instance.Namespace}, found)
+	if err != nil && !errors.IsNotFound(err) {
+		return reconcile.Result{}, err
+	}
+	secret, err := newSecretForCR(instance, referenced, found.Data)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
 
 	// Set Synapse instance as the owner and controller
 	if err := controllerutil.SetControllerReference(instance, secret, r.scheme); err != nil {
-		return reconcile.Result{}, false, err
+		return reconcile.Result{}, err
 	}
 
-	found := &corev1.Secret{}
-	err := r.client.Get(context.TODO(), types.NamespacedName{Name: secret.Name, Namespace: secret.Namespace}, found)
-	if err != nil && errors.IsNotFound(err) {
-		reqLogger.Info("Creating a new Secret", "Secret.Namespace", secret.Namespace, "Secret.Name", secret.Name)
-		err = r.client.Create(context.TODO(), secret)
+	reqLogger.Info("Applying Secret", "Secret.Namespace", secret.Namespace, "Secret.Name", secret.Name)
+	if err := r.applier.Apply(instance, secret); err != nil {
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{}, nil
+}
+
+// getReferencedSecretData returns values of secret keys referenced in the CR
+func (r *ReconcileSynapse) getReferencedSecretData(cr *synapsev1alpha1.Synapse) (map[string][]byte, error) {
+	data := map[string][]byte{}
+	for key, ref := range cr.GetSecretRefs() {
+		optional := ref.Optional != nil && *ref.Optional
+		secret := &corev1.Secret{}
+		err := r.client.Get(context.TODO(), types.NamespacedName{Name: ref.Name, Namespace: cr.Namespace}, secret)
 		if err != nil {
-			return reconcile.Result{}, false, err
+			if errors.IsNotFound(err) && optional {
+				continue
+			}
+			return nil, fmt.Errorf("failed to get secret %s referenced by %s: %w", ref.Name, key, err)
 		}
-
-		// Secret created successfully - don't requeue
-		return reconcile.Result{}, true, nil
-	} else if err != nil {
-		reqLogger.Info("Secret reconcile error", "Secret.Namespace", found.Namespace, "Secret.Name", found.Name, "Error", err)
-		return reconcile.Result{Requeue: true}, false, nil
-	} else if err == nil {
-		// Check if secret fields haven't change
-		expectedData := getExpectedSecretData(instance)
-		if !reflect.DeepEqual(found.Data, expectedData) {
-			found.ObjectMeta = secret.ObjectMeta
-			controllerutil.SetControllerReference(instance, found, r.scheme)
-			found.Data = expectedData
-			err = r.client.Update(context.TODO(), found)
-			if err != nil {
-				return reconcile.Result{Requeue: true}, false, err
+		value, ok := secret.Data[ref.Key]
+		if !ok {
+			if optional {
+				continue
 			}
-			reqLogger.Info("Secret contents updated", "Secret.Namespace", found.Namespace, "Secret.Name", found.Name)
-			return reconcile.Result{}, true, nil
+			return nil, fmt.Errorf("key %s not found in secret %s referenced by %s", ref.Key, ref.Name, key)
 		}
+		data[key] = value
 	}
+	return data, nil
+}
 
-	// Secret already exists - don't requeue
-	reqLogger.Info("Skip reconcile: Secret already exists", "Secret.Namespace", found.Namespace, "Secret.Name", found.Name)
-	return reconcile.Result{}, false, nil
+// getSynapsesReferencingSecret maps a secret to Synapse instances in the same namespace referencing it
+func getSynapsesReferencingSecret(c client.Client, a handler.MapObject) []reconcile.Request {
+	synapses := &synapsev1alpha1.SynapseList{}
+	if err := c.List(context.TODO(), synapses, client.InNamespace(a.Meta.GetNamespace())); err != nil {
+		log.Error(err, "Failed to list Synapse instances", "Secret.Namespace", a.Meta.GetNamespace(), "Secret.Name", a.Meta.GetName())
+		return nil
+	}
+	requests := []reconcile.Request{}
+	for _, synapse := range synapses.Items {
+		if isSecretReferenced(&synapse, a.Meta.GetName()) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: synapse.Name, Namespace: synapse.Namespace},
+			})
+		}
+	}
+	return requests
 }
 
-func getExpectedSecretData(cr *synapsev1alpha1.Synapse) map[string][]byte {
-	return map[string][]byte{
-		"cert":       []byte(cr.Spec.Secrets.Cert),
-		"key":        []byte(cr.Spec.Secrets.Key),
-		"signingKey": []byte(cr.Spec.Secrets.SigningKey),
+// isSecretReferenced returns true if the secret holds values copied to the managed secret, or TLS certificates
+// of ingress hosts. Routes embed certificates, so they are updated when the certificate is rotated
+func isSecretReferenced(cr *synapsev1alpha1.Synapse, name string) bool {
+	for _, ref := range cr.GetSecretRefs() {
+		if ref.Name == name {
+			return true
+		}
+	}
+	for _, tlsSecretName := range cr.GetTLSSecretNames() {
+		if tlsSecretName == name {
+			return true
+		}
 	}
+	return false
+}
+
+// getExpectedSecretData returns expected data stored in secret. Values from referenced secrets take precedence
+// over values set in the CR, missing keys are taken from existing secret data or generated,
+// so that they never change once created
+func getExpectedSecretData(cr *synapsev1alpha1.Synapse, referenced, existing map[string][]byte) (map[string][]byte, error) {
+	data := map[string][]byte{}
+	for key, value := range referenced {
+		data[key] = value
+	}
+
+	// TLS certificate and key must be replaced together
+	if _, ok := data[synapsev1alpha1.SecretKeyCert]; !ok && cr.Spec.Secrets.Cert != "" {
+		data[synapsev1alpha1.SecretKeyCert] = []byte(cr.Spec.Secrets.Cert)
+	}
+	if _, ok := data[synapsev1alpha1.SecretKeyKey]; !ok && cr.Spec.Secrets.Key != "" {
+		data[synapsev1alpha1.SecretKeyKey] = []byte(cr.Spec.Secrets.Key)
+	}
+	_, hasCert := data[synapsev1alpha1.SecretKeyCert]
+	_, hasKey := data[synapsev1alpha1.SecretKeyKey]
+	switch {
+	case hasCert && hasKey:
+		// Both are set by the user
+	case hasCert || hasKey:
+		return nil, fmt.Errorf("TLS certificate and key must be set together")
+	case len(existing[synapsev1alpha1.SecretKeyCert]) > 0 && len(existing[synapsev1alpha1.SecretKeyKey]) > 0:
+		data[synapsev1alpha1.SecretKeyCert] = existing[synapsev1alpha1.SecretKeyCert]
+		data[synapsev1alpha1.SecretKeyKey] = existing[synapsev1alpha1.SecretKeyKey]
+	default:
+		cert, key, err := generateSelfSignedCertificate(cr.Spec.ServerName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate TLS certificate: %v", err)
+		}
+		data[synapsev1alpha1.SecretKeyCert] = cert
+		data[synapsev1alpha1.SecretKeyKey] = key
+	}
+
+	generators := map[string]func() ([]byte, error){
+		synapsev1alpha1.SecretKeySigningKey:               generateSigningKey,
+		synapsev1alpha1.SecretKeyMacaroonSecretKey:        generateSharedSecret,
+		synapsev1alpha1.SecretKeyFormSecret:               generateSharedSecret,
+		synapsev1alpha1.SecretKeyRegistrationSharedSecret: generateSharedSecret,
+	}
+	if _, ok := data[synapsev1alpha1.SecretKeySigningKey]; !ok && cr.Spec.Secrets.SigningKey != "" {
+		data[synapsev1alpha1.SecretKeySigningKey] = []byte(cr.Spec.Secrets.SigningKey)
+	}
+	for key, generate := range generators {
+		if _, ok := data[key]; ok {
+			continue
+		}
+		if value := existing[key]; len(value) > 0 {
+			data[key] = value
+			continue
+		}
+		value, err := generate()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate %s: %v", key, err)
+		}
+		data[key] = value
+	}
+
+	secretsConfig, err := cr.GenerateSecretsConfig(data)
+	if err != nil {
+		return nil, err
+	}
+	data[synapsev1alpha1.SecretKeySecretsConfig] = secretsConfig
+	return data, nil
 }
 
 // newSecretForCR returns a busybox pod with the same name/namespace as the cr
-func newSecretForCR(cr *synapsev1alpha1.Synapse) *corev1.Secret {
+func newSecretForCR(cr *synapsev1alpha1.Synapse, referenced, existing map[string][]byte) (*corev1.Secret, error) {
 	labels := map[string]string{
 		"app": cr.Name,
 	}
+	data, err := getExpectedSecretData(cr, referenced, existing)
+	if err != nil {
+		return nil, err
+	}
 	return &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      cr.GetSecretName(),
 			Namespace: cr.Namespace,
 			Labels:    labels,
 		},
-		Data: getExpectedSecretData(cr),
-	}
+		Data: data,
+	}, nil
 }
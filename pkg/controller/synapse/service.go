@@ -1,16 +1,11 @@
 package synapse
 
 import (
-	"context"
-	"reflect"
-
 	"github.com/go-logr/logr"
 	synapsev1alpha1 "github.com/vrutkovs/synapse-operator/pkg/apis/synapse/v1alpha1"
 
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -24,69 +19,29 @@ func (r *ReconcileSynapse) reconcileService(request reconcile.Request, instance
 		return reconcile.Result{}, err
 	}
 
-	// Check if this Service already exists
-	found := &corev1.Service{}
-	err := r.client.Get(context.TODO(), types.NamespacedName{Name: service.Name, Namespace: service.Namespace}, found)
-	if err != nil && errors.IsNotFound(err) {
-		reqLogger.Info("Creating a new Service", "Service.Namespace", service.Namespace, "Service.Name", service.Name)
-		err = r.client.Create(context.TODO(), service)
-		if err != nil {
-			return reconcile.Result{}, err
-		}
-
-		// Service created successfully - don't requeue
-		return reconcile.Result{}, nil
-	} else if err != nil {
-		// Unknown error - requeue
-		reqLogger.Info("Service reconcile error", "Service.Namespace", found.Namespace, "Service.Name", found.Name, "Error", err)
-		return reconcile.Result{Requeue: true}, nil
-	} else if err == nil {
-		// Check if Service fields haven't change
-
-		expectedSpec := getExpectedServiceSpec(instance)
-		if serviceNeedsUpdate(&found.Spec, &expectedSpec, reqLogger) {
-			found.ObjectMeta = service.ObjectMeta
-			controllerutil.SetControllerReference(instance, found, r.scheme)
-			found.Spec = expectedSpec
-			err = r.client.Update(context.TODO(), found)
-			if err != nil {
-				return reconcile.Result{Requeue: true}, err
-			}
-			reqLogger.Info("Service spec updated", "Service.Namespace", found.Namespace, "Service.Name", found.Name)
-			return reconcile.Result{}, nil
-		}
+	reqLogger.Info("Applying Service", "Service.Namespace", service.Namespace, "Service.Name", service.Name)
+	if err := r.applier.Apply(instance, service); err != nil {
+		return reconcile.Result{}, err
 	}
-
-	// Service already exists - don't requeue
-	reqLogger.Info("Skip reconcile: Service already exists", "Service.Namespace", found.Namespace, "Service.Name", found.Name)
 	return reconcile.Result{}, nil
 }
 
 // getExpectedServiceData returns expected data stored in Service
 func getExpectedServiceSpec(cr *synapsev1alpha1.Synapse) corev1.ServiceSpec {
+	// Service exposes the same ports as the container, so that they match generated listeners
+	var ports []corev1.ServicePort
+	for _, containerPort := range cr.GetContainerPorts() {
+		ports = append(ports, corev1.ServicePort{
+			Name:       containerPort.Name,
+			Protocol:   containerPort.Protocol,
+			TargetPort: intstr.IntOrString{Type: intstr.String, StrVal: containerPort.Name},
+			Port:       containerPort.ContainerPort,
+		})
+	}
 	return corev1.ServiceSpec{
 		Selector: getDeploymentLabels(cr),
 		Type:     corev1.ServiceTypeClusterIP,
-		Ports: []corev1.ServicePort{
-			{
-				Name:       "http",
-				Protocol:   corev1.ProtocolTCP,
-				TargetPort: intstr.IntOrString{Type: intstr.String, StrVal: "http"},
-				Port:       int32(cr.Spec.Ports.HTTP),
-			},
-			{
-				Name:       "https",
-				Protocol:   corev1.ProtocolTCP,
-				TargetPort: intstr.IntOrString{Type: intstr.String, StrVal: "https"},
-				Port:       int32(cr.Spec.Ports.HTTPS),
-			},
-			{
-				Name:       "replication",
-				Protocol:   corev1.ProtocolTCP,
-				TargetPort: intstr.IntOrString{Type: intstr.String, StrVal: "replication"},
-				Port:       int32(cr.Spec.Ports.Replication),
-			},
-		},
+		Ports:    ports,
 	}
 }
 
@@ -104,19 +59,3 @@ func newServiceForCR(cr *synapsev1alpha1.Synapse) *corev1.Service {
 		Spec: getExpectedServiceSpec(cr),
 	}
 }
-
-func serviceNeedsUpdate(actual, expected *corev1.ServiceSpec, reqLogger logr.Logger) bool {
-	// Selector
-	if !reflect.DeepEqual(actual.Selector, expected.Selector) {
-		reqLogger.Info("Service selector mismatch found", "actual", actual.Selector, "expected", expected.Selector)
-		return true
-	}
-
-	// Ports
-	if !reflect.DeepEqual(actual.Ports, expected.Ports) {
-		reqLogger.Info("Service ports mismatch found", "actual", actual.Ports, "expected", expected.Ports)
-		return true
-	}
-
-	return false
-}
@@ -0,0 +1,107 @@
+package synapse
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	synapsev1alpha1 "github.com/vrutkovs/synapse-operator/pkg/apis/synapse/v1alpha1"
+	"github.com/vrutkovs/synapse-operator/pkg/controller/apply"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// updateStatus records observed state of managed resources and the result of the last reconcile in Synapse status
+func (r *ReconcileSynapse) updateStatus(instance *synapsev1alpha1.Synapse, reconcileErr error, reqLogger logr.Logger) error {
+	newStatus := instance.Status.DeepCopy()
+	newStatus.ObservedGeneration = instance.Generation
+
+	// Fetch deployment to find out how many replicas are ready
+	found := &appsv1.Deployment{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: instance.GetDeploymentName(), Namespace: instance.Namespace}, found)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	desiredReplicas := int32(1)
+	if found.Spec.Replicas != nil {
+		desiredReplicas = *found.Spec.Replicas
+	}
+	newStatus.ReadyReplicas = found.Status.ReadyReplicas
+
+	ready := err == nil && found.Status.ReadyReplicas >= desiredReplicas
+	if ready {
+		newStatus.Conditions.SetCondition(status.Condition{
+			Type:    synapsev1alpha1.SynapseConditionReady,
+			Status:  corev1.ConditionTrue,
+			Reason:  "DeploymentReady",
+			Message: "All homeserver replicas are ready",
+		})
+	} else {
+		newStatus.Conditions.SetCondition(status.Condition{
+			Type:    synapsev1alpha1.SynapseConditionReady,
+			Status:  corev1.ConditionFalse,
+			Reason:  "DeploymentNotReady",
+			Message: "Waiting for homeserver replicas to become ready",
+		})
+	}
+
+	progressing := err != nil ||
+		found.Status.ObservedGeneration < found.Generation ||
+		found.Status.UpdatedReplicas < desiredReplicas ||
+		!ready
+	if progressing {
+		newStatus.Conditions.SetCondition(status.Condition{
+			Type:    synapsev1alpha1.SynapseConditionProgressing,
+			Status:  corev1.ConditionTrue,
+			Reason:  "RolloutInProgress",
+			Message: "Homeserver deployment is being rolled out",
+		})
+	} else {
+		newStatus.Conditions.SetCondition(status.Condition{
+			Type:    synapsev1alpha1.SynapseConditionProgressing,
+			Status:  corev1.ConditionFalse,
+			Reason:  "RolloutComplete",
+			Message: "Homeserver deployment is up to date",
+		})
+	}
+
+	if reconcileErr != nil {
+		newStatus.LastError = reconcileErr.Error()
+		newStatus.Conditions.SetCondition(status.Condition{
+			Type:    synapsev1alpha1.SynapseConditionDegraded,
+			Status:  corev1.ConditionTrue,
+			Reason:  "ReconcileFailed",
+			Message: reconcileErr.Error(),
+		})
+	} else {
+		newStatus.LastError = ""
+		newStatus.Conditions.SetCondition(status.Condition{
+			Type:   synapsev1alpha1.SynapseConditionDegraded,
+			Status: corev1.ConditionFalse,
+			Reason: "ReconcileSucceeded",
+		})
+	}
+
+	newStatus.Conditions.SetCondition(apply.ConflictCondition(synapsev1alpha1.SynapseConditionFieldConflict, r.applier.Conflicts(instance)))
+
+	switch {
+	case reconcileErr != nil:
+		newStatus.Phase = synapsev1alpha1.SynapsePhaseFailed
+	case ready:
+		newStatus.Phase = synapsev1alpha1.SynapsePhaseRunning
+	default:
+		newStatus.Phase = synapsev1alpha1.SynapsePhasePending
+	}
+
+	// Skip the update if nothing has changed to avoid reconcile loops
+	if reflect.DeepEqual(&instance.Status, newStatus) {
+		return nil
+	}
+	instance.Status = *newStatus
+	reqLogger.Info("Updating Synapse status", "Phase", newStatus.Phase, "ReadyReplicas", newStatus.ReadyReplicas)
+	return r.client.Status().Update(context.TODO(), instance)
+}
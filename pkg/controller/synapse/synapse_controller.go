@@ -2,11 +2,17 @@ package synapse
 
 import (
 	"context"
+	"fmt"
 
+	"github.com/go-logr/logr"
 	synapsev1alpha1 "github.com/vrutkovs/synapse-operator/pkg/apis/synapse/v1alpha1"
+	"github.com/vrutkovs/synapse-operator/pkg/controller/apply"
+	"github.com/vrutkovs/synapse-operator/pkg/controller/exposure"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
@@ -27,7 +33,13 @@ func Add(mgr manager.Manager) error {
 
 // newReconciler returns a new reconcile.Reconciler
 func newReconciler(mgr manager.Manager) reconcile.Reconciler {
-	return &ReconcileSynapse{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+	return &ReconcileSynapse{
+		client:          mgr.GetClient(),
+		reader:          mgr.GetAPIReader(),
+		scheme:          mgr.GetScheme(),
+		applier:         apply.NewApplier(mgr.GetClient(), mgr.GetScheme()),
+		routesAvailable: exposure.IsRouteAPIAvailable(mgr),
+	}
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
@@ -68,6 +80,52 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		return err
 	}
 
+	err = c.Watch(&source.Kind{Type: &corev1.PersistentVolumeClaim{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &synapsev1alpha1.Synapse{},
+	})
+	if err != nil {
+		return err
+	}
+
+	err = c.Watch(&source.Kind{Type: &networkingv1beta1.Ingress{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &synapsev1alpha1.Synapse{},
+	})
+	if err != nil {
+		return err
+	}
+
+	if exposure.IsRouteAPIAvailable(mgr) {
+		route := &unstructured.Unstructured{}
+		route.SetGroupVersionKind(exposure.RouteGVK)
+		err = c.Watch(&source.Kind{Type: route}, &handler.EnqueueRequestForOwner{
+			IsController: true,
+			OwnerType:    &synapsev1alpha1.Synapse{},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Watch for SynapseWorkers to keep worker routing and homeserver settings up to date
+	err = c.Watch(&source.Kind{Type: &synapsev1alpha1.SynapseWorker{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(getReferencedSynapse),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to secrets referenced by Synapse instances, including ingress TLS secrets
+	err = c.Watch(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(a handler.MapObject) []reconcile.Request {
+			return getSynapsesReferencingSecret(mgr.GetClient(), a)
+		}),
+	})
+	if err != nil {
+		return err
+	}
+
 	err = c.Watch(&source.Kind{Type: &corev1.Service{}}, &handler.EnqueueRequestForOwner{
 		IsController: true,
 		OwnerType:    &synapsev1alpha1.Synapse{},
@@ -87,7 +145,14 @@ type ReconcileSynapse struct {
 	// This client, initialized using mgr.Client() above, is a split client
 	// that reads objects from the cache and writes to the apiserver
 	client client.Client
+	// reader reads objects directly from the apiserver, bypassing the cache
+	reader client.Reader
 	scheme *runtime.Scheme
+	// applier manages configmaps, secrets, persistent volume claims, deployments, services, ingresses
+	// and routes via server-side apply
+	applier *apply.Applier
+	// routesAvailable is set when OpenShift Routes are used instead of Ingress
+	routesAvailable bool
 }
 
 // Reconcile reads that state of the cluster for a Synapse object and makes changes based on the state read
@@ -110,32 +175,46 @@ func (r *ReconcileSynapse) Reconcile(request reconcile.Request) (reconcile.Resul
 		return reconcile.Result{}, err
 	}
 
-	result, secretUpdated, err := r.reconcileSecret(request, instance, reqLogger)
-	if err != nil {
-		return result, err
-	}
+	r.applier.Reset(instance)
+	result, err := r.reconcileResources(request, instance, reqLogger)
 
-	result, cmUpdated, err := r.reconcileConfigMap(request, instance, reqLogger)
-	if err != nil {
-		return result, err
+	// Record the outcome in status, the reconcile error takes precedence over status update error
+	if statusErr := r.updateStatus(instance, err, reqLogger); statusErr != nil {
+		reqLogger.Info("Failed to update Synapse status", "Error", statusErr)
+		if err == nil {
+			return reconcile.Result{}, statusErr
+		}
 	}
 
-	result, created, err := r.reconcileDeployment(request, instance, reqLogger)
-	if err != nil {
-		return result, err
-	}
+	return result, err
+}
 
-	// If either of configMap or secret has been updated force rollout
-	if (cmUpdated || secretUpdated) && !created {
-		if result, err := r.forceDeploymentRollout(request, instance, reqLogger); err != nil {
-			return result, err
-		}
+// reconcileResources creates or updates all resources managed by Synapse instance.
+// Remaining resources are reconciled when one of them requests a requeue, the requeue is returned once all are done
+func (r *ReconcileSynapse) reconcileResources(request reconcile.Request, instance *synapsev1alpha1.Synapse, reqLogger logr.Logger) (reconcile.Result, error) {
+	steps := []struct {
+		name      string
+		reconcile func(reconcile.Request, *synapsev1alpha1.Synapse, logr.Logger) (reconcile.Result, error)
+	}{
+		{"secret", r.reconcileSecret},
+		{"configmap", r.reconcileConfigMap},
+		{"persistent volume claim", r.reconcilePVC},
+		{"redis", r.reconcileRedis},
+		{"deployment", r.reconcileDeployment},
+		{"service", r.reconcileService},
+		{"routing configmap", r.reconcileRoutingConfigMap},
+		{"proxy", r.reconcileProxy},
+		{"ingress", r.reconcileIngress},
+		{"well-known responder", r.reconcileWellKnown},
 	}
 
-	result, err = r.reconcileService(request, instance, reqLogger)
-	if err != nil {
-		return result, err
+	result := reconcile.Result{}
+	for _, step := range steps {
+		stepResult, err := step.reconcile(request, instance, reqLogger)
+		if err != nil {
+			return stepResult, fmt.Errorf("failed to reconcile %s: %w", step.name, err)
+		}
+		result.Requeue = result.Requeue || stepResult.Requeue
 	}
-
-	return reconcile.Result{}, nil
+	return result, nil
 }
@@ -1,6 +1,9 @@
 package synapse
 
 import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
 	"flag"
 	"testing"
 	"time"
@@ -9,9 +12,26 @@ import (
 	g "github.com/onsi/gomega"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/vrutkovs/synapse-operator/pkg/apis/common"
 	synapsev1alpha1 "github.com/vrutkovs/synapse-operator/pkg/apis/synapse/v1alpha1"
+	"github.com/vrutkovs/synapse-operator/pkg/controller/apply"
+	applyfake "github.com/vrutkovs/synapse-operator/pkg/controller/apply/fake"
+	"github.com/vrutkovs/synapse-operator/pkg/controller/exposure"
+	"github.com/vrutkovs/synapse-operator/pkg/controller/rollout"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/kubectl/pkg/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
 )
 
 var (
@@ -63,17 +83,898 @@ var _ = ginkgo.Describe("[synapse]", func() {
 		secret := getSecret(t, instance, cl, ns)
 		g.Expect(secret.Name).To(g.Equal(instance.GetSecretName()))
 		g.Expect(secret.Labels).To(g.Equal(map[string]string{"app": name}))
-		g.Expect(secret.Data).To(g.Equal(map[string][]byte{
-			"cert":       []byte("foo"),
-			"key":        []byte("bar"),
-			"signingKey": []byte("baz"),
+		g.Expect(secret.Data).To(g.HaveKeyWithValue("cert", []byte("foo")))
+		g.Expect(secret.Data).To(g.HaveKeyWithValue("key", []byte("bar")))
+		g.Expect(secret.Data).To(g.HaveKeyWithValue("signingKey", []byte("baz")))
+
+		secretsConfig := map[string]string{}
+		err := yaml.Unmarshal(secret.Data["secrets.yaml"], &secretsConfig)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(secretsConfig).To(g.Equal(map[string]string{
+			"macaroon_secret_key":        string(secret.Data["macaroonSecretKey"]),
+			"form_secret":                string(secret.Data["formSecret"]),
+			"registration_shared_secret": string(secret.Data["registrationSharedSecret"]),
+		}))
+	})
+
+	ginkgo.It("should generate keys once", func() {
+		spec := synapsev1alpha1.SynapseSpec{
+			ServerName: "foo.bar",
+		}
+		instance := initFakeSynapse(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns)
+		secret := getSecret(t, instance, cl, ns)
+		g.Expect(string(secret.Data["signingKey"])).To(g.MatchRegexp(`^ed25519 a_[a-zA-Z]{4} [A-Za-z0-9+/]{43}$`))
+		for _, key := range []string{"macaroonSecretKey", "formSecret", "registrationSharedSecret"} {
+			g.Expect(secret.Data[key]).NotTo(g.BeEmpty())
+		}
+
+		block, _ := pem.Decode(secret.Data["cert"])
+		g.Expect(block).NotTo(g.BeNil())
+		cert, err := x509.ParseCertificate(block.Bytes)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(cert.DNSNames).To(g.Equal([]string{"foo.bar"}))
+
+		reconcileSynapse(t, cl, name, ns)
+		g.Expect(getSecret(t, instance, cl, ns).Data).To(g.Equal(secret.Data))
+	})
+
+	ginkgo.It("should not regenerate keys when cache misses the secret", func() {
+		spec := synapsev1alpha1.SynapseSpec{
+			ServerName: "foo.bar",
+		}
+		instance := initFakeSynapse(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns)
+		secret := getSecret(t, instance, cl, ns)
+
+		stale := &staleCacheClient{Client: cl, name: instance.GetSecretName()}
+		r := &ReconcileSynapse{client: stale, reader: cl, scheme: scheme.Scheme, applier: apply.NewApplier(cl, scheme.Scheme)}
+		_, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: ns}})
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(getSecret(t, instance, cl, ns).Data).To(g.Equal(secret.Data))
+	})
+
+	ginkgo.It("should use referenced secrets", func() {
+		external := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "external",
+				Namespace: ns,
+			},
+			Data: map[string][]byte{
+				"tls.crt":  []byte("foo"),
+				"tls.key":  []byte("bar"),
+				"signing":  []byte("baz"),
+				"password": []byte("hunter2"),
+			},
+		}
+		selector := func(key string) *corev1.SecretKeySelector {
+			return &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "external"},
+				Key:                  key,
+			}
+		}
+		spec := synapsev1alpha1.SynapseSpec{
+			ServerName: "foo.bar",
+			Config: synapsev1alpha1.SynapseConfig{
+				Settings: &synapsev1alpha1.SynapseSettings{
+					Database: &synapsev1alpha1.SynapseDatabaseSettings{
+						Name: "psycopg2",
+						Args: map[string]string{"user": "synapse"},
+					},
+				},
+			},
+			Secrets: synapsev1alpha1.SynapseSecrets{
+				CertRef:             selector("tls.crt"),
+				KeyRef:              selector("tls.key"),
+				SigningKeyRef:       selector("signing"),
+				DatabasePasswordRef: selector("password"),
+			},
+		}
+		instance := initFakeSynapse(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns, external)
+		secret := getSecret(t, instance, cl, ns)
+		g.Expect(secret.Data).To(g.HaveKeyWithValue("cert", []byte("foo")))
+		g.Expect(secret.Data).To(g.HaveKeyWithValue("key", []byte("bar")))
+		g.Expect(secret.Data).To(g.HaveKeyWithValue("signingKey", []byte("baz")))
+
+		secretsConfig := map[string]interface{}{}
+		err := yaml.Unmarshal(secret.Data["secrets.yaml"], &secretsConfig)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(secretsConfig["database"]).To(g.Equal(map[string]interface{}{
+			"name": "psycopg2",
+			"args": map[string]interface{}{
+				"user":     "synapse",
+				"password": "hunter2",
+			},
+		}))
+
+		requests := getSynapsesReferencingSecret(cl, handler.MapObject{Meta: external, Object: external})
+		g.Expect(requests).To(g.HaveLen(1))
+		g.Expect(requests[0].Name).To(g.Equal(name))
+
+		// Referenced secret change is copied to the managed secret and rolls out deployment
+		configHash := getDeployment(t, instance, cl, ns).Spec.Template.Annotations[rollout.ConfigHashAnnotation]
+		g.Expect(configHash).NotTo(g.BeEmpty())
+		external.Data["signing"] = []byte("qux")
+		err = cl.Update(context.TODO(), external)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		reconcileSynapse(t, cl, name, ns)
+		g.Expect(getSecret(t, instance, cl, ns).Data).To(g.HaveKeyWithValue("signingKey", []byte("qux")))
+		deployment := getDeployment(t, instance, cl, ns)
+		g.Expect(deployment.Spec.Template.Annotations[rollout.ConfigHashAnnotation]).NotTo(g.Equal(configHash))
+	})
+
+	ginkgo.It("should fail when referenced secret is missing", func() {
+		spec := synapsev1alpha1.SynapseSpec{
+			Secrets: synapsev1alpha1.SynapseSecrets{
+				SigningKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "missing"},
+					Key:                  "signing",
+				},
+			},
+		}
+		instance := initFakeSynapse(t, name, ns, &spec)
+		s := scheme.Scheme
+		s.AddKnownTypes(synapsev1alpha1.SchemeGroupVersion, instance, &synapsev1alpha1.SynapseList{}, &synapsev1alpha1.SynapseWorkerList{})
+		cl = applyfake.NewClient(fake.NewFakeClientWithScheme(s, instance), s)
+		r := &ReconcileSynapse{client: cl, reader: cl, scheme: s, applier: apply.NewApplier(cl, s)}
+		_, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: ns}})
+		g.Expect(err).To(g.HaveOccurred())
+
+		synapse := getSynapse(t, instance, cl, ns)
+		g.Expect(synapse.Status.Phase).To(g.Equal(synapsev1alpha1.SynapsePhaseFailed))
+		g.Expect(synapse.Status.LastError).To(g.ContainSubstring("missing"))
+	})
+
+	ginkgo.It("should fail when only TLS certificate is set", func() {
+		spec := synapsev1alpha1.SynapseSpec{
+			Secrets: synapsev1alpha1.SynapseSecrets{
+				Cert: "foo",
+			},
+		}
+		instance := initFakeSynapse(t, name, ns, &spec)
+		s := scheme.Scheme
+		s.AddKnownTypes(synapsev1alpha1.SchemeGroupVersion, instance, &synapsev1alpha1.SynapseList{}, &synapsev1alpha1.SynapseWorkerList{})
+		cl = applyfake.NewClient(fake.NewFakeClientWithScheme(s, instance), s)
+		r := &ReconcileSynapse{client: cl, reader: cl, scheme: s, applier: apply.NewApplier(cl, s)}
+		_, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: ns}})
+		g.Expect(err).To(g.HaveOccurred())
+
+		secret := &corev1.Secret{}
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: instance.GetSecretName(), Namespace: ns}, secret)
+		g.Expect(errors.IsNotFound(err)).To(g.BeTrue())
+		g.Expect(getSynapse(t, instance, cl, ns).Status.LastError).To(g.ContainSubstring("TLS certificate and key must be set together"))
+	})
+
+	ginkgo.It("should report errors getting managed objects", func() {
+		spec := synapsev1alpha1.SynapseSpec{}
+		instance := initFakeSynapse(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns)
+		failing := &failingGetClient{Client: cl, name: instance.GetConfigMapName()}
+		r := &ReconcileSynapse{client: failing, reader: failing, scheme: scheme.Scheme, applier: apply.NewApplier(failing, scheme.Scheme)}
+		_, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: ns}})
+		g.Expect(err).To(g.HaveOccurred())
+
+		synapse := getSynapse(t, instance, cl, ns)
+		g.Expect(synapse.Status.Phase).To(g.Equal(synapsev1alpha1.SynapsePhaseFailed))
+		g.Expect(synapse.Status.LastError).To(g.ContainSubstring("apiserver is unavailable"))
+		g.Expect(synapse.Status.Conditions.IsTrueFor(synapsev1alpha1.SynapseConditionDegraded)).To(g.BeTrue())
+	})
+
+	ginkgo.It("should configure managed database", func() {
+		password := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "postgres",
+				Namespace: ns,
+			},
+			Data: map[string][]byte{"password": []byte("hunter2")},
+		}
+		spec := synapsev1alpha1.SynapseSpec{
+			ServerName: "foo.bar",
+			Database: &synapsev1alpha1.SynapseDatabase{
+				Host: "postgres.synapse.svc",
+				Name: "synapse",
+				User: "synapse_user",
+				PasswordSecretRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "postgres"},
+					Key:                  "password",
+				},
+			},
+		}
+		instance := initFakeSynapse(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns, password)
+
+		expectedArgs := map[string]interface{}{
+			"user":     "synapse_user",
+			"database": "synapse",
+			"host":     "postgres.synapse.svc",
+			"port":     float64(5432),
+			"cp_min":   float64(5),
+			"cp_max":   float64(10),
+		}
+		config := parseHomeserverConfig(t, getConfigMap(t, instance, cl, ns))
+		g.Expect(config["database"]).To(g.Equal(map[string]interface{}{
+			"name": "psycopg2",
+			"args": expectedArgs,
+		}))
+
+		secretsConfig := map[string]interface{}{}
+		err := yaml.Unmarshal(getSecret(t, instance, cl, ns).Data["secrets.yaml"], &secretsConfig)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		expectedArgs["password"] = "hunter2"
+		g.Expect(secretsConfig["database"]).To(g.Equal(map[string]interface{}{
+			"name": "psycopg2",
+			"args": expectedArgs,
+		}))
+
+		deployment := getDeployment(t, instance, cl, ns)
+		g.Expect(deployment.Spec.Template.Spec.InitContainers).To(g.HaveLen(1))
+		initContainer := deployment.Spec.Template.Spec.InitContainers[0]
+		g.Expect(initContainer.Image).To(g.Equal(synapsev1alpha1.DefaultDatabaseWaitImage))
+		g.Expect(initContainer.Env).To(g.Equal([]corev1.EnvVar{
+			{Name: "PGHOST", Value: "postgres.synapse.svc"},
+			{Name: "PGPORT", Value: "5432"},
+			{Name: "PGDATABASE", Value: "synapse"},
+			{Name: "PGUSER", Value: "synapse_user"},
+		}))
+	})
+
+	ginkgo.It("should deploy redis", func() {
+		spec := synapsev1alpha1.SynapseSpec{
+			ServerName: "foo.bar",
+			Ports: synapsev1alpha1.SynapsePorts{
+				HTTP:        8008,
+				Replication: 9093,
+			},
+			Redis: &synapsev1alpha1.SynapseRedis{},
+		}
+		instance := initFakeSynapse(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns)
+
+		deployment := &appsv1.Deployment{}
+		err := cl.Get(context.TODO(), types.NamespacedName{Name: instance.GetRedisName(), Namespace: ns}, deployment)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(deployment.Spec.Template.Spec.Containers[0].Image).To(g.Equal(synapsev1alpha1.DefaultRedisImage))
+		svc := &corev1.Service{}
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: instance.GetRedisName(), Namespace: ns}, svc)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(svc.Spec.Ports[0].Port).To(g.Equal(int32(6379)))
+
+		config := parseHomeserverConfig(t, getConfigMap(t, instance, cl, ns))
+		g.Expect(config["redis"]).To(g.Equal(map[string]interface{}{
+			"enabled": true,
+			"host":    instance.GetRedisName(),
+			"port":    float64(6379),
+		}))
+		// Replication port serves HTTP replication instead of legacy TCP replication
+		g.Expect(config["listeners"]).To(g.ContainElement(map[string]interface{}{
+			"port":           float64(9093),
+			"type":           "http",
+			"tls":            false,
+			"x_forwarded":    false,
+			"bind_addresses": []interface{}{"0.0.0.0"},
+			"resources": []interface{}{
+				map[string]interface{}{"names": []interface{}{"replication"}, "compress": false},
+			},
+		}))
+
+		// Managed Redis is removed once an external host is set
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: ns}, instance)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		instance.Spec.Redis.Host = "redis.example.com"
+		err = cl.Update(context.TODO(), instance)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		reconcileSynapse(t, cl, name, ns)
+		expectNotFound(t, cl, instance.GetRedisName(), ns, &appsv1.Deployment{}, &corev1.Service{})
+
+		// Redis is deployed again when external host is unset and removed when Redis is disabled
+		instance.Spec.Redis.Host = ""
+		err = cl.Update(context.TODO(), instance)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		reconcileSynapse(t, cl, name, ns)
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: instance.GetRedisName(), Namespace: ns}, deployment)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: ns}, instance)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		instance.Spec.Redis = nil
+		err = cl.Update(context.TODO(), instance)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		reconcileSynapse(t, cl, name, ns)
+		expectNotFound(t, cl, instance.GetRedisName(), ns, &appsv1.Deployment{}, &corev1.Service{})
+	})
+
+	ginkgo.It("should deploy redis on configured port", func() {
+		spec := synapsev1alpha1.SynapseSpec{
+			ServerName: "foo.bar",
+			Ports: synapsev1alpha1.SynapsePorts{
+				HTTP:        8008,
+				Replication: 9093,
+			},
+			Redis: &synapsev1alpha1.SynapseRedis{
+				Port: 6380,
+			},
+		}
+		instance := initFakeSynapse(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns)
+
+		deployment := &appsv1.Deployment{}
+		err := cl.Get(context.TODO(), types.NamespacedName{Name: instance.GetRedisName(), Namespace: ns}, deployment)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		container := deployment.Spec.Template.Spec.Containers[0]
+		g.Expect(container.Args).To(g.ContainElements("--port", "6380"))
+		g.Expect(container.Ports[0].ContainerPort).To(g.Equal(int32(6380)))
+		svc := &corev1.Service{}
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: instance.GetRedisName(), Namespace: ns}, svc)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(svc.Spec.Ports[0].Port).To(g.Equal(int32(6380)))
+
+		config := parseHomeserverConfig(t, getConfigMap(t, instance, cl, ns))
+		g.Expect(config["redis"]).To(g.HaveKeyWithValue("port", float64(6380)))
+	})
+
+	ginkgo.It("should use external redis", func() {
+		password := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "redis",
+				Namespace: ns,
+			},
+			Data: map[string][]byte{"password": []byte("hunter2")},
+		}
+		spec := synapsev1alpha1.SynapseSpec{
+			ServerName: "foo.bar",
+			Redis: &synapsev1alpha1.SynapseRedis{
+				Host: "redis.example.com",
+				Port: 6380,
+				PasswordSecretRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "redis"},
+					Key:                  "password",
+				},
+			},
+		}
+		instance := initFakeSynapse(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns, password)
+
+		err := cl.Get(context.TODO(), types.NamespacedName{Name: instance.GetRedisName(), Namespace: ns}, &appsv1.Deployment{})
+		g.Expect(errors.IsNotFound(err)).To(g.BeTrue())
+
+		config := parseHomeserverConfig(t, getConfigMap(t, instance, cl, ns))
+		g.Expect(config["redis"]).To(g.Equal(map[string]interface{}{
+			"enabled": true,
+			"host":    "redis.example.com",
+			"port":    float64(6380),
+		}))
+		secretsConfig := map[string]interface{}{}
+		err = yaml.Unmarshal(getSecret(t, instance, cl, ns).Data["secrets.yaml"], &secretsConfig)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(secretsConfig["redis"]).To(g.Equal(map[string]interface{}{
+			"enabled":  true,
+			"host":     "redis.example.com",
+			"port":     float64(6380),
+			"password": "hunter2",
+		}))
+	})
+
+	ginkgo.It("should create media store claim", func() {
+		spec := synapsev1alpha1.SynapseSpec{
+			ServerName: "foo.bar",
+			Storage: &synapsev1alpha1.SynapseStorage{
+				Size: resource.MustParse("1Gi"),
+			},
+		}
+		instance := initFakeSynapse(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns)
+
+		pvc := getPVC(t, instance, cl, ns)
+		g.Expect(pvc.Spec.AccessModes).To(g.Equal([]corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}))
+		g.Expect(pvc.Spec.Resources.Requests[corev1.ResourceStorage]).To(g.Equal(resource.MustParse("1Gi")))
+
+		config := parseHomeserverConfig(t, getConfigMap(t, instance, cl, ns))
+		g.Expect(config["media_store_path"]).To(g.Equal("/synapse/media_store"))
+
+		deployment := getDeployment(t, instance, cl, ns)
+		g.Expect(deployment.Spec.Template.Spec.Volumes).To(g.ContainElement(corev1.Volume{
+			Name: "media-store",
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: instance.GetMediaStorePVCName(),
+				},
+			},
+		}))
+		g.Expect(deployment.Spec.Template.Spec.Containers[0].VolumeMounts).To(g.ContainElement(corev1.VolumeMount{
+			Name:      "media-store",
+			MountPath: "/synapse/media_store",
+		}))
+
+		// Claim keeps its size unless expansion is allowed
+		synapse := getSynapse(t, instance, cl, ns)
+		synapse.Spec.Storage.Size = resource.MustParse("2Gi")
+		err := cl.Update(context.TODO(), synapse)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		reconcileSynapse(t, cl, name, ns)
+		pvc = getPVC(t, instance, cl, ns)
+		g.Expect(pvc.Spec.Resources.Requests[corev1.ResourceStorage]).To(g.Equal(resource.MustParse("1Gi")))
+
+		// Claim is expanded when requested size grows, but never shrunk
+		for _, size := range []string{"2Gi", "1Gi"} {
+			synapse := getSynapse(t, instance, cl, ns)
+			synapse.Spec.Storage.Size = resource.MustParse(size)
+			synapse.Spec.Storage.AllowExpansion = true
+			err := cl.Update(context.TODO(), synapse)
+			g.Expect(err).NotTo(g.HaveOccurred())
+			reconcileSynapse(t, cl, name, ns)
+			pvc = getPVC(t, instance, cl, ns)
+			g.Expect(pvc.Spec.Resources.Requests[corev1.ResourceStorage]).To(g.Equal(resource.MustParse("2Gi")))
+		}
+
+		// Reconcile fails clearly if storage class doesn't allow expansion
+		synapse = getSynapse(t, instance, cl, ns)
+		synapse.Spec.Storage.Size = resource.MustParse("3Gi")
+		err = cl.Update(context.TODO(), synapse)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		forbidden := &forbiddenPatchClient{Client: cl, name: instance.GetMediaStorePVCName()}
+		r := &ReconcileSynapse{client: forbidden, reader: forbidden, scheme: scheme.Scheme, applier: apply.NewApplier(forbidden, scheme.Scheme)}
+		_, err = r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: ns}})
+		g.Expect(err).To(g.MatchError(g.ContainSubstring("failed to expand claim to 3Gi, storage class of the claim must allow volume expansion")))
+		synapse = getSynapse(t, instance, cl, ns)
+		synapse.Spec.Storage.Size = resource.MustParse("2Gi")
+		err = cl.Update(context.TODO(), synapse)
+		g.Expect(err).NotTo(g.HaveOccurred())
+
+		// Claim is only garbage collected with Synapse if deletion policy is Delete
+		g.Expect(pvc.OwnerReferences).To(g.BeEmpty())
+		synapse = getSynapse(t, instance, cl, ns)
+		synapse.Spec.Storage.DeletionPolicy = synapsev1alpha1.StorageDeletionPolicyDelete
+		err = cl.Update(context.TODO(), synapse)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		reconcileSynapse(t, cl, name, ns)
+		pvc = getPVC(t, instance, cl, ns)
+		g.Expect(pvc.OwnerReferences).To(g.HaveLen(1))
+		g.Expect(pvc.OwnerReferences[0].Name).To(g.Equal(name))
+
+		synapse = getSynapse(t, instance, cl, ns)
+		synapse.Spec.Storage.DeletionPolicy = synapsev1alpha1.StorageDeletionPolicyRetain
+		err = cl.Update(context.TODO(), synapse)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		reconcileSynapse(t, cl, name, ns)
+		g.Expect(getPVC(t, instance, cl, ns).OwnerReferences).To(g.BeEmpty())
+	})
+
+	ginkgo.It("should create ingress", func() {
+		spec := synapsev1alpha1.SynapseSpec{
+			ServerName: "foo.bar",
+			Ingress: &synapsev1alpha1.SynapseIngress{
+				TLSSecretName: "foo-tls",
+			},
+		}
+		instance := initFakeSynapse(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns)
+		ingress := &networkingv1beta1.Ingress{}
+		err := cl.Get(context.TODO(), types.NamespacedName{Name: instance.GetIngressName(), Namespace: ns}, ingress)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(ingress.Spec.TLS).To(g.Equal([]networkingv1beta1.IngressTLS{
+			{Hosts: []string{"foo.bar"}, SecretName: "foo-tls"},
 		}))
+		g.Expect(ingress.Spec.Rules).To(g.HaveLen(1))
+		g.Expect(ingress.Spec.Rules[0].Host).To(g.Equal("foo.bar"))
+		backend := networkingv1beta1.IngressBackend{
+			ServiceName: instance.GetServiceName(),
+			ServicePort: intstr.FromString("http"),
+		}
+		g.Expect(ingress.Spec.Rules[0].HTTP.Paths).To(g.Equal([]networkingv1beta1.HTTPIngressPath{
+			{Path: "/_matrix", Backend: backend},
+			{Path: "/_synapse/client", Backend: backend},
+		}))
+
+		g.Expect(ingress.OwnerReferences).To(g.HaveLen(1))
+		g.Expect(ingress.OwnerReferences[0].Name).To(g.Equal(name))
+
+		// Changed fields are updated
+		synapse := getSynapse(t, instance, cl, ns)
+		synapse.Spec.Ingress.TLSSecretName = "bar-tls"
+		err = cl.Update(context.TODO(), synapse)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		reconcileSynapse(t, cl, name, ns)
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: instance.GetIngressName(), Namespace: ns}, ingress)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(ingress.Spec.TLS[0].SecretName).To(g.Equal("bar-tls"))
+
+		// Ingress is removed once disabled
+		synapse = getSynapse(t, instance, cl, ns)
+		synapse.Spec.Ingress = nil
+		err = cl.Update(context.TODO(), synapse)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		reconcileSynapse(t, cl, name, ns)
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: instance.GetIngressName(), Namespace: ns}, ingress)
+		g.Expect(errors.IsNotFound(err)).To(g.BeTrue())
+	})
+
+	ginkgo.It("should generate a route for each ingress path", func() {
+		spec := synapsev1alpha1.SynapseSpec{
+			ServerName: "foo.bar",
+			Ingress: &synapsev1alpha1.SynapseIngress{
+				Host: "matrix.foo.bar",
+			},
+		}
+		instance := initFakeSynapse(t, name, ns, &spec)
+		routes := exposure.NewRoutes(newExposureForCR(instance), map[string]interface{}{"termination": "edge"})
+		g.Expect(routes).To(g.HaveLen(2))
+		route := routes[1]
+		g.Expect(route.GetName()).To(g.Equal(name + "-synapse-client"))
+		g.Expect(route.GetKind()).To(g.Equal("Route"))
+		g.Expect(route.Object["spec"]).To(g.HaveKeyWithValue("host", "matrix.foo.bar"))
+		g.Expect(route.Object["spec"]).To(g.HaveKeyWithValue("path", "/_synapse/client"))
+	})
+
+	ginkgo.It("should map ingress TLS secrets to Synapse", func() {
+		spec := synapsev1alpha1.SynapseSpec{
+			ServerName: "foo.bar",
+			Ingress: &synapsev1alpha1.SynapseIngress{
+				Host:          "matrix.foo.bar",
+				TLSSecretName: "matrix-tls",
+			},
+			WellKnown: &synapsev1alpha1.SynapseWellKnown{
+				Ingress: &synapsev1alpha1.SynapseIngress{
+					TLSSecretName: "well-known-tls",
+				},
+			},
+		}
+		instance := initFakeSynapse(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns)
+		expected := []reconcile.Request{{NamespacedName: types.NamespacedName{Name: name, Namespace: ns}}}
+		for _, secretName := range []string{"matrix-tls", "well-known-tls"} {
+			secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: ns}}
+			g.Expect(getSynapsesReferencingSecret(cl, handler.MapObject{Meta: secret, Object: secret})).To(g.Equal(expected))
+		}
+		other := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: ns}}
+		g.Expect(getSynapsesReferencingSecret(cl, handler.MapObject{Meta: other, Object: other})).To(g.BeEmpty())
+	})
+
+	ginkgo.It("should route worker endpoints", func() {
+		spec := synapsev1alpha1.SynapseSpec{
+			Ports: synapsev1alpha1.SynapsePorts{
+				HTTP: 8008,
+			},
+		}
+		instance := initFakeSynapse(t, name, ns, &spec)
+		workers := []runtime.Object{
+			&synapsev1alpha1.SynapseWorker{
+				ObjectMeta: metav1.ObjectMeta{Name: "media", Namespace: ns},
+				Spec: synapsev1alpha1.SynapseWorkerSpec{
+					Replicas: 1,
+					Synapse:  name,
+					Worker:   "synapse.app.media_repository",
+					Port:     8085,
+				},
+			},
+			&synapsev1alpha1.SynapseWorker{
+				ObjectMeta: metav1.ObjectMeta{Name: "generic", Namespace: ns},
+				Spec: synapsev1alpha1.SynapseWorkerSpec{
+					Replicas: 1,
+					Synapse:  name,
+					Worker:   "synapse.app.generic_worker",
+					Port:     8083,
+				},
+			},
+			&synapsev1alpha1.SynapseWorker{
+				ObjectMeta: metav1.ObjectMeta{Name: "generic-2", Namespace: ns},
+				Spec: synapsev1alpha1.SynapseWorkerSpec{
+					Replicas: 1,
+					Synapse:  name,
+					Worker:   "synapse.app.generic_worker",
+					Port:     8084,
+				},
+			},
+			&synapsev1alpha1.SynapseWorker{
+				ObjectMeta: metav1.ObjectMeta{Name: "pusher", Namespace: ns},
+				Spec: synapsev1alpha1.SynapseWorkerSpec{
+					Synapse: name,
+					Worker:  "synapse.app.pusher",
+				},
+			},
+			&synapsev1alpha1.SynapseWorker{
+				ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: ns},
+				Spec: synapsev1alpha1.SynapseWorkerSpec{
+					Synapse: "other-synapse",
+					Worker:  "synapse.app.federation_reader",
+					Port:    8083,
+				},
+			},
+		}
+		cl = initFakeClient(t, instance, name, ns, workers...)
+		cm := &corev1.ConfigMap{}
+		err := cl.Get(context.TODO(), types.NamespacedName{Name: instance.GetRoutingConfigMapName(), Namespace: ns}, cm)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		// Workers of the same app share an upstream, services are resolved at runtime
+		g.Expect(cm.Data["upstreams.conf"]).To(g.Equal("upstream generic_worker {\n" +
+			"    zone generic_worker 64k;\n" +
+			"    server generic-server.synapse.svc.cluster.local:8083 resolve;\n" +
+			"    server generic-2-server.synapse.svc.cluster.local:8084 resolve;\n" +
+			"}\n" +
+			"upstream media_repository {\n" +
+			"    zone media_repository 64k;\n" +
+			"    server media-server.synapse.svc.cluster.local:8085 resolve;\n" +
+			"}\n" +
+			"upstream homeserver {\n" +
+			"    zone homeserver 64k;\n" +
+			"    server example-synapse-service.synapse.svc.cluster.local:8008 resolve;\n" +
+			"}\n"))
+		routing := cm.Data["routing.conf"]
+		g.Expect(routing).To(g.HavePrefix("# synapse.app.generic_worker (generic, generic-2)\n" +
+			"location ~ ^/_matrix/client/(v2_alpha|r0)/sync$ {\n    set $upstream generic_worker;\n    proxy_pass http://$upstream;\n}\n"))
+		g.Expect(routing).To(g.ContainSubstring("# synapse.app.media_repository (media)\n" +
+			"location ~ ^/_matrix/media/ {\n    set $upstream media_repository;\n    proxy_pass http://$upstream;\n}\n"))
+		g.Expect(routing).To(g.HaveSuffix("# example-synapse\n" +
+			"location /_matrix {\n    set $upstream homeserver;\n    proxy_pass http://$upstream;\n}\n" +
+			"location /_synapse/client {\n    set $upstream homeserver;\n    proxy_pass http://$upstream;\n}\n"))
+		g.Expect(routing).NotTo(g.ContainSubstring("pusher"))
+		g.Expect(routing).NotTo(g.ContainSubstring("other"))
+
+		// Scaled down workers are not routed
+		media := &synapsev1alpha1.SynapseWorker{}
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: "media", Namespace: ns}, media)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		media.Spec.Replicas = 0
+		err = cl.Update(context.TODO(), media)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		reconcileSynapse(t, cl, name, ns)
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: instance.GetRoutingConfigMapName(), Namespace: ns}, cm)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(cm.Data["routing.conf"]).NotTo(g.ContainSubstring("media"))
+
+		// Routing is updated when workers are removed
+		media.Spec.Replicas = 1
+		err = cl.Update(context.TODO(), media)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		reconcileSynapse(t, cl, name, ns)
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: instance.GetRoutingConfigMapName(), Namespace: ns}, cm)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(cm.Data["routing.conf"]).To(g.ContainSubstring("media"))
+		err = cl.Delete(context.TODO(), media)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		reconcileSynapse(t, cl, name, ns)
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: instance.GetRoutingConfigMapName(), Namespace: ns}, cm)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(cm.Data["routing.conf"]).NotTo(g.ContainSubstring("media"))
+	})
+
+	ginkgo.It("should disable main process duties handled by workers", func() {
+		spec := synapsev1alpha1.SynapseSpec{
+			ServerName: "foo.bar",
+			Config: synapsev1alpha1.SynapseConfig{
+				Homeserver: "send_federation: true\n",
+			},
+		}
+		instance := initFakeSynapse(t, name, ns, &spec)
+		newWorker := func(name, app string, replicas int) *synapsev1alpha1.SynapseWorker {
+			return &synapsev1alpha1.SynapseWorker{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+				Spec: synapsev1alpha1.SynapseWorkerSpec{
+					Replicas: replicas,
+					Synapse:  instance.Name,
+					Worker:   app,
+				},
+			}
+		}
+		workers := []runtime.Object{
+			newWorker("federation-sender", "synapse.app.federation_sender", 1),
+			newWorker("pusher", "synapse.app.pusher", 2),
+			newWorker("media", "synapse.app.media_repository", 0),
+			newWorker("sync", "synapse.app.synchrotron", 1),
+		}
+		cl = initFakeClient(t, instance, name, ns, workers...)
+		homeserver := parseHomeserverConfig(t, getConfigMap(t, instance, cl, ns))
+		g.Expect(homeserver).To(g.HaveKeyWithValue("send_federation", false))
+		g.Expect(homeserver).To(g.HaveKeyWithValue("start_pushers", false))
+		g.Expect(homeserver).NotTo(g.HaveKey("enable_media_repo"))
+		g.Expect(homeserver).NotTo(g.HaveKey("notify_appservices"))
+		g.Expect(homeserver).NotTo(g.HaveKey("update_user_directory"))
+
+		// Settings are reverted when workers are removed
+		err := cl.Delete(context.TODO(), workers[0])
+		g.Expect(err).NotTo(g.HaveOccurred())
+		reconcileSynapse(t, cl, name, ns)
+		homeserver = parseHomeserverConfig(t, getConfigMap(t, instance, cl, ns))
+		g.Expect(homeserver).To(g.HaveKeyWithValue("send_federation", true))
+		g.Expect(homeserver).To(g.HaveKeyWithValue("start_pushers", false))
+	})
+
+	ginkgo.It("should deploy reverse proxy", func() {
+		spec := synapsev1alpha1.SynapseSpec{
+			ServerName: "foo.bar",
+			Ports: synapsev1alpha1.SynapsePorts{
+				HTTP: 8008,
+			},
+			Proxy:   &synapsev1alpha1.SynapseProxy{},
+			Ingress: &synapsev1alpha1.SynapseIngress{},
+		}
+		instance := initFakeSynapse(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns)
+		key := types.NamespacedName{Name: instance.GetProxyName(), Namespace: ns}
+
+		cm := &corev1.ConfigMap{}
+		err := cl.Get(context.TODO(), key, cm)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(cm.Data["default.conf"]).To(g.HavePrefix("resolver kube-dns.kube-system.svc.cluster.local;\n" +
+			"include /etc/nginx/routing/upstreams.conf;\n"))
+		g.Expect(cm.Data["default.conf"]).To(g.ContainSubstring("include /etc/nginx/routing/routing.conf;"))
+
+		deployment := &appsv1.Deployment{}
+		err = cl.Get(context.TODO(), key, deployment)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(*deployment.Spec.Replicas).To(g.Equal(int32(1)))
+		configHash := deployment.Spec.Template.Annotations[rollout.ConfigHashAnnotation]
+		container := deployment.Spec.Template.Spec.Containers[0]
+		g.Expect(container.Image).To(g.Equal(synapsev1alpha1.DefaultProxyImage))
+		g.Expect(container.VolumeMounts).To(g.Equal([]corev1.VolumeMount{
+			{Name: "config", MountPath: "/etc/nginx/conf.d"},
+			{Name: "routing", MountPath: "/etc/nginx/routing"},
+		}))
+		g.Expect(deployment.Spec.Template.Spec.Volumes[1].ConfigMap.Name).To(g.Equal(instance.GetRoutingConfigMapName()))
+
+		svc := &corev1.Service{}
+		err = cl.Get(context.TODO(), key, svc)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(svc.Spec.Selector).To(g.Equal(map[string]string{"app": instance.GetProxyName()}))
+
+		// Ingress sends traffic to the proxy
+		ingress := &networkingv1beta1.Ingress{}
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: instance.GetIngressName(), Namespace: ns}, ingress)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(ingress.Spec.Rules[0].HTTP.Paths[0].Backend.ServiceName).To(g.Equal(instance.GetProxyName()))
+
+		// Proxy is rolled out when worker routing changes
+		worker := &synapsev1alpha1.SynapseWorker{
+			ObjectMeta: metav1.ObjectMeta{Name: "sync", Namespace: ns},
+			Spec: synapsev1alpha1.SynapseWorkerSpec{
+				Replicas: 1,
+				Synapse:  name,
+				Worker:   "synapse.app.synchrotron",
+				Port:     8083,
+			},
+		}
+		err = cl.Create(context.TODO(), worker)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		reconcileSynapse(t, cl, name, ns)
+		err = cl.Get(context.TODO(), key, deployment)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(deployment.Spec.Template.Annotations[rollout.ConfigHashAnnotation]).NotTo(g.Equal(configHash))
+
+		// Services are resolved by the configured DNS server in the cluster domain
+		synapse := getSynapse(t, instance, cl, ns)
+		synapse.Spec.Proxy.Resolver = "dns-default.openshift-dns.svc.example.com"
+		synapse.Spec.Proxy.ClusterDomain = "example.com"
+		err = cl.Update(context.TODO(), synapse)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		reconcileSynapse(t, cl, name, ns)
+		err = cl.Get(context.TODO(), key, cm)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(cm.Data["default.conf"]).To(g.HavePrefix("resolver dns-default.openshift-dns.svc.example.com;\n"))
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: instance.GetRoutingConfigMapName(), Namespace: ns}, cm)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(cm.Data["upstreams.conf"]).To(g.ContainSubstring("server sync-server.synapse.svc.example.com:8083 resolve;\n"))
+
+		// Proxy is removed once disabled and ingress sends traffic to the homeserver
+		synapse = getSynapse(t, instance, cl, ns)
+		synapse.Spec.Proxy = nil
+		err = cl.Update(context.TODO(), synapse)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		reconcileSynapse(t, cl, name, ns)
+		expectNotFound(t, cl, instance.GetProxyName(), ns, &appsv1.Deployment{}, &corev1.Service{}, &corev1.ConfigMap{})
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: instance.GetIngressName(), Namespace: ns}, ingress)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(ingress.Spec.Rules[0].HTTP.Paths[0].Backend.ServiceName).To(g.Equal(instance.GetServiceName()))
+	})
+
+	ginkgo.It("should reconcile remaining resources and requeue when proxy config is not cached yet", func() {
+		spec := synapsev1alpha1.SynapseSpec{
+			ServerName: "foo.bar",
+			Ports: synapsev1alpha1.SynapsePorts{
+				HTTP: 8008,
+			},
+			Proxy: &synapsev1alpha1.SynapseProxy{},
+		}
+		instance := initFakeSynapse(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns)
+
+		err := cl.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: ns}, instance)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		instance.Spec.Ingress = &synapsev1alpha1.SynapseIngress{}
+		err = cl.Update(context.TODO(), instance)
+		g.Expect(err).NotTo(g.HaveOccurred())
+
+		stale := &staleCacheClient{Client: cl, name: instance.GetRoutingConfigMapName()}
+		r := &ReconcileSynapse{client: stale, reader: cl, scheme: scheme.Scheme, applier: apply.NewApplier(cl, scheme.Scheme)}
+		res, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: ns}})
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(res.Requeue).To(g.BeTrue())
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: instance.GetIngressName(), Namespace: ns}, &networkingv1beta1.Ingress{})
+		g.Expect(err).NotTo(g.HaveOccurred())
+	})
+
+	ginkgo.It("should serve well-known delegation", func() {
+		spec := synapsev1alpha1.SynapseSpec{
+			ServerName: "example.com",
+			Ports: synapsev1alpha1.SynapsePorts{
+				HTTP:  8008,
+				HTTPS: 8448,
+			},
+			Ingress: &synapsev1alpha1.SynapseIngress{
+				Host: "matrix.example.com",
+			},
+			WellKnown: &synapsev1alpha1.SynapseWellKnown{
+				Ingress: &synapsev1alpha1.SynapseIngress{},
+			},
+		}
+		instance := initFakeSynapse(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns)
+		key := types.NamespacedName{Name: instance.GetWellKnownName(), Namespace: ns}
+
+		cm := &corev1.ConfigMap{}
+		err := cl.Get(context.TODO(), key, cm)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(cm.Data[synapsev1alpha1.WellKnownServerKey]).To(g.MatchJSON(`{"m.server": "matrix.example.com:443"}`))
+		g.Expect(cm.Data[synapsev1alpha1.WellKnownClientKey]).To(g.MatchJSON(`{"m.homeserver": {"base_url": "https://matrix.example.com"}}`))
+
+		deployment := &appsv1.Deployment{}
+		err = cl.Get(context.TODO(), key, deployment)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(deployment.Spec.Template.Spec.Containers[0].VolumeMounts).To(g.Equal([]corev1.VolumeMount{
+			{Name: "config", MountPath: "/etc/nginx/conf.d"},
+			{Name: "well-known", MountPath: "/usr/share/nginx/.well-known/matrix"},
+		}))
+		configHash := deployment.Spec.Template.Annotations[rollout.ConfigHashAnnotation]
+
+		svc := &corev1.Service{}
+		err = cl.Get(context.TODO(), key, svc)
+		g.Expect(err).NotTo(g.HaveOccurred())
+
+		// Well-known is exposed on the server name
+		ingress := &networkingv1beta1.Ingress{}
+		err = cl.Get(context.TODO(), key, ingress)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(ingress.Spec.Rules[0].Host).To(g.Equal("example.com"))
+		g.Expect(ingress.Spec.Rules[0].HTTP.Paths[0].Path).To(g.Equal("/.well-known/matrix"))
+		g.Expect(ingress.Spec.Rules[0].HTTP.Paths[0].Backend.ServiceName).To(g.Equal(instance.GetWellKnownName()))
+
+		// Responder is rolled out when delegation changes
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: ns}, instance)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		instance.Spec.WellKnown.Server = "federation.example.com:8448"
+		err = cl.Update(context.TODO(), instance)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		reconcileSynapse(t, cl, name, ns)
+		err = cl.Get(context.TODO(), key, cm)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(cm.Data[synapsev1alpha1.WellKnownServerKey]).To(g.MatchJSON(`{"m.server": "federation.example.com:8448"}`))
+		err = cl.Get(context.TODO(), key, deployment)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(deployment.Spec.Template.Annotations[rollout.ConfigHashAnnotation]).NotTo(g.Equal(configHash))
+
+		// Ingress is removed once well-known is not exposed, other objects once it's disabled
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: ns}, instance)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		instance.Spec.WellKnown.Ingress = nil
+		err = cl.Update(context.TODO(), instance)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		reconcileSynapse(t, cl, name, ns)
+		expectNotFound(t, cl, instance.GetWellKnownName(), ns, &networkingv1beta1.Ingress{})
+		err = cl.Get(context.TODO(), key, deployment)
+		g.Expect(err).NotTo(g.HaveOccurred())
+
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: ns}, instance)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		instance.Spec.WellKnown = nil
+		err = cl.Update(context.TODO(), instance)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		reconcileSynapse(t, cl, name, ns)
+		expectNotFound(t, cl, instance.GetWellKnownName(), ns, &appsv1.Deployment{}, &corev1.Service{}, &corev1.ConfigMap{})
 	})
 
 	ginkgo.It("should create configmap", func() {
 		spec := synapsev1alpha1.SynapseSpec{
+			ServerName: "foo.bar",
 			Config: synapsev1alpha1.SynapseConfig{
-				Homeserver: "foo",
+				Homeserver: "server_name: baz\nreport_stats: true\n",
 				Logging:    "bar",
 			},
 		}
@@ -82,9 +983,181 @@ var _ = ginkgo.Describe("[synapse]", func() {
 		cm := getConfigMap(t, instance, cl, ns)
 		g.Expect(cm.Name).To(g.Equal(instance.GetConfigMapName()))
 		g.Expect(cm.Labels).To(g.Equal(map[string]string{"app": name}))
-		g.Expect(cm.Data).To(g.Equal(map[string]string{
-			"homeserver": "foo",
-			"logging":    "bar",
+		g.Expect(cm.Data).To(g.HaveKeyWithValue("logging", "bar"))
+
+		homeserver := parseHomeserverConfig(t, cm)
+		g.Expect(homeserver).To(g.HaveKeyWithValue("server_name", "foo.bar"))
+		g.Expect(homeserver).To(g.HaveKeyWithValue("report_stats", true))
+		g.Expect(homeserver).To(g.HaveKeyWithValue("log_config", "/synapse/config/foo.bar.log.config"))
+		g.Expect(homeserver).To(g.HaveKeyWithValue("signing_key_path", "/synapse/keys/foo.bar.signing.key"))
+	})
+
+	ginkgo.It("should generate logging config", func() {
+		spec := synapsev1alpha1.SynapseSpec{
+			ServerName: "foo.bar",
+			Config: synapsev1alpha1.SynapseConfig{
+				LoggingSettings: &synapsev1alpha1.SynapseLoggingSettings{
+					Level: "WARNING",
+					Loggers: map[string]string{
+						"synapse.storage.SQL": "DEBUG",
+					},
+				},
+			},
+		}
+		instance := initFakeSynapse(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns)
+		cm := getConfigMap(t, instance, cl, ns)
+
+		logging := map[string]interface{}{}
+		err := yaml.Unmarshal([]byte(cm.Data["logging"]), &logging)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(logging).To(g.HaveKeyWithValue("root", map[string]interface{}{
+			"level":    "WARNING",
+			"handlers": []interface{}{"console"},
+		}))
+		g.Expect(logging).To(g.HaveKeyWithValue("loggers", map[string]interface{}{
+			"synapse.storage.SQL": map[string]interface{}{"level": "DEBUG"},
+		}))
+		g.Expect(logging).To(g.HaveKeyWithValue("formatters", map[string]interface{}{
+			"default": map[string]interface{}{
+				"format": "%(asctime)s - %(name)s - %(lineno)d - %(levelname)s - %(request)s - %(message)s",
+			},
+		}))
+
+		// Worker logs are prefixed with worker name and could be switched to JSON
+		worker := &synapsev1alpha1.SynapseWorker{
+			ObjectMeta: metav1.ObjectMeta{Name: "sync", Namespace: ns},
+		}
+		workerLogging, err := worker.GenerateLoggingConfig(instance)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		err = yaml.Unmarshal(workerLogging, &logging)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(logging["formatters"]).To(g.HaveKeyWithValue("default", map[string]interface{}{
+			"format": "sync - %(asctime)s - %(name)s - %(lineno)d - %(levelname)s - %(request)s - %(message)s",
+		}))
+		worker.Spec.Logging = &synapsev1alpha1.SynapseLoggingSettings{
+			Format: synapsev1alpha1.LoggingFormatJSON,
+		}
+		workerLogging, err = worker.GenerateLoggingConfig(instance)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(string(workerLogging)).To(g.ContainSubstring("class: synapse.logging.TerseJsonFormatter"))
+		g.Expect(string(workerLogging)).To(g.ContainSubstring("level: INFO"))
+	})
+
+	ginkgo.It("should render structured homeserver settings", func() {
+		spec := synapsev1alpha1.SynapseSpec{
+			ServerName: "foo.bar",
+			Config: synapsev1alpha1.SynapseConfig{
+				Homeserver: "report_stats: true\n",
+				Settings: &synapsev1alpha1.SynapseSettings{
+					Database: &synapsev1alpha1.SynapseDatabaseSettings{
+						Name: "sqlite3",
+						Args: map[string]string{"database": "/db/homeserver.db"},
+					},
+					Registration: &synapsev1alpha1.SynapseRegistrationSettings{
+						Enabled: true,
+					},
+					Federation: &synapsev1alpha1.SynapseFederationSettings{
+						TrustedKeyServers: []string{"matrix.org"},
+					},
+					Caches: &synapsev1alpha1.SynapseCachesSettings{
+						GlobalFactor: "0.5",
+					},
+				},
+				Overrides: &runtime.RawExtension{
+					Raw: []byte(`{"report_stats": null, "database": {"args": {"cp_max": 5}}, "caches": {"per_cache_factors": {"get_users_who_share_room_with_user": 2}}}`),
+				},
+			},
+		}
+		instance := initFakeSynapse(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns)
+		cm := getConfigMap(t, instance, cl, ns)
+
+		homeserver := parseHomeserverConfig(t, cm)
+		g.Expect(homeserver).NotTo(g.HaveKey("report_stats"))
+		g.Expect(homeserver).To(g.HaveKeyWithValue("enable_registration", true))
+		g.Expect(homeserver).To(g.HaveKeyWithValue("database", map[string]interface{}{
+			"name": "sqlite3",
+			"args": map[string]interface{}{
+				"database": "/db/homeserver.db",
+				"cp_max":   float64(5),
+			},
+		}))
+		g.Expect(homeserver).To(g.HaveKeyWithValue("trusted_key_servers", []interface{}{
+			map[string]interface{}{"server_name": "matrix.org"},
+		}))
+		g.Expect(homeserver).To(g.HaveKeyWithValue("caches", map[string]interface{}{
+			"global_factor": 0.5,
+			"per_cache_factors": map[string]interface{}{
+				"get_users_who_share_room_with_user": float64(2),
+			},
+		}))
+	})
+
+	ginkgo.It("should not allow overriding volume paths", func() {
+		spec := synapsev1alpha1.SynapseSpec{
+			ServerName: "foo.bar",
+			Config: synapsev1alpha1.SynapseConfig{
+				Overrides: &runtime.RawExtension{
+					Raw: []byte(`{"server_name": "baz.qux", "log_config": "/data/log.yaml", "signing_key_path": "/data/signing.key"}`),
+				},
+			},
+		}
+		instance := initFakeSynapse(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns)
+		cm := getConfigMap(t, instance, cl, ns)
+
+		homeserver := parseHomeserverConfig(t, cm)
+		g.Expect(homeserver).To(g.HaveKeyWithValue("server_name", "foo.bar"))
+		g.Expect(homeserver["log_config"]).To(g.HavePrefix(synapsev1alpha1.ConfigMountPath))
+		g.Expect(homeserver["signing_key_path"]).To(g.HavePrefix(synapsev1alpha1.KeysMountPath))
+	})
+
+	ginkgo.It("should generate listeners from ports", func() {
+		spec := synapsev1alpha1.SynapseSpec{
+			ServerName: "foo.bar",
+			Ports: synapsev1alpha1.SynapsePorts{
+				HTTP:        8008,
+				HTTPS:       8448,
+				Replication: 9092,
+				Metrics:     9093,
+			},
+			Config: synapsev1alpha1.SynapseConfig{
+				Settings: &synapsev1alpha1.SynapseSettings{
+					Listeners: []synapsev1alpha1.SynapseListener{
+						{Port: 8008, Type: "http"},
+						{Port: 9000, Type: "manhole"},
+					},
+				},
+			},
+		}
+		instance := initFakeSynapse(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns)
+		cm := getConfigMap(t, instance, cl, ns)
+
+		homeserver := parseHomeserverConfig(t, cm)
+		listeners, ok := homeserver["listeners"].([]interface{})
+		g.Expect(ok).To(g.BeTrue())
+		ports := []float64{}
+		for _, listener := range listeners {
+			ports = append(ports, listener.(map[string]interface{})["port"].(float64))
+		}
+		g.Expect(ports).To(g.Equal([]float64{8008, 8448, 9092, 9093, 9000}))
+		g.Expect(listeners[0]).To(g.HaveKeyWithValue("x_forwarded", true))
+		g.Expect(listeners[1]).To(g.HaveKeyWithValue("tls", true))
+		g.Expect(listeners[2]).To(g.HaveKeyWithValue("type", "replication"))
+		g.Expect(listeners[3]).To(g.HaveKeyWithValue("type", "metrics"))
+		g.Expect(homeserver).To(g.HaveKeyWithValue("tls_certificate_path", "/synapse/keys/tls.crt"))
+		g.Expect(homeserver).To(g.HaveKeyWithValue("tls_private_key_path", "/synapse/keys/tls.key"))
+		g.Expect(homeserver).To(g.HaveKeyWithValue("enable_metrics", true))
+
+		svc := getService(t, instance, cl, ns)
+		g.Expect(svc.Spec.Ports).To(g.HaveLen(4))
+		g.Expect(svc.Spec.Ports[3]).To(g.Equal(corev1.ServicePort{
+			Name:       "metrics",
+			Protocol:   corev1.ProtocolTCP,
+			TargetPort: intstr.IntOrString{Type: intstr.String, StrVal: "metrics"},
+			Port:       int32(9093),
 		}))
 	})
 
@@ -188,6 +1261,10 @@ var _ = ginkgo.Describe("[synapse]", func() {
 								Key:  "key",
 								Path: "tls.key",
 							},
+							{
+								Key:  "secrets.yaml",
+								Path: "secrets.yaml",
+							},
 						},
 						DefaultMode: &mode,
 					},
@@ -229,4 +1306,180 @@ var _ = ginkgo.Describe("[synapse]", func() {
 		}))
 	})
 
+	ginkgo.It("should run homeserver with config paths in default image", func() {
+		spec := synapsev1alpha1.SynapseSpec{
+			ServerName: "foo.bar",
+		}
+		instance := initFakeSynapse(t, name, ns, &spec)
+		instance.Default()
+		// Image is defaulted even if the webhook didn't set it
+		instance.Spec.Image = ""
+		cl = initFakeClient(t, instance, name, ns)
+		dep := getDeployment(t, instance, cl, ns)
+
+		container := dep.Spec.Template.Spec.Containers[0]
+		g.Expect(container.Image).To(g.Equal(synapsev1alpha1.DefaultImage))
+		g.Expect(container.Command).To(g.Equal([]string{
+			"python", "-m", "synapse.app.homeserver",
+			"--config-path", "/synapse/config/homeserver.yaml",
+			"--config-path", "/synapse/keys/secrets.yaml",
+		}))
+		g.Expect(container.Args).To(g.BeEmpty())
+	})
+
+	ginkgo.It("should roll out deployment only when config changes", func() {
+		spec := synapsev1alpha1.SynapseSpec{
+			ServerName: "foo.bar",
+			Config: synapsev1alpha1.SynapseConfig{
+				Homeserver: "report_stats: true\n",
+			},
+		}
+		instance := initFakeSynapse(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns)
+		deployment := getDeployment(t, instance, cl, ns)
+		configHash := deployment.Spec.Template.Annotations[rollout.ConfigHashAnnotation]
+		g.Expect(configHash).To(g.HaveLen(64))
+
+		// Other annotations are kept and hash is stable across reconciles
+		deployment.Spec.Template.Annotations["kubectl.kubernetes.io/restartedAt"] = "now"
+		err := cl.Update(context.TODO(), deployment)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		reconcileSynapse(t, cl, name, ns)
+		deployment = getDeployment(t, instance, cl, ns)
+		g.Expect(deployment.Spec.Template.Annotations).To(g.Equal(map[string]string{
+			rollout.ConfigHashAnnotation:        configHash,
+			"kubectl.kubernetes.io/restartedAt": "now",
+		}))
+
+		// Config change updates the hash, reverting it restores the original one
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: ns}, instance)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		instance.Spec.Config.Homeserver = "report_stats: false\n"
+		err = cl.Update(context.TODO(), instance)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		reconcileSynapse(t, cl, name, ns)
+		deployment = getDeployment(t, instance, cl, ns)
+		g.Expect(deployment.Spec.Template.Annotations[rollout.ConfigHashAnnotation]).NotTo(g.Equal(configHash))
+		g.Expect(deployment.Spec.Template.Annotations).To(g.HaveKeyWithValue("kubectl.kubernetes.io/restartedAt", "now"))
+
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: ns}, instance)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		instance.Spec.Config.Homeserver = "report_stats: true\n"
+		err = cl.Update(context.TODO(), instance)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		reconcileSynapse(t, cl, name, ns)
+		deployment = getDeployment(t, instance, cl, ns)
+		g.Expect(deployment.Spec.Template.Annotations[rollout.ConfigHashAnnotation]).To(g.Equal(configHash))
+	})
+
+	ginkgo.It("should leave fields managed by others", func() {
+		spec := synapsev1alpha1.SynapseSpec{
+			ServerName: "foo.bar",
+		}
+		instance := initFakeSynapse(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns)
+
+		// Replicas are scaled by an autoscaler
+		deployment := getDeployment(t, instance, cl, ns)
+		replicas := int32(3)
+		deployment.Spec.Replicas = &replicas
+		err := cl.Update(context.TODO(), deployment)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		cl.(*applyfake.Client).ForeignFields["Deployment/"+deployment.Name] = []string{".spec.replicas"}
+		reconcileSynapse(t, cl, name, ns)
+
+		deployment = getDeployment(t, instance, cl, ns)
+		g.Expect(deployment.Spec.Replicas).To(g.Equal(&replicas))
+		found := &synapsev1alpha1.Synapse{}
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: ns}, found)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(found.Status.Conditions.IsTrueFor(synapsev1alpha1.SynapseConditionFieldConflict)).To(g.BeTrue())
+		condition := found.Status.Conditions.GetCondition(synapsev1alpha1.SynapseConditionFieldConflict)
+		g.Expect(condition.Message).To(g.ContainSubstring(".spec.replicas"))
+
+		// Conflict is cleared once the field is no longer managed by others
+		delete(cl.(*applyfake.Client).ForeignFields, "Deployment/"+deployment.Name)
+		reconcileSynapse(t, cl, name, ns)
+		deployment = getDeployment(t, instance, cl, ns)
+		g.Expect(*deployment.Spec.Replicas).To(g.Equal(int32(1)))
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: ns}, found)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(found.Status.Conditions.IsFalseFor(synapsev1alpha1.SynapseConditionFieldConflict)).To(g.BeTrue())
+	})
+
+	ginkgo.It("should apply pod template overrides", func() {
+		runAsNonRoot := true
+		resources := corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceMemory: resource.MustParse("512Mi"),
+			},
+			Limits: corev1.ResourceList{
+				corev1.ResourceMemory: resource.MustParse("1Gi"),
+			},
+		}
+		tolerations := []corev1.Toleration{
+			{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "matrix", Effect: corev1.TaintEffectNoSchedule},
+		}
+		spec := synapsev1alpha1.SynapseSpec{
+			ServerName: "foo.bar",
+			Redis:      &synapsev1alpha1.SynapseRedis{},
+			PodTemplate: &common.PodTemplate{
+				Resources:                &resources,
+				NodeSelector:             map[string]string{"node-role.kubernetes.io/matrix": ""},
+				Tolerations:              tolerations,
+				PriorityClassName:        "matrix",
+				SecurityContext:          &corev1.PodSecurityContext{RunAsNonRoot: &runAsNonRoot},
+				ContainerSecurityContext: &corev1.SecurityContext{RunAsNonRoot: &runAsNonRoot},
+			},
+		}
+		instance := initFakeSynapse(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns)
+		deployment := getDeployment(t, instance, cl, ns)
+		pod := deployment.Spec.Template.Spec
+		g.Expect(pod.NodeSelector).To(g.Equal(map[string]string{"node-role.kubernetes.io/matrix": ""}))
+		g.Expect(pod.Tolerations).To(g.Equal(tolerations))
+		g.Expect(pod.PriorityClassName).To(g.Equal("matrix"))
+		g.Expect(pod.SecurityContext).To(g.Equal(&corev1.PodSecurityContext{RunAsNonRoot: &runAsNonRoot}))
+		g.Expect(pod.Containers[0].Resources).To(g.Equal(resources))
+		g.Expect(pod.Containers[0].SecurityContext).To(g.Equal(&corev1.SecurityContext{RunAsNonRoot: &runAsNonRoot}))
+		for _, container := range pod.InitContainers {
+			g.Expect(container.SecurityContext).To(g.Equal(&corev1.SecurityContext{RunAsNonRoot: &runAsNonRoot}))
+		}
+
+		// Auxiliary pods are scheduled the same way, but don't get homeserver resources and security settings
+		redis := &appsv1.Deployment{}
+		err := cl.Get(context.TODO(), types.NamespacedName{Name: instance.GetRedisName(), Namespace: ns}, redis)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(redis.Spec.Template.Spec.Tolerations).To(g.Equal(tolerations))
+		g.Expect(redis.Spec.Template.Spec.PriorityClassName).To(g.Equal("matrix"))
+		g.Expect(redis.Spec.Template.Spec.SecurityContext).To(g.BeNil())
+		g.Expect(redis.Spec.Template.Spec.Containers[0].SecurityContext).To(g.BeNil())
+		g.Expect(redis.Spec.Template.Spec.Containers[0].Resources).To(g.Equal(corev1.ResourceRequirements{}))
+
+		// Manual changes are reverted
+		deployment.Spec.Template.Spec.Tolerations = nil
+		deployment.Spec.Template.Spec.Containers[0].Resources = corev1.ResourceRequirements{}
+		err = cl.Update(context.TODO(), deployment)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		reconcileSynapse(t, cl, name, ns)
+		deployment = getDeployment(t, instance, cl, ns)
+		g.Expect(deployment.Spec.Template.Spec.Tolerations).To(g.Equal(tolerations))
+		g.Expect(deployment.Spec.Template.Spec.Containers[0].Resources).To(g.Equal(resources))
+	})
+
+	ginkgo.It("should report status", func() {
+		spec := synapsev1alpha1.SynapseSpec{
+			ServerName: "foo.bar",
+		}
+		instance := initFakeSynapse(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns)
+		found := getSynapse(t, instance, cl, ns)
+		g.Expect(found.Status.Phase).To(g.Equal(synapsev1alpha1.SynapsePhasePending))
+		g.Expect(found.Status.ReadyReplicas).To(g.Equal(int32(0)))
+		g.Expect(found.Status.LastError).To(g.BeEmpty())
+		g.Expect(found.Status.Conditions.IsFalseFor(synapsev1alpha1.SynapseConditionReady)).To(g.BeTrue())
+		g.Expect(found.Status.Conditions.IsTrueFor(synapsev1alpha1.SynapseConditionProgressing)).To(g.BeTrue())
+		g.Expect(found.Status.Conditions.IsFalseFor(synapsev1alpha1.SynapseConditionDegraded)).To(g.BeTrue())
+	})
+
 })
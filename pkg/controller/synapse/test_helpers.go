@@ -2,10 +2,13 @@ package synapse
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -13,8 +16,11 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
 
 	synapsev1alpha1 "github.com/vrutkovs/synapse-operator/pkg/apis/synapse/v1alpha1"
+	"github.com/vrutkovs/synapse-operator/pkg/controller/apply"
+	applyfake "github.com/vrutkovs/synapse-operator/pkg/controller/apply/fake"
 
 	g "github.com/onsi/gomega"
 )
@@ -29,14 +35,20 @@ func initFakeSynapse(t *testing.T, name, ns string, spec *synapsev1alpha1.Synaps
 	}
 }
 
-func initFakeClient(t *testing.T, synapse *synapsev1alpha1.Synapse, name, ns string) client.Client {
+func initFakeClient(t *testing.T, synapse *synapsev1alpha1.Synapse, name, ns string, extraObjs ...runtime.Object) client.Client {
 	objs := []runtime.Object{synapse}
 	s := scheme.Scheme
-	s.AddKnownTypes(synapsev1alpha1.SchemeGroupVersion, objs...)
+	s.AddKnownTypes(synapsev1alpha1.SchemeGroupVersion, synapse, &synapsev1alpha1.SynapseList{}, &synapsev1alpha1.SynapseWorker{}, &synapsev1alpha1.SynapseWorkerList{})
+	objs = append(objs, extraObjs...)
 
 	// Reconcile
-	cl := fake.NewFakeClientWithScheme(s, objs...)
-	r := &ReconcileSynapse{client: cl, scheme: s}
+	cl := applyfake.NewClient(fake.NewFakeClientWithScheme(s, objs...), s)
+	reconcileSynapse(t, cl, name, ns)
+	return cl
+}
+
+func reconcileSynapse(t *testing.T, cl client.Client, name, ns string) {
+	r := &ReconcileSynapse{client: cl, reader: cl, scheme: scheme.Scheme, applier: apply.NewApplier(cl, scheme.Scheme)}
 	req := reconcile.Request{
 		NamespacedName: types.NamespacedName{
 			Name:      name,
@@ -46,7 +58,46 @@ func initFakeClient(t *testing.T, synapse *synapsev1alpha1.Synapse, name, ns str
 	res, err := r.Reconcile(req)
 	g.Expect(err).NotTo(g.HaveOccurred(), "failed to reconcile")
 	g.Expect(res).To(g.Equal(reconcile.Result{}), "reconcile did not return an empty Result")
-	return cl
+}
+
+// failingGetClient fails to get objects with the given name
+type failingGetClient struct {
+	client.Client
+	name string
+}
+
+func (c *failingGetClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	if key.Name == c.name {
+		return errors.NewServiceUnavailable("apiserver is unavailable")
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+// staleCacheClient doesn't find objects with the given name, as a cache which hasn't caught up yet
+type staleCacheClient struct {
+	client.Client
+	name string
+}
+
+func (c *staleCacheClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	if key.Name == c.name {
+		return errors.NewNotFound(corev1.Resource("secrets"), key.Name)
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+// forbiddenPatchClient rejects patches of objects with the given name, as apiserver does
+// when a claim can't be expanded
+type forbiddenPatchClient struct {
+	client.Client
+	name string
+}
+
+func (c *forbiddenPatchClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if accessor, err := meta.Accessor(obj); err == nil && accessor.GetName() == c.name {
+		return errors.NewForbidden(corev1.Resource("persistentvolumeclaims"), c.name, fmt.Errorf("only dynamically provisioned pvc can be resized"))
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
 }
 
 func getSecret(t *testing.T, synapse *synapsev1alpha1.Synapse, cl client.Client, ns string) *corev1.Secret {
@@ -76,3 +127,32 @@ func getDeployment(t *testing.T, synapse *synapsev1alpha1.Synapse, cl client.Cli
 	g.Expect(err).NotTo(g.HaveOccurred(), "failed to get deployment")
 	return dep
 }
+
+func getPVC(t *testing.T, synapse *synapsev1alpha1.Synapse, cl client.Client, ns string) *corev1.PersistentVolumeClaim {
+	pvc := &corev1.PersistentVolumeClaim{}
+	err := cl.Get(context.TODO(), types.NamespacedName{Name: synapse.GetMediaStorePVCName(), Namespace: ns}, pvc)
+	g.Expect(err).NotTo(g.HaveOccurred(), "failed to get persistent volume claim")
+	return pvc
+}
+
+func getSynapse(t *testing.T, synapse *synapsev1alpha1.Synapse, cl client.Client, ns string) *synapsev1alpha1.Synapse {
+	found := &synapsev1alpha1.Synapse{}
+	err := cl.Get(context.TODO(), types.NamespacedName{Name: synapse.Name, Namespace: ns}, found)
+	g.Expect(err).NotTo(g.HaveOccurred(), "failed to get synapse")
+	return found
+}
+
+func parseHomeserverConfig(t *testing.T, cm *corev1.ConfigMap) map[string]interface{} {
+	homeserver := map[string]interface{}{}
+	err := yaml.Unmarshal([]byte(cm.Data["homeserver"]), &homeserver)
+	g.Expect(err).NotTo(g.HaveOccurred(), "failed to parse homeserver config")
+	return homeserver
+}
+
+// expectNotFound checks that objects with the name don't exist
+func expectNotFound(t *testing.T, cl client.Client, name, ns string, objs ...runtime.Object) {
+	for _, obj := range objs {
+		err := cl.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: ns}, obj)
+		g.Expect(errors.IsNotFound(err)).To(g.BeTrue(), "%T %s is not removed", obj, name)
+	}
+}
@@ -0,0 +1,132 @@
+package synapse
+
+import (
+	"fmt"
+
+	"github.com/go-logr/logr"
+	synapsev1alpha1 "github.com/vrutkovs/synapse-operator/pkg/apis/synapse/v1alpha1"
+	"github.com/vrutkovs/synapse-operator/pkg/controller/exposure"
+	"github.com/vrutkovs/synapse-operator/pkg/controller/rollout"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	wellKnownPath         = "/.well-known/matrix"
+	wellKnownRoot         = "/usr/share/nginx"
+	wellKnownServerConfig = `server {
+    listen %d;
+
+    location = /healthz {
+        return 200;
+    }
+
+    location %s/ {
+        root %s;
+        default_type application/json;
+        add_header Access-Control-Allow-Origin *;
+    }
+}
+`
+)
+
+// reconcileWellKnown creates or updates well-known responder configmap, deployment and service
+// and exposes it via ingress if requested, these are removed once disabled. Responder pods are rolled out
+// when delegation files change
+func (r *ReconcileSynapse) reconcileWellKnown(request reconcile.Request, instance *synapsev1alpha1.Synapse, reqLogger logr.Logger) (reconcile.Result, error) {
+	if instance.Spec.WellKnown == nil {
+		if err := r.deleteWellKnownExposure(instance, reqLogger); err != nil {
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{}, r.deleteAuxiliaryObjects(instance, instance.GetWellKnownName(), reqLogger)
+	}
+
+	configMap, err := newWellKnownConfigMapForCR(instance)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	result, err := r.reconcileAuxiliaryConfigMap(instance, configMap, reqLogger)
+	if err != nil {
+		return result, err
+	}
+
+	deployment := newWellKnownDeploymentForCR(instance)
+	rollout.SetConfigHash(&deployment.Spec.Template, rollout.ConfigHash([]*corev1.ConfigMap{configMap}, nil))
+	result, err = r.reconcileAuxiliaryDeployment(instance, deployment, reqLogger)
+	if err != nil {
+		return result, err
+	}
+
+	result, err = r.reconcileAuxiliaryService(instance, newAuxiliaryService(instance, instance.GetWellKnownName()), reqLogger)
+	if err != nil {
+		return result, err
+	}
+
+	ingressSpec := instance.Spec.WellKnown.Ingress
+	if ingressSpec == nil {
+		return reconcile.Result{}, r.deleteWellKnownExposure(instance, reqLogger)
+	}
+	e := newExposure(instance, instance.GetWellKnownName(), ingressSpec, instance.GetWellKnownHost(), []string{wellKnownPath}, instance.GetWellKnownName())
+	if err := r.getExposureReconciler().Reconcile(instance, e, reqLogger); err != nil {
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{}, nil
+}
+
+// deleteWellKnownExposure removes well-known Ingress or Route once it's disabled
+func (r *ReconcileSynapse) deleteWellKnownExposure(instance *synapsev1alpha1.Synapse, reqLogger logr.Logger) error {
+	e := &exposure.Exposure{Name: instance.GetWellKnownName(), Namespace: instance.Namespace, Paths: []string{wellKnownPath}}
+	return r.getExposureReconciler().Delete(instance, e, reqLogger)
+}
+
+// newWellKnownConfigMapForCR returns nginx server config along with delegation files
+func newWellKnownConfigMapForCR(cr *synapsev1alpha1.Synapse) (*corev1.ConfigMap, error) {
+	server, err := cr.GenerateWellKnownServer()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate well-known server: %w", err)
+	}
+	client, err := cr.GenerateWellKnownClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate well-known client: %w", err)
+	}
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cr.GetWellKnownName(),
+			Namespace: cr.Namespace,
+			Labels:    getAuxiliaryLabels(cr.GetWellKnownName()),
+		},
+		Data: map[string]string{
+			"default.conf":                     fmt.Sprintf(wellKnownServerConfig, nginxPort, wellKnownPath, wellKnownRoot),
+			synapsev1alpha1.WellKnownServerKey: server,
+			synapsev1alpha1.WellKnownClientKey: client,
+		},
+	}, nil
+}
+
+// newWellKnownDeploymentForCR returns nginx deployment, server config and delegation files
+// are mounted from the same configmap
+func newWellKnownDeploymentForCR(cr *synapsev1alpha1.Synapse) *appsv1.Deployment {
+	volumes := []corev1.Volume{
+		newConfigMapVolume("config", cr.GetWellKnownName(), []corev1.KeyToPath{
+			{Key: "default.conf", Path: "default.conf"},
+		}),
+		newConfigMapVolume("well-known", cr.GetWellKnownName(), []corev1.KeyToPath{
+			{Key: synapsev1alpha1.WellKnownServerKey, Path: synapsev1alpha1.WellKnownServerKey},
+			{Key: synapsev1alpha1.WellKnownClientKey, Path: synapsev1alpha1.WellKnownClientKey},
+		}),
+	}
+	volumeMounts := []corev1.VolumeMount{
+		{
+			Name:      "config",
+			MountPath: "/etc/nginx/conf.d",
+		},
+		{
+			Name:      "well-known",
+			MountPath: wellKnownRoot + wellKnownPath,
+		},
+	}
+	return newNginxDeployment(cr, cr.GetWellKnownName(), cr.Spec.WellKnown.Image, 1, volumes, volumeMounts)
+}
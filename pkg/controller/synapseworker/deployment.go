@@ -2,12 +2,11 @@ package synapseworker
 
 import (
 	"context"
-	"fmt"
-	"reflect"
-	"time"
+	"path"
 
 	"github.com/go-logr/logr"
 	synapsev1alphav1 "github.com/vrutkovs/synapse-operator/pkg/apis/synapse/v1alpha1"
+	"github.com/vrutkovs/synapse-operator/pkg/controller/rollout"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -18,146 +17,62 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
-func (r *ReconcileSynapseWorker) reconcileDeployment(request reconcile.Request, instance *synapsev1alphav1.SynapseWorker, reqLogger logr.Logger, s *synapsev1alphav1.Synapse) (reconcile.Result, bool, error) {
-
-	// Check if this Deployment already exists
-	deployment := newDeploymentForCR(instance, s)
-
-	// Set SynapseWorker instance as the owner and controller
-	if err := controllerutil.SetControllerReference(instance, deployment, r.scheme); err != nil {
-		return reconcile.Result{}, false, err
+// getConfigHashes returns hashes of worker config and of mounted Synapse config and secret
+func (r *ReconcileSynapseWorker) getConfigHashes(instance *synapsev1alphav1.SynapseWorker, s *synapsev1alphav1.Synapse) (string, string, error) {
+	configHash, err := rollout.GetConfigHash(r.client, instance.Namespace, []string{instance.GetConfigMapName()}, nil)
+	if err != nil {
+		return "", "", err
 	}
-
-	found := &appsv1.Deployment{}
-	err := r.client.Get(context.TODO(), types.NamespacedName{Name: deployment.Name, Namespace: deployment.Namespace}, found)
-	if err != nil && errors.IsNotFound(err) {
-		reqLogger.Info("Creating a new Deployment", "Deployment.Namespace", deployment.Namespace, "Deployment.Name", deployment.Name)
-		err = r.client.Create(context.TODO(), deployment)
-		if err != nil {
-			return reconcile.Result{}, false, err
-		}
-
-		// Deployment created successfully - don't requeue
-		return reconcile.Result{}, true, nil
-	} else if err != nil {
-		// Unknown error - requeue
-		reqLogger.Info("Deployment reconcile error", "DeploymentDeployment.Namespace", found.Namespace, "Deployment.Name", found.Name, "Error", err)
-		return reconcile.Result{Requeue: true}, false, nil
-	} else if err == nil {
-		expectedSpec := getExpectedDeploymentSpec(instance, s)
-		// Check if deployment needs to be updated
-		if deploymentNeedsUpdate(&found.Spec, &expectedSpec, reqLogger) {
-			found.ObjectMeta = deployment.ObjectMeta
-			controllerutil.SetControllerReference(instance, found, r.scheme)
-			found.Spec = expectedSpec
-			err = r.client.Update(context.TODO(), found)
-			if err != nil {
-				return reconcile.Result{Requeue: true}, false, err
-			}
-			reqLogger.Info("Deployment spec updated", "Deployment.Namespace", found.Namespace, "Deployment.Name", found.Name)
-			return reconcile.Result{}, false, nil
-		}
+	synapseConfigHash, err := rollout.GetConfigHash(r.client, s.Namespace, []string{s.GetConfigMapName()}, []string{s.GetSecretName()})
+	if err != nil {
+		return "", "", err
 	}
-
-	// Deployment already exists - don't requeue
-	reqLogger.Info("Skip reconcile: Deployment already exists", "Deployment.Namespace", found.Namespace, "Deployment.Name", found.Name)
-	return reconcile.Result{}, false, nil
+	return configHash, synapseConfigHash, nil
 }
 
-func deploymentNeedsUpdate(actual, expected *appsv1.DeploymentSpec, reqLogger logr.Logger) bool {
-	// Replicas
-	if actual.Replicas != nil && expected.Replicas != nil && *actual.Replicas != *expected.Replicas {
-		reqLogger.Info("Deployment replicas mismatch found", "actual", actual.Replicas, "expected", expected.Replicas)
-		return true
-	}
+func (r *ReconcileSynapseWorker) reconcileDeployment(request reconcile.Request, instance *synapsev1alphav1.SynapseWorker, reqLogger logr.Logger, s *synapsev1alphav1.Synapse) (reconcile.Result, error) {
 
-	// Template Labels
-	if !reflect.DeepEqual(actual.Template.ObjectMeta.Labels, expected.Template.ObjectMeta.Labels) {
-		reqLogger.Info("Deployment label mismatch found", "actual", actual.Template.ObjectMeta.Labels, "expected", expected.Template.ObjectMeta.Labels)
-		return true
-	}
-
-	// Template Spec Volumes
-	if !reflect.DeepEqual(actual.Template.Spec.Volumes, expected.Template.Spec.Volumes) {
-		reqLogger.Info("Deployment volume mismatch found", "actual", actual.Template.Spec.Volumes, "expected", expected.Template.Spec.Volumes)
-		return true
-	}
-
-	// Template Spec Containers length
-	if len(actual.Template.Spec.Containers) != len(expected.Template.Spec.Containers) {
-		reqLogger.Info("Deployment container number mismatch found", "actual", len(actual.Template.Spec.Containers), "expected", expected.Template.Spec.Containers)
-		return true
-	}
-
-	// Template Spec Containers [0] Name
-	if actual.Template.Spec.Containers[0].Name != expected.Template.Spec.Containers[0].Name {
-		reqLogger.Info("Deployment name mismatch found", "actual", actual.Template.Spec.Containers[0].Name, "expected", expected.Template.Spec.Containers[0].Name)
-	}
-
-	// Template Spec Containers [0] ReadinessProbe
-	if !reflect.DeepEqual(actual.Template.Spec.Containers[0].ReadinessProbe, expected.Template.Spec.Containers[0].ReadinessProbe) {
-		reqLogger.Info("Deployment readiness probe mismatch found", "actual", actual.Template.Spec.Containers[0].ReadinessProbe, "expected", expected.Template.Spec.Containers[0].ReadinessProbe)
-		return true
-	}
-
-	// Template Spec Containers [0] LivenessProbe
-	if !reflect.DeepEqual(actual.Template.Spec.Containers[0].LivenessProbe, expected.Template.Spec.Containers[0].LivenessProbe) {
-		reqLogger.Info("Deployment liveness probe mismatch found", "actual", actual.Template.Spec.Containers[0].LivenessProbe, "expected", expected.Template.Spec.Containers[0].LivenessProbe)
-		return true
-	}
-
-	// Template Spec Containers [0] Image
-	if actual.Template.Spec.Containers[0].Image != expected.Template.Spec.Containers[0].Image {
-		reqLogger.Info("Deployment image mismatch found", "actual", actual.Template.Spec.Containers[0].Image, "expected", expected.Template.Spec.Containers[0].Image)
-	}
-
-	// Template Spec Containers [0] Ports
-	if !reflect.DeepEqual(actual.Template.Spec.Containers[0].Ports, expected.Template.Spec.Containers[0].Ports) {
-		reqLogger.Info("Deployment ports mismatch found", "actual", actual.Template.Spec.Containers[0].Ports, "expected", expected.Template.Spec.Containers[0].Ports)
-		return true
-	}
-
-	// Template Spec Containers [0] VolumeMounts
-	if !reflect.DeepEqual(actual.Template.Spec.Containers[0].VolumeMounts, expected.Template.Spec.Containers[0].VolumeMounts) {
-		reqLogger.Info("Deployment volume mount mismatch found", "actual", actual.Template.Spec.Containers[0].VolumeMounts, "expected", expected.Template.Spec.Containers[0].VolumeMounts)
-		return true
+	// Pods are rolled out when worker config or mounted Synapse config and secret change
+	configHash, synapseConfigHash, err := r.getConfigHashes(instance, s)
+	if err != nil && errors.IsNotFound(err) {
+		// Cache has not seen just created objects or Synapse has not created its config yet - requeue
+		return reconcile.Result{Requeue: true}, nil
+	} else if err != nil {
+		return reconcile.Result{}, err
 	}
 
-	// Template Spec Containers [0] Args
-	if !reflect.DeepEqual(actual.Template.Spec.Containers[0].Args, expected.Template.Spec.Containers[0].Args) {
-		reqLogger.Info("Deployment args mismatch found", "actual", actual.Template.Spec.Containers[0].Args, "expected", expected.Template.Spec.Containers[0].Args)
-		return true
-	}
+	deployment := newDeploymentForCR(instance, s, configHash, synapseConfigHash)
 
-	// Template Spec Containers [0] Command
-	if !reflect.DeepEqual(actual.Template.Spec.Containers[0].Command, expected.Template.Spec.Containers[0].Command) {
-		reqLogger.Info("Deployment command mismatch found", "actual", actual.Template.Spec.Containers[0].Command, "expected", expected.Template.Spec.Containers[0].Command)
-		return true
+	// Set SynapseWorker instance as the owner and controller
+	if err := controllerutil.SetControllerReference(instance, deployment, r.scheme); err != nil {
+		return reconcile.Result{}, err
 	}
 
-	return false
-}
-
-func (r *ReconcileSynapseWorker) forceDeploymentRollout(request reconcile.Request, instance *synapsev1alphav1.SynapseWorker, reqLogger logr.Logger, s *synapsev1alphav1.Synapse) (reconcile.Result, error) {
-	deployment := newDeploymentForCR(instance, s)
 	found := &appsv1.Deployment{}
-	err := r.client.Get(context.TODO(), types.NamespacedName{Name: deployment.Name, Namespace: deployment.Namespace}, found)
-	if err != nil {
-		// No deployment exists, odd
-		return reconcile.Result{Requeue: true}, err
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: deployment.Name, Namespace: deployment.Namespace}, found)
+	if err != nil && !errors.IsNotFound(err) {
+		return reconcile.Result{}, err
+	} else if err == nil {
+		// Synapse config changes are rolled out after Synapse itself, so that workers
+		// don't run with config the homeserver hasn't picked up yet
+		actualSynapseConfigHash := found.Spec.Template.Annotations[rollout.SynapseConfigHashAnnotation]
+		if actualSynapseConfigHash != synapseConfigHash {
+			rolledOut, err := r.isSynapseRolledOut(s, synapseConfigHash)
+			if err != nil {
+				return reconcile.Result{Requeue: true}, err
+			}
+			if !rolledOut {
+				reqLogger.Info("Waiting for Synapse to roll out new config", "Synapse.Namespace", s.Namespace, "Synapse.Name", s.Name)
+				deployment.Spec.Template.Annotations[rollout.SynapseConfigHashAnnotation] = actualSynapseConfigHash
+			}
+		}
 	}
 
-	// Update annotation in the pod template to force deployment rollout
-	reqLogger.Info("Config/Secret changed: rolling out new pods", "Deployment.Namespace", found.Namespace, "Deployment.Name", found.Name)
-	found.Spec.Template.Annotations = map[string]string{
-		"synapse-operator/force-rollout": fmt.Sprintf("config changed at %q", time.Now().String()),
-	}
-	err = r.client.Update(context.TODO(), found)
-	if err != nil {
-		return reconcile.Result{Requeue: true}, err
+	reqLogger.Info("Applying Deployment", "Deployment.Namespace", deployment.Namespace, "Deployment.Name", deployment.Name)
+	if err := r.applier.Apply(instance, deployment); err != nil {
+		return reconcile.Result{}, err
 	}
 	return reconcile.Result{}, nil
-
 }
 
 func getWorkerVolume(cr *synapsev1alphav1.SynapseWorker) corev1.Volume {
@@ -171,8 +86,12 @@ func getWorkerVolume(cr *synapsev1alphav1.SynapseWorker) corev1.Volume {
 				},
 				Items: []corev1.KeyToPath{
 					{
-						Key:  "worker.yaml",
-						Path: "worker.yaml",
+						Key:  synapsev1alphav1.WorkerConfigFileName,
+						Path: synapsev1alphav1.WorkerConfigFileName,
+					},
+					{
+						Key:  synapsev1alphav1.WorkerLogConfigFileName,
+						Path: synapsev1alphav1.WorkerLogConfigFileName,
 					},
 				},
 				DefaultMode: &mode,
@@ -182,28 +101,30 @@ func getWorkerVolume(cr *synapsev1alphav1.SynapseWorker) corev1.Volume {
 }
 
 func getVolumes(cr *synapsev1alphav1.SynapseWorker, s *synapsev1alphav1.Synapse) []corev1.Volume {
-	return append(s.GetVolumes(), getWorkerVolume(cr))
+	return append(s.GetWorkerVolumes(cr.Spec.Worker), getWorkerVolume(cr))
 }
 
 func getWorkerVolumeMounts(cr *synapsev1alphav1.SynapseWorker) corev1.VolumeMount {
 	return corev1.VolumeMount{
 		Name:      "worker-config",
-		MountPath: "/synapse/config/worker.yaml",
+		MountPath: synapsev1alphav1.WorkerConfigMountPath,
 	}
 }
 
 func getVolumeMounts(cr *synapsev1alphav1.SynapseWorker, s *synapsev1alphav1.Synapse) []corev1.VolumeMount {
-	return append(s.GetVolumeMounts(), getWorkerVolumeMounts(cr))
+	return append(s.GetWorkerVolumeMounts(cr.Spec.Worker), getWorkerVolumeMounts(cr))
 }
 
 func getContainerPorts(cr *synapsev1alphav1.SynapseWorker) []corev1.ContainerPort {
-	return []corev1.ContainerPort{
-		{
-			Name:          "http",
-			ContainerPort: int32(cr.Spec.Port),
+	var ports []corev1.ContainerPort
+	for _, listener := range cr.GetListeners() {
+		ports = append(ports, corev1.ContainerPort{
+			Name:          listener.Name,
+			ContainerPort: int32(listener.Port),
 			Protocol:      corev1.ProtocolTCP,
-		},
+		})
 	}
+	return ports
 }
 
 func getDeploymentLabels(cr *synapsev1alphav1.SynapseWorker) map[string]string {
@@ -212,11 +133,11 @@ func getDeploymentLabels(cr *synapsev1alphav1.SynapseWorker) map[string]string {
 	}
 }
 
-func getExpectedDeploymentSpec(cr *synapsev1alphav1.SynapseWorker, s *synapsev1alphav1.Synapse) appsv1.DeploymentSpec {
+func getExpectedDeploymentSpec(cr *synapsev1alphav1.SynapseWorker, s *synapsev1alphav1.Synapse, configHash, synapseConfigHash string) appsv1.DeploymentSpec {
 
 	replicas := int32(cr.Spec.Replicas)
 
-	return appsv1.DeploymentSpec{
+	spec := appsv1.DeploymentSpec{
 		Replicas: &replicas,
 		Selector: &metav1.LabelSelector{
 			MatchLabels: getDeploymentLabels(cr),
@@ -228,31 +149,34 @@ func getExpectedDeploymentSpec(cr *synapsev1alphav1.SynapseWorker, s *synapsev1a
 				Labels:    getDeploymentLabels(cr),
 			},
 			Spec: corev1.PodSpec{
-				Volumes: getVolumes(cr, s),
+				Volumes:        getVolumes(cr, s),
+				InitContainers: s.GetInitContainers(),
 				Containers: []corev1.Container{
 					{
 						Name:         "worker",
-						Image:        s.Spec.Image,
+						Image:        s.GetImage(),
 						Ports:        getContainerPorts(cr),
 						VolumeMounts: getVolumeMounts(cr, s),
-						Args: []string{
-							cr.Spec.Worker, "-c", "/synapse/config/worker.yaml",
-						},
+						Command:      s.GetCommand(cr.Spec.Worker, path.Join(synapsev1alphav1.WorkerConfigMountPath, synapsev1alphav1.WorkerConfigFileName)),
 					},
 				},
 			},
 		},
 	}
+	cr.Spec.PodTemplate.Apply(&spec.Template.Spec, "worker")
+	rollout.SetConfigHash(&spec.Template, configHash)
+	spec.Template.Annotations[rollout.SynapseConfigHashAnnotation] = synapseConfigHash
+	return spec
 }
 
 // newDeploymentForCR returns a busybox pod with the same name/namespace as the cr
-func newDeploymentForCR(cr *synapsev1alphav1.SynapseWorker, s *synapsev1alphav1.Synapse) *appsv1.Deployment {
+func newDeploymentForCR(cr *synapsev1alphav1.SynapseWorker, s *synapsev1alphav1.Synapse, configHash, synapseConfigHash string) *appsv1.Deployment {
 	return &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      cr.GetDeploymentName(),
 			Namespace: cr.Namespace,
 			Labels:    getDeploymentLabels(cr),
 		},
-		Spec: getExpectedDeploymentSpec(cr, s),
+		Spec: getExpectedDeploymentSpec(cr, s, configHash, synapseConfigHash),
 	}
 }
@@ -1,16 +1,11 @@
 package synapseworker
 
 import (
-	"context"
-	"reflect"
-
 	"github.com/go-logr/logr"
 	synapsev1alphav1 "github.com/vrutkovs/synapse-operator/pkg/apis/synapse/v1alpha1"
 
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -24,58 +19,29 @@ func (r *ReconcileSynapseWorker) reconcileService(request reconcile.Request, ins
 		return reconcile.Result{}, err
 	}
 
-	// Check if this Service already exists
-	found := &corev1.Service{}
-	err := r.client.Get(context.TODO(), types.NamespacedName{Name: service.Name, Namespace: service.Namespace}, found)
-	if err != nil && errors.IsNotFound(err) {
-		reqLogger.Info("Creating a new Service", "Service.Namespace", service.Namespace, "Service.Name", service.Name)
-		err = r.client.Create(context.TODO(), service)
-		if err != nil {
-			return reconcile.Result{}, err
-		}
-
-		// Service created successfully - don't requeue
-		return reconcile.Result{}, nil
-	} else if err != nil {
-		// Unknown error - requeue
-		reqLogger.Info("Service reconcile error", "Service.Namespace", found.Namespace, "Service.Name", found.Name, "Error", err)
-		return reconcile.Result{Requeue: true}, nil
-	} else if err == nil {
-		// Check if Service fields haven't change
-
-		expectedSpec := getExpectedServiceSpec(instance, s)
-		if serviceNeedsUpdate(&found.Spec, &expectedSpec, reqLogger) {
-			found.ObjectMeta = service.ObjectMeta
-			controllerutil.SetControllerReference(instance, found, r.scheme)
-			found.Spec = expectedSpec
-			err = r.client.Update(context.TODO(), found)
-			if err != nil {
-				return reconcile.Result{Requeue: true}, err
-			}
-			reqLogger.Info("Service spec updated", "Service.Namespace", found.Namespace, "Service.Name", found.Name)
-			return reconcile.Result{}, nil
-		}
+	reqLogger.Info("Applying Service", "Service.Namespace", service.Namespace, "Service.Name", service.Name)
+	if err := r.applier.Apply(instance, service); err != nil {
+		return reconcile.Result{}, err
 	}
-
-	// Service already exists - don't requeue
-	reqLogger.Info("Skip reconcile: Service already exists", "Service.Namespace", found.Namespace, "Service.Name", found.Name)
 	return reconcile.Result{}, nil
 }
 
-// getExpectedServiceData returns expected data stored in Service
+// getExpectedServiceData returns expected data stored in Service, a port is exposed for each listener
 func getExpectedServiceSpec(cr *synapsev1alphav1.SynapseWorker, s *synapsev1alphav1.Synapse) corev1.ServiceSpec {
+	var ports []corev1.ServicePort
+	for _, listener := range cr.GetListeners() {
+		ports = append(ports, corev1.ServicePort{
+			Name:       listener.Name,
+			Protocol:   corev1.ProtocolTCP,
+			TargetPort: intstr.IntOrString{Type: intstr.Int, IntVal: int32(listener.Port)},
+			Port:       int32(listener.Port),
+		})
+	}
 
 	return corev1.ServiceSpec{
 		Selector: getDeploymentLabels(cr),
 		Type:     corev1.ServiceTypeClusterIP,
-		Ports: []corev1.ServicePort{
-			{
-				Name:       "http",
-				Protocol:   corev1.ProtocolTCP,
-				TargetPort: intstr.IntOrString{Type: intstr.Int, IntVal: int32(cr.Spec.Port)},
-				Port:       int32(cr.Spec.Port),
-			},
-		},
+		Ports:    ports,
 	}
 }
 
@@ -93,19 +59,3 @@ func newServiceForCR(cr *synapsev1alphav1.SynapseWorker, s *synapsev1alphav1.Syn
 		Spec: getExpectedServiceSpec(cr, s),
 	}
 }
-
-func serviceNeedsUpdate(actual, expected *corev1.ServiceSpec, reqLogger logr.Logger) bool {
-	// Selector
-	if !reflect.DeepEqual(actual.Selector, expected.Selector) {
-		reqLogger.Info("Service selector mismatch found", "actual", actual.Selector, "expected", expected.Selector)
-		return true
-	}
-
-	// Ports
-	if !reflect.DeepEqual(actual.Ports, expected.Ports) {
-		reqLogger.Info("Service ports mismatch found", "actual", actual.Ports, "expected", expected.Ports)
-		return true
-	}
-
-	return false
-}
@@ -0,0 +1,157 @@
+package synapseworker
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/go-logr/logr"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	synapsev1alpha1 "github.com/vrutkovs/synapse-operator/pkg/apis/synapse/v1alpha1"
+	"github.com/vrutkovs/synapse-operator/pkg/controller/apply"
+	"github.com/vrutkovs/synapse-operator/pkg/controller/rollout"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// updateStatus records resolved Synapse, replica readiness and config drift in SynapseWorker status.
+// Synapse is nil if it doesn't exist
+func (r *ReconcileSynapseWorker) updateStatus(instance *synapsev1alpha1.SynapseWorker, s *synapsev1alpha1.Synapse, reqLogger logr.Logger) error {
+	newStatus := instance.Status.DeepCopy()
+	newStatus.ObservedGeneration = instance.Generation
+
+	// Fetch deployment to find out how many replicas are ready
+	found := &appsv1.Deployment{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: instance.GetDeploymentName(), Namespace: instance.Namespace}, found)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	deploymentFound := err == nil
+	newStatus.Replicas = int32(instance.Spec.Replicas)
+	newStatus.ReadyReplicas = found.Status.ReadyReplicas
+	newStatus.Endpoint = getEndpoint(instance)
+
+	if deploymentFound && found.Status.AvailableReplicas >= newStatus.Replicas {
+		newStatus.Conditions.SetCondition(status.Condition{
+			Type:    synapsev1alpha1.SynapseWorkerConditionAvailable,
+			Status:  corev1.ConditionTrue,
+			Reason:  "DeploymentAvailable",
+			Message: "All worker replicas are available",
+		})
+	} else {
+		newStatus.Conditions.SetCondition(status.Condition{
+			Type:    synapsev1alpha1.SynapseWorkerConditionAvailable,
+			Status:  corev1.ConditionFalse,
+			Reason:  "DeploymentNotAvailable",
+			Message: "Waiting for worker replicas to become available",
+		})
+	}
+
+	newStatus.Conditions.SetCondition(apply.ConflictCondition(synapsev1alpha1.SynapseWorkerConditionFieldConflict, r.applier.Conflicts(instance)))
+
+	if s == nil {
+		message := fmt.Sprintf("Synapse %s does not exist", instance.Spec.Synapse)
+		newStatus.Synapse = ""
+		newStatus.Conditions.SetCondition(status.Condition{
+			Type:    synapsev1alpha1.SynapseWorkerConditionParentNotFound,
+			Status:  corev1.ConditionTrue,
+			Reason:  "SynapseNotFound",
+			Message: message,
+		})
+		newStatus.Conditions.SetCondition(status.Condition{
+			Type:    synapsev1alpha1.SynapseWorkerConditionWaitingForSynapse,
+			Status:  corev1.ConditionTrue,
+			Reason:  "SynapseNotFound",
+			Message: message,
+		})
+	} else {
+		newStatus.Synapse = s.Name
+		synapseGeneration, err := r.getRenderedSynapseGeneration(instance)
+		if err != nil {
+			return err
+		}
+		newStatus.SynapseGeneration = synapseGeneration
+		newStatus.Conditions.SetCondition(status.Condition{
+			Type:   synapsev1alpha1.SynapseWorkerConditionParentNotFound,
+			Status: corev1.ConditionFalse,
+			Reason: "SynapseFound",
+		})
+
+		configHash, synapseConfigHash, err := r.getConfigHashes(instance, s)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		annotations := found.Spec.Template.Annotations
+		synapseConfigOutdated := err != nil || annotations[rollout.SynapseConfigHashAnnotation] != synapseConfigHash
+		if deploymentFound && synapseConfigOutdated {
+			newStatus.Conditions.SetCondition(status.Condition{
+				Type:    synapsev1alpha1.SynapseWorkerConditionWaitingForSynapse,
+				Status:  corev1.ConditionTrue,
+				Reason:  "SynapseRolloutInProgress",
+				Message: "Waiting for Synapse to roll out new config",
+			})
+		} else {
+			newStatus.Conditions.SetCondition(status.Condition{
+				Type:   synapsev1alpha1.SynapseWorkerConditionWaitingForSynapse,
+				Status: corev1.ConditionFalse,
+				Reason: "SynapseFound",
+			})
+		}
+
+		if !deploymentFound || synapseConfigOutdated || annotations[rollout.ConfigHashAnnotation] != configHash || !rollout.IsComplete(found) {
+			newStatus.Conditions.SetCondition(status.Condition{
+				Type:    synapsev1alpha1.SynapseWorkerConditionConfigOutOfDate,
+				Status:  corev1.ConditionTrue,
+				Reason:  "RolloutInProgress",
+				Message: "Worker pods don't run current config yet",
+			})
+		} else {
+			newStatus.Conditions.SetCondition(status.Condition{
+				Type:   synapsev1alpha1.SynapseWorkerConditionConfigOutOfDate,
+				Status: corev1.ConditionFalse,
+				Reason: "RolloutComplete",
+			})
+		}
+	}
+
+	// Skip the update if nothing has changed to avoid reconcile loops
+	if reflect.DeepEqual(&instance.Status, newStatus) {
+		return nil
+	}
+	instance.Status = *newStatus
+	reqLogger.Info("Updating SynapseWorker status", "Synapse", newStatus.Synapse, "ReadyReplicas", newStatus.ReadyReplicas)
+	return r.client.Status().Update(context.TODO(), instance)
+}
+
+// getRenderedSynapseGeneration returns Synapse generation recorded when worker configmap was rendered,
+// or 0 if it's not rendered yet
+func (r *ReconcileSynapseWorker) getRenderedSynapseGeneration(instance *synapsev1alpha1.SynapseWorker) (int64, error) {
+	cm := &corev1.ConfigMap{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: instance.GetConfigMapName(), Namespace: instance.Namespace}, cm)
+	if errors.IsNotFound(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	generation, ok := cm.Annotations[rollout.SynapseGenerationAnnotation]
+	if !ok {
+		return 0, nil
+	}
+	return strconv.ParseInt(generation, 10, 64)
+}
+
+// getEndpoint returns service address of worker HTTP listener, or the first listener if there is none
+func getEndpoint(cr *synapsev1alpha1.SynapseWorker) string {
+	port := cr.GetHTTPPort()
+	if listeners := cr.GetListeners(); port == 0 && len(listeners) > 0 {
+		port = listeners[0].Port
+	}
+	if port == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s.%s.svc:%d", cr.GetServiceName(), cr.Namespace, port)
+}
@@ -0,0 +1,98 @@
+package synapseworker
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/go-logr/logr"
+
+	synapsev1alpha1 "github.com/vrutkovs/synapse-operator/pkg/apis/synapse/v1alpha1"
+	"github.com/vrutkovs/synapse-operator/pkg/controller/rollout"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// getWorkersForSynapse returns requests for all workers referencing the Synapse
+func getWorkersForSynapse(c client.Client, namespace, name string) []reconcile.Request {
+	workers := &synapsev1alpha1.SynapseWorkerList{}
+	if err := c.List(context.TODO(), workers, client.InNamespace(namespace)); err != nil {
+		log.Error(err, "Failed to list SynapseWorker instances", "Synapse.Namespace", namespace, "Synapse.Name", name)
+		return nil
+	}
+	requests := []reconcile.Request{}
+	for _, worker := range workers.Items {
+		if worker.Spec.Synapse == name {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: worker.Name, Namespace: worker.Namespace},
+			})
+		}
+	}
+	return requests
+}
+
+// getWorkersForSynapseObject maps Synapse to its workers
+func getWorkersForSynapseObject(c client.Client, a handler.MapObject) []reconcile.Request {
+	return getWorkersForSynapse(c, a.Meta.GetNamespace(), a.Meta.GetName())
+}
+
+// getWorkersForOwnedObject maps an object owned by Synapse to its workers. Only objects mounted
+// by workers and the homeserver deployment are considered, selected by name returned by getName
+func getWorkersForOwnedObject(c client.Client, a handler.MapObject, getName func(*synapsev1alpha1.Synapse) string) []reconcile.Request {
+	owner := metav1.GetControllerOf(a.Meta)
+	if owner == nil || owner.Kind != "Synapse" || owner.APIVersion != synapsev1alpha1.SchemeGroupVersion.String() {
+		return nil
+	}
+	synapse := &synapsev1alpha1.Synapse{ObjectMeta: metav1.ObjectMeta{Name: owner.Name}}
+	if a.Meta.GetName() != getName(synapse) {
+		return nil
+	}
+	return getWorkersForSynapse(c, a.Meta.GetNamespace(), owner.Name)
+}
+
+// reconcileSynapseOwnerReference makes Synapse an owner of the worker when deletion policy is Delete,
+// so that worker is garbage collected with Synapse, and removes the reference otherwise
+func (r *ReconcileSynapseWorker) reconcileSynapseOwnerReference(instance *synapsev1alpha1.SynapseWorker, reqLogger logr.Logger, s *synapsev1alpha1.Synapse) error {
+	ownerRefs := []metav1.OwnerReference{}
+	for _, ref := range instance.OwnerReferences {
+		// Drop references to previous Synapse instances too
+		if ref.Kind == "Synapse" && ref.APIVersion == synapsev1alpha1.SchemeGroupVersion.String() {
+			continue
+		}
+		ownerRefs = append(ownerRefs, ref)
+	}
+	if instance.Spec.SynapseDeletionPolicy != synapsev1alpha1.SynapseDeletionPolicyOrphan {
+		ownerRefs = append(ownerRefs, metav1.OwnerReference{
+			APIVersion: synapsev1alpha1.SchemeGroupVersion.String(),
+			Kind:       "Synapse",
+			Name:       s.Name,
+			UID:        s.UID,
+		})
+	}
+	if len(ownerRefs) == 0 {
+		ownerRefs = nil
+	}
+	if reflect.DeepEqual(instance.OwnerReferences, ownerRefs) {
+		return nil
+	}
+	reqLogger.Info("Updating SynapseWorker owner references", "SynapseDeletionPolicy", instance.Spec.SynapseDeletionPolicy)
+	instance.OwnerReferences = ownerRefs
+	return r.client.Update(context.TODO(), instance)
+}
+
+// isSynapseRolledOut returns true if homeserver deployment has finished rolling out config with the hash
+func (r *ReconcileSynapseWorker) isSynapseRolledOut(s *synapsev1alpha1.Synapse, configHash string) (bool, error) {
+	deployment := &appsv1.Deployment{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: s.GetDeploymentName(), Namespace: s.Namespace}, deployment)
+	if err != nil {
+		return false, err
+	}
+	if deployment.Spec.Template.Annotations[rollout.ConfigHashAnnotation] != configHash {
+		return false, nil
+	}
+	return rollout.IsComplete(deployment), nil
+}
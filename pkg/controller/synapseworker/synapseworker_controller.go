@@ -3,7 +3,9 @@ package synapseworker
 import (
 	"context"
 
+	"github.com/go-logr/logr"
 	synapsev1alpha1 "github.com/vrutkovs/synapse-operator/pkg/apis/synapse/v1alpha1"
+	"github.com/vrutkovs/synapse-operator/pkg/controller/apply"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -27,7 +29,11 @@ func Add(mgr manager.Manager) error {
 
 // newReconciler returns a new reconcile.Reconciler
 func newReconciler(mgr manager.Manager) reconcile.Reconciler {
-	return &ReconcileSynapseWorker{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+	return &ReconcileSynapseWorker{
+		client:  mgr.GetClient(),
+		scheme:  mgr.GetScheme(),
+		applier: apply.NewApplier(mgr.GetClient(), mgr.GetScheme()),
+	}
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
@@ -68,6 +74,44 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		return err
 	}
 
+	// Watch for changes to referenced Synapse, its config, secret and deployment,
+	// so that workers are rolled out after Synapse
+	err = c.Watch(&source.Kind{Type: &synapsev1alpha1.Synapse{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(a handler.MapObject) []reconcile.Request {
+			return getWorkersForSynapseObject(mgr.GetClient(), a)
+		}),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = c.Watch(&source.Kind{Type: &corev1.ConfigMap{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(a handler.MapObject) []reconcile.Request {
+			return getWorkersForOwnedObject(mgr.GetClient(), a, (*synapsev1alpha1.Synapse).GetConfigMapName)
+		}),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = c.Watch(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(a handler.MapObject) []reconcile.Request {
+			return getWorkersForOwnedObject(mgr.GetClient(), a, (*synapsev1alpha1.Synapse).GetSecretName)
+		}),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = c.Watch(&source.Kind{Type: &appsv1.Deployment{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(a handler.MapObject) []reconcile.Request {
+			return getWorkersForOwnedObject(mgr.GetClient(), a, (*synapsev1alpha1.Synapse).GetDeploymentName)
+		}),
+	})
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -80,6 +124,8 @@ type ReconcileSynapseWorker struct {
 	// that reads objects from the cache and writes to the apiserver
 	client client.Client
 	scheme *runtime.Scheme
+	// applier manages configmaps, deployments and services via server-side apply
+	applier *apply.Applier
 }
 
 // Reconcile reads that state of the cluster for a SynapseWorker object and makes changes based on the state read
@@ -107,26 +153,40 @@ func (r *ReconcileSynapseWorker) Reconcile(request reconcile.Request) (reconcile
 
 	// Find referenced Synapse object
 	s, err := instance.FindReferencedSynapse(r.client)
-	if err != nil {
-		reqLogger.Info("Deployment reconcile error", "Referenced Synapse object not found", err)
+	if err != nil && errors.IsNotFound(err) {
+		// Synapse watch would enqueue the worker when Synapse is created
+		reqLogger.Info("Waiting for referenced Synapse", "Synapse.Namespace", instance.Namespace, "Synapse.Name", instance.Spec.Synapse)
+		return reconcile.Result{}, r.updateStatus(instance, nil, reqLogger)
+	} else if err != nil {
 		return reconcile.Result{}, err
 	}
 
-	result, cmUpdated, err := r.reconcileConfigMap(request, instance, reqLogger, s)
+	r.applier.Reset(instance)
+	result, err := r.reconcileResources(request, instance, reqLogger, s)
 	if err != nil {
 		return result, err
 	}
 
-	result, created, err := r.reconcileDeployment(request, instance, reqLogger, s)
+	if err := r.updateStatus(instance, s, reqLogger); err != nil {
+		return reconcile.Result{}, err
+	}
+	return result, nil
+}
+
+// reconcileResources creates or updates all resources managed by SynapseWorker instance
+func (r *ReconcileSynapseWorker) reconcileResources(request reconcile.Request, instance *synapsev1alpha1.SynapseWorker, reqLogger logr.Logger, s *synapsev1alpha1.Synapse) (reconcile.Result, error) {
+	if err := r.reconcileSynapseOwnerReference(instance, reqLogger, s); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	result, err := r.reconcileConfigMap(request, instance, reqLogger, s)
 	if err != nil {
 		return result, err
 	}
 
-	// If either of configMap or secret has been updated force rollout
-	if cmUpdated && !created {
-		if result, err := r.forceDeploymentRollout(request, instance, reqLogger, s); err != nil {
-			return result, err
-		}
+	result, err = r.reconcileDeployment(request, instance, reqLogger, s)
+	if err != nil || result.Requeue {
+		return result, err
 	}
 
 	result, err = r.reconcileService(request, instance, reqLogger, s)
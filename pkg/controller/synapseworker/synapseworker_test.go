@@ -0,0 +1,352 @@
+package synapseworker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/onsi/ginkgo"
+	g "github.com/onsi/gomega"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/vrutkovs/synapse-operator/pkg/apis/common"
+	synapsev1alpha1 "github.com/vrutkovs/synapse-operator/pkg/apis/synapse/v1alpha1"
+	"github.com/vrutkovs/synapse-operator/pkg/controller/rollout"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var Testing *testing.T
+
+func TestGinkgo(t *testing.T) {
+	g.RegisterFailHandler(ginkgo.Fail)
+	Testing = t
+	ginkgo.RunSpecs(t, "unit tests")
+}
+
+var _ = ginkgo.Describe("[synapseworker]", func() {
+	var (
+		cl          client.Client
+		t           *testing.T
+		name        string
+		synapseName string
+		ns          string
+		spec        synapsev1alpha1.SynapseWorkerSpec
+	)
+	ginkgo.BeforeEach(func() {
+		t = Testing
+		name = "example-worker"
+		synapseName = "example-synapse"
+		ns = "synapse"
+		spec = synapsev1alpha1.SynapseWorkerSpec{
+			Replicas: 1,
+			Synapse:  synapseName,
+			Worker:   "synapse.app.generic_worker",
+			Protocol: "http",
+			Port:     8083,
+		}
+	})
+
+	ginkgo.It("should map Synapse objects to workers", func() {
+		instance := initFakeSynapseWorker(t, name, ns, &spec)
+		synapseObjs := initFakeSynapse(t, synapseName, ns)
+		other := initFakeSynapseWorker(t, "other-worker", ns, &synapsev1alpha1.SynapseWorkerSpec{Synapse: "other-synapse"})
+		cl = initFakeClient(t, instance, name, ns, append(synapseObjs, other)...)
+
+		expected := []reconcile.Request{{NamespacedName: types.NamespacedName{Name: name, Namespace: ns}}}
+		s := synapseObjs[0].(*synapsev1alpha1.Synapse)
+		g.Expect(getWorkersForSynapseObject(cl, handler.MapObject{Meta: s, Object: s})).To(g.Equal(expected))
+		for _, obj := range synapseObjs[1:] {
+			meta := obj.(metav1.Object)
+			g.Expect(getWorkersForOwnedObject(cl, handler.MapObject{Meta: meta, Object: obj}, func(s *synapsev1alpha1.Synapse) string {
+				return meta.GetName()
+			})).To(g.Equal(expected))
+		}
+
+		// Objects with matching names which are not owned by Synapse are ignored
+		cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: s.GetConfigMapName(), Namespace: ns}}
+		g.Expect(getWorkersForOwnedObject(cl, handler.MapObject{Meta: cm, Object: cm}, (*synapsev1alpha1.Synapse).GetConfigMapName)).To(g.BeEmpty())
+		// Owned objects workers don't use are ignored
+		cm = synapseObjs[1].(*corev1.ConfigMap)
+		g.Expect(getWorkersForOwnedObject(cl, handler.MapObject{Meta: cm, Object: cm}, (*synapsev1alpha1.Synapse).GetSecretName)).To(g.BeEmpty())
+	})
+
+	ginkgo.It("should wait for Synapse", func() {
+		instance := initFakeSynapseWorker(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns)
+		found := getSynapseWorker(t, instance, cl, ns)
+		g.Expect(found.Status.Conditions.IsTrueFor(synapsev1alpha1.SynapseWorkerConditionWaitingForSynapse)).To(g.BeTrue())
+		g.Expect(found.Status.Conditions.IsTrueFor(synapsev1alpha1.SynapseWorkerConditionParentNotFound)).To(g.BeTrue())
+
+		for _, obj := range initFakeSynapse(t, synapseName, ns) {
+			err := cl.Create(context.TODO(), obj)
+			g.Expect(err).NotTo(g.HaveOccurred())
+		}
+		reconcileSynapseWorker(t, cl, name, ns)
+		found = getSynapseWorker(t, instance, cl, ns)
+		g.Expect(found.Status.Conditions.IsFalseFor(synapsev1alpha1.SynapseWorkerConditionWaitingForSynapse)).To(g.BeTrue())
+		getDeployment(t, instance, cl, ns)
+	})
+
+	ginkgo.It("should set Synapse owner reference according to deletion policy", func() {
+		instance := initFakeSynapseWorker(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns, initFakeSynapse(t, synapseName, ns)...)
+		found := getSynapseWorker(t, instance, cl, ns)
+		g.Expect(found.OwnerReferences).To(g.Equal([]metav1.OwnerReference{{
+			APIVersion: synapsev1alpha1.SchemeGroupVersion.String(),
+			Kind:       "Synapse",
+			Name:       synapseName,
+			UID:        types.UID(synapseName),
+		}}))
+
+		found.Spec.SynapseDeletionPolicy = synapsev1alpha1.SynapseDeletionPolicyOrphan
+		err := cl.Update(context.TODO(), found)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		reconcileSynapseWorker(t, cl, name, ns)
+		g.Expect(getSynapseWorker(t, instance, cl, ns).OwnerReferences).To(g.BeEmpty())
+	})
+
+	ginkgo.It("should generate worker config", func() {
+		instance := initFakeSynapseWorker(t, name, ns, &spec)
+		synapseObjs := initFakeSynapse(t, synapseName, ns)
+		cl = initFakeClient(t, instance, name, ns, synapseObjs...)
+		config := parseWorkerConfig(t, getConfigMap(t, instance, cl, ns))
+		g.Expect(config).To(g.HaveKeyWithValue("worker_app", "synapse.app.generic_worker"))
+		g.Expect(config).To(g.HaveKeyWithValue("worker_replication_host", "example-synapse-service"))
+		g.Expect(config).To(g.HaveKeyWithValue("worker_replication_port", float64(9093)))
+		g.Expect(config).NotTo(g.HaveKey("worker_replication_http_port"))
+		g.Expect(config).NotTo(g.HaveKey("redis"))
+
+		// Workers use HTTP replication port with Redis
+		s := synapseObjs[0].(*synapsev1alpha1.Synapse)
+		err := cl.Get(context.TODO(), types.NamespacedName{Name: s.Name, Namespace: ns}, s)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		s.Spec.Redis = &synapsev1alpha1.SynapseRedis{}
+		err = cl.Update(context.TODO(), s)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		reconcileSynapseWorker(t, cl, name, ns)
+		config = parseWorkerConfig(t, getConfigMap(t, instance, cl, ns))
+		g.Expect(config).NotTo(g.HaveKey("worker_replication_port"))
+		g.Expect(config).To(g.HaveKeyWithValue("worker_replication_http_port", float64(9093)))
+		// Redis section with password comes from homeserver.yaml and secrets.yaml
+		g.Expect(config).NotTo(g.HaveKey("redis"))
+	})
+
+	ginkgo.It("should expose every listener", func() {
+		spec.Port = 0
+		spec.Listeners = []synapsev1alpha1.SynapseWorkerListenerSpec{
+			{
+				Type: "http",
+				Port: 8083,
+				Resources: []synapsev1alpha1.SynapseWorkerResource{
+					{Names: []string{"client"}},
+				},
+			},
+			{
+				Type: "http",
+				Port: 8084,
+				Resources: []synapsev1alpha1.SynapseWorkerResource{
+					{Names: []string{"federation"}},
+				},
+			},
+			{
+				Name: "prometheus",
+				Type: "metrics",
+				Port: 9100,
+			},
+		}
+		instance := initFakeSynapseWorker(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns, initFakeSynapse(t, synapseName, ns)...)
+
+		g.Expect(getDeployment(t, instance, cl, ns).Spec.Template.Spec.Containers[0].Ports).To(g.Equal([]corev1.ContainerPort{
+			{Name: "http-0", ContainerPort: 8083, Protocol: corev1.ProtocolTCP},
+			{Name: "http-1", ContainerPort: 8084, Protocol: corev1.ProtocolTCP},
+			{Name: "prometheus", ContainerPort: 9100, Protocol: corev1.ProtocolTCP},
+		}))
+		svc := &corev1.Service{}
+		err := cl.Get(context.TODO(), types.NamespacedName{Name: instance.GetServiceName(), Namespace: ns}, svc)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		g.Expect(svc.Spec.Ports).To(g.HaveLen(3))
+		g.Expect(svc.Spec.Ports[2].Name).To(g.Equal("prometheus"))
+		g.Expect(svc.Spec.Ports[2].Port).To(g.Equal(int32(9100)))
+
+		config := parseWorkerConfig(t, getConfigMap(t, instance, cl, ns))
+		g.Expect(config["worker_listeners"]).To(g.Equal([]interface{}{
+			map[string]interface{}{
+				"type":      "http",
+				"port":      float64(8083),
+				"resources": []interface{}{map[string]interface{}{"names": []interface{}{"client"}}},
+			},
+			map[string]interface{}{
+				"type":      "http",
+				"port":      float64(8084),
+				"resources": []interface{}{map[string]interface{}{"names": []interface{}{"federation"}}},
+			},
+			map[string]interface{}{
+				"type":      "metrics",
+				"port":      float64(9100),
+				"resources": []interface{}{},
+			},
+		}))
+		g.Expect(instance.GetHTTPPort()).To(g.Equal(8083))
+	})
+
+	ginkgo.It("should roll out after Synapse", func() {
+		instance := initFakeSynapseWorker(t, name, ns, &spec)
+		synapseObjs := initFakeSynapse(t, synapseName, ns)
+		cl = initFakeClient(t, instance, name, ns, synapseObjs...)
+		s := synapseObjs[0].(*synapsev1alpha1.Synapse)
+		synapseHash := getDeployment(t, instance, cl, ns).Spec.Template.Annotations[rollout.SynapseConfigHashAnnotation]
+		g.Expect(synapseHash).To(g.HaveLen(64))
+
+		// Synapse config change is held back until Synapse has rolled it out
+		cm := &corev1.ConfigMap{}
+		err := cl.Get(context.TODO(), types.NamespacedName{Name: s.GetConfigMapName(), Namespace: ns}, cm)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		cm.Data["homeserver"] = "report_stats: false\n"
+		err = cl.Update(context.TODO(), cm)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		reconcileSynapseWorker(t, cl, name, ns)
+		g.Expect(getDeployment(t, instance, cl, ns).Spec.Template.Annotations).To(g.HaveKeyWithValue(rollout.SynapseConfigHashAnnotation, synapseHash))
+		found := getSynapseWorker(t, instance, cl, ns)
+		g.Expect(found.Status.Conditions.IsTrueFor(synapsev1alpha1.SynapseWorkerConditionWaitingForSynapse)).To(g.BeTrue())
+		g.Expect(found.Status.Conditions.IsTrueFor(synapsev1alpha1.SynapseWorkerConditionConfigOutOfDate)).To(g.BeTrue())
+
+		synapseDeployment := &appsv1.Deployment{}
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: s.GetDeploymentName(), Namespace: ns}, synapseDeployment)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		newHash, err := rollout.GetConfigHash(cl, ns, []string{s.GetConfigMapName()}, []string{s.GetSecretName()})
+		g.Expect(err).NotTo(g.HaveOccurred())
+		synapseDeployment.Spec.Template.Annotations[rollout.ConfigHashAnnotation] = newHash
+		synapseDeployment.Status.UpdatedReplicas = 0
+		err = cl.Update(context.TODO(), synapseDeployment)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		reconcileSynapseWorker(t, cl, name, ns)
+		g.Expect(getDeployment(t, instance, cl, ns).Spec.Template.Annotations).To(g.HaveKeyWithValue(rollout.SynapseConfigHashAnnotation, synapseHash))
+
+		// Workers pick up new config once Synapse rollout completes
+		synapseDeployment.Status.UpdatedReplicas = 1
+		err = cl.Update(context.TODO(), synapseDeployment)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		reconcileSynapseWorker(t, cl, name, ns)
+		g.Expect(getDeployment(t, instance, cl, ns).Spec.Template.Annotations).To(g.HaveKeyWithValue(rollout.SynapseConfigHashAnnotation, newHash))
+		found = getSynapseWorker(t, instance, cl, ns)
+		g.Expect(found.Status.Conditions.IsFalseFor(synapsev1alpha1.SynapseWorkerConditionWaitingForSynapse)).To(g.BeTrue())
+	})
+
+	ginkgo.It("should run worker app with homeserver and worker config", func() {
+		synapseObjs := initFakeSynapse(t, synapseName, ns)
+		instance := initFakeSynapseWorker(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns, synapseObjs...)
+		container := getDeployment(t, instance, cl, ns).Spec.Template.Spec.Containers[0]
+		g.Expect(container.Command).To(g.Equal([]string{
+			"python", "-m", spec.Worker,
+			"--config-path", "/synapse/config/homeserver.yaml",
+			"--config-path", "/synapse/keys/secrets.yaml",
+			"--config-path", "/synapse/worker/worker.yaml",
+		}))
+		g.Expect(container.VolumeMounts).To(g.ContainElement(corev1.VolumeMount{
+			Name:      "worker-config",
+			MountPath: "/synapse/worker",
+		}))
+	})
+
+	ginkgo.It("should only mount media store in media repository workers", func() {
+		synapseObjs := initFakeSynapse(t, synapseName, ns)
+		s := synapseObjs[0].(*synapsev1alpha1.Synapse)
+		s.Spec.Storage = &synapsev1alpha1.SynapseStorage{Size: resource.MustParse("1Gi")}
+		instance := initFakeSynapseWorker(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns, synapseObjs...)
+		pod := getDeployment(t, instance, cl, ns).Spec.Template.Spec
+		for _, volume := range pod.Volumes {
+			g.Expect(volume.Name).NotTo(g.Equal("media-store"))
+		}
+
+		found := getSynapseWorker(t, instance, cl, ns)
+		found.Spec.Worker = "synapse.app.media_repository"
+		err := cl.Update(context.TODO(), found)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		reconcileSynapseWorker(t, cl, name, ns)
+		pod = getDeployment(t, instance, cl, ns).Spec.Template.Spec
+		g.Expect(pod.Volumes).To(g.ContainElement(corev1.Volume{
+			Name: "media-store",
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: s.GetMediaStorePVCName(),
+				},
+			},
+		}))
+		g.Expect(pod.Containers[0].VolumeMounts).To(g.ContainElement(corev1.VolumeMount{
+			Name:      "media-store",
+			MountPath: synapsev1alpha1.MediaStoreMountPath,
+		}))
+	})
+
+	ginkgo.It("should apply pod template overrides", func() {
+		spec.PodTemplate = &common.PodTemplate{
+			Resources: &corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceCPU: resource.MustParse("100m"),
+				},
+			},
+			NodeSelector: map[string]string{"disktype": "ssd"},
+		}
+		instance := initFakeSynapseWorker(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns, initFakeSynapse(t, synapseName, ns)...)
+		pod := getDeployment(t, instance, cl, ns).Spec.Template.Spec
+		g.Expect(pod.NodeSelector).To(g.Equal(map[string]string{"disktype": "ssd"}))
+		g.Expect(pod.Containers[0].Resources.Requests.Cpu().String()).To(g.Equal("100m"))
+	})
+
+	ginkgo.It("should report Synapse generation worker config was rendered from", func() {
+		synapseObjs := initFakeSynapse(t, synapseName, ns)
+		s := synapseObjs[0].(*synapsev1alpha1.Synapse)
+		s.Generation = 2
+		instance := initFakeSynapseWorker(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns, synapseObjs...)
+		g.Expect(getConfigMap(t, instance, cl, ns).Annotations).To(g.HaveKeyWithValue(rollout.SynapseGenerationAnnotation, "2"))
+		g.Expect(getSynapseWorker(t, instance, cl, ns).Status.SynapseGeneration).To(g.Equal(int64(2)))
+
+		err := cl.Get(context.TODO(), types.NamespacedName{Name: s.Name, Namespace: ns}, s)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		s.Generation = 3
+		err = cl.Update(context.TODO(), s)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		reconcileSynapseWorker(t, cl, name, ns)
+		g.Expect(getConfigMap(t, instance, cl, ns).Annotations).To(g.HaveKeyWithValue(rollout.SynapseGenerationAnnotation, "3"))
+		g.Expect(getSynapseWorker(t, instance, cl, ns).Status.SynapseGeneration).To(g.Equal(int64(3)))
+	})
+
+	ginkgo.It("should report status", func() {
+		instance := initFakeSynapseWorker(t, name, ns, &spec)
+		cl = initFakeClient(t, instance, name, ns, initFakeSynapse(t, synapseName, ns)...)
+		found := getSynapseWorker(t, instance, cl, ns)
+		g.Expect(found.Status.Synapse).To(g.Equal(synapseName))
+		g.Expect(found.Status.Replicas).To(g.Equal(int32(1)))
+		g.Expect(found.Status.ReadyReplicas).To(g.Equal(int32(0)))
+		g.Expect(found.Status.Endpoint).To(g.Equal("example-worker-server.synapse.svc:8083"))
+		g.Expect(found.Status.Conditions.IsFalseFor(synapsev1alpha1.SynapseWorkerConditionParentNotFound)).To(g.BeTrue())
+		g.Expect(found.Status.Conditions.IsFalseFor(synapsev1alpha1.SynapseWorkerConditionWaitingForSynapse)).To(g.BeTrue())
+		g.Expect(found.Status.Conditions.IsFalseFor(synapsev1alpha1.SynapseWorkerConditionAvailable)).To(g.BeTrue())
+		g.Expect(found.Status.Conditions.IsTrueFor(synapsev1alpha1.SynapseWorkerConditionConfigOutOfDate)).To(g.BeTrue())
+
+		deployment := getDeployment(t, instance, cl, ns)
+		deployment.Status = appsv1.DeploymentStatus{
+			Replicas:          1,
+			UpdatedReplicas:   1,
+			ReadyReplicas:     1,
+			AvailableReplicas: 1,
+		}
+		err := cl.Update(context.TODO(), deployment)
+		g.Expect(err).NotTo(g.HaveOccurred())
+		reconcileSynapseWorker(t, cl, name, ns)
+		found = getSynapseWorker(t, instance, cl, ns)
+		g.Expect(found.Status.ReadyReplicas).To(g.Equal(int32(1)))
+		g.Expect(found.Status.Conditions.IsTrueFor(synapsev1alpha1.SynapseWorkerConditionAvailable)).To(g.BeTrue())
+		g.Expect(found.Status.Conditions.IsFalseFor(synapsev1alpha1.SynapseWorkerConditionConfigOutOfDate)).To(g.BeTrue())
+	})
+})
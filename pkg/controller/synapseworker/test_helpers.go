@@ -0,0 +1,140 @@
+package synapseworker
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/kubectl/pkg/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
+
+	synapsev1alpha1 "github.com/vrutkovs/synapse-operator/pkg/apis/synapse/v1alpha1"
+	"github.com/vrutkovs/synapse-operator/pkg/controller/apply"
+	applyfake "github.com/vrutkovs/synapse-operator/pkg/controller/apply/fake"
+	"github.com/vrutkovs/synapse-operator/pkg/controller/rollout"
+
+	g "github.com/onsi/gomega"
+)
+
+func initFakeSynapseWorker(t *testing.T, name, ns string, spec *synapsev1alpha1.SynapseWorkerSpec) *synapsev1alpha1.SynapseWorker {
+	return &synapsev1alpha1.SynapseWorker{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ns,
+		},
+		Spec: *spec,
+	}
+}
+
+// initFakeSynapse returns Synapse with objects its controller would have created:
+// configmap, secret and a fully rolled out deployment
+func initFakeSynapse(t *testing.T, name, ns string) []runtime.Object {
+	s := &synapsev1alpha1.Synapse{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ns,
+			UID:       types.UID(name),
+		},
+		Spec: synapsev1alpha1.SynapseSpec{
+			ServerName: "foo.bar",
+			Ports: synapsev1alpha1.SynapsePorts{
+				HTTP:        8008,
+				HTTPS:       8448,
+				Replication: 9093,
+			},
+		},
+	}
+	owner := *metav1.NewControllerRef(s, synapsev1alpha1.SchemeGroupVersion.WithKind("Synapse"))
+	objectMeta := func(name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       ns,
+			OwnerReferences: []metav1.OwnerReference{owner},
+		}
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: objectMeta(s.GetConfigMapName()),
+		Data:       map[string]string{"homeserver": "report_stats: true\n"},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: objectMeta(s.GetSecretName()),
+		Data:       map[string][]byte{"signingKey": []byte("foo")},
+	}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: objectMeta(s.GetDeploymentName()),
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						rollout.ConfigHashAnnotation: rollout.ConfigHash([]*corev1.ConfigMap{cm}, []*corev1.Secret{secret}),
+					},
+				},
+			},
+		},
+		Status: appsv1.DeploymentStatus{
+			Replicas:          1,
+			UpdatedReplicas:   1,
+			AvailableReplicas: 1,
+		},
+	}
+	return []runtime.Object{s, cm, secret, deployment}
+}
+
+func initFakeClient(t *testing.T, worker *synapsev1alpha1.SynapseWorker, name, ns string, extraObjs ...runtime.Object) client.Client {
+	objs := []runtime.Object{worker}
+	s := scheme.Scheme
+	s.AddKnownTypes(synapsev1alpha1.SchemeGroupVersion, worker, &synapsev1alpha1.SynapseWorkerList{}, &synapsev1alpha1.Synapse{}, &synapsev1alpha1.SynapseList{})
+	objs = append(objs, extraObjs...)
+
+	cl := applyfake.NewClient(fake.NewFakeClientWithScheme(s, objs...), s)
+	reconcileSynapseWorker(t, cl, name, ns)
+	return cl
+}
+
+func reconcileSynapseWorker(t *testing.T, cl client.Client, name, ns string) {
+	r := &ReconcileSynapseWorker{client: cl, scheme: scheme.Scheme, applier: apply.NewApplier(cl, scheme.Scheme)}
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      name,
+			Namespace: ns,
+		},
+	}
+	res, err := r.Reconcile(req)
+	g.Expect(err).NotTo(g.HaveOccurred(), "failed to reconcile")
+	g.Expect(res).To(g.Equal(reconcile.Result{}), "reconcile did not return an empty Result")
+}
+
+func getSynapseWorker(t *testing.T, worker *synapsev1alpha1.SynapseWorker, cl client.Client, ns string) *synapsev1alpha1.SynapseWorker {
+	found := &synapsev1alpha1.SynapseWorker{}
+	err := cl.Get(context.TODO(), types.NamespacedName{Name: worker.Name, Namespace: ns}, found)
+	g.Expect(err).NotTo(g.HaveOccurred(), "failed to get synapse worker")
+	return found
+}
+
+func getConfigMap(t *testing.T, worker *synapsev1alpha1.SynapseWorker, cl client.Client, ns string) *corev1.ConfigMap {
+	cm := &corev1.ConfigMap{}
+	err := cl.Get(context.TODO(), types.NamespacedName{Name: worker.GetConfigMapName(), Namespace: ns}, cm)
+	g.Expect(err).NotTo(g.HaveOccurred(), "failed to get configmap")
+	return cm
+}
+
+func parseWorkerConfig(t *testing.T, cm *corev1.ConfigMap) map[string]interface{} {
+	config := map[string]interface{}{}
+	err := yaml.Unmarshal([]byte(cm.Data["worker.yaml"]), &config)
+	g.Expect(err).NotTo(g.HaveOccurred(), "failed to parse worker config")
+	return config
+}
+
+func getDeployment(t *testing.T, worker *synapsev1alpha1.SynapseWorker, cl client.Client, ns string) *appsv1.Deployment {
+	dep := &appsv1.Deployment{}
+	err := cl.Get(context.TODO(), types.NamespacedName{Name: worker.GetDeploymentName(), Namespace: ns}, dep)
+	g.Expect(err).NotTo(g.HaveOccurred(), "failed to get deployment")
+	return dep
+}
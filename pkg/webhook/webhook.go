@@ -0,0 +1,27 @@
+package webhook
+
+import (
+	riotv1alpha1 "github.com/vrutkovs/synapse-operator/pkg/apis/riot/v1alpha1"
+	synapsev1alpha1 "github.com/vrutkovs/synapse-operator/pkg/apis/synapse/v1alpha1"
+
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// webhookSetup is implemented by API types which serve admission webhooks
+type webhookSetup interface {
+	SetupWebhookWithManager(mgr manager.Manager) error
+}
+
+// AddToManager registers admission webhooks of all API types in the manager webhook server
+func AddToManager(m manager.Manager) error {
+	for _, w := range []webhookSetup{
+		&synapsev1alpha1.Synapse{},
+		&synapsev1alpha1.SynapseWorker{},
+		&riotv1alpha1.Riot{},
+	} {
+		if err := w.SetupWebhookWithManager(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/onsi/ginkgo"
+	g "github.com/onsi/gomega"
+
+	riotv1alpha1 "github.com/vrutkovs/synapse-operator/pkg/apis/riot/v1alpha1"
+	synapsev1alpha1 "github.com/vrutkovs/synapse-operator/pkg/apis/synapse/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestGinkgo(t *testing.T) {
+	g.RegisterFailHandler(ginkgo.Fail)
+	ginkgo.RunSpecs(t, "unit tests")
+}
+
+// getCauseFields returns field paths of invalid object error
+func getCauseFields(err error) []string {
+	statusErr, ok := err.(*apierrors.StatusError)
+	g.Expect(ok).To(g.BeTrue())
+	fields := []string{}
+	for _, cause := range statusErr.ErrStatus.Details.Causes {
+		fields = append(fields, cause.Field)
+	}
+	return fields
+}
+
+func newSynapse() *synapsev1alpha1.Synapse {
+	return &synapsev1alpha1.Synapse{
+		ObjectMeta: metav1.ObjectMeta{Name: "example-synapse", Namespace: "synapse"},
+		Spec: synapsev1alpha1.SynapseSpec{
+			ServerName: "example.com",
+			Ports: synapsev1alpha1.SynapsePorts{
+				HTTP:        8008,
+				HTTPS:       8448,
+				Replication: 9093,
+			},
+			Config: synapsev1alpha1.SynapseConfig{
+				Logging: "version: 1",
+			},
+		},
+	}
+}
+
+var _ = ginkgo.Describe("[validation]", func() {
+	ginkgo.It("should accept valid synapse", func() {
+		g.Expect(newSynapse().ValidateCreate()).To(g.Succeed())
+
+		synapse := newSynapse()
+		synapse.Spec.ServerName = "example.com:8448"
+		g.Expect(synapse.ValidateCreate()).To(g.Succeed())
+	})
+
+	ginkgo.It("should reject invalid synapse", func() {
+		synapse := newSynapse()
+		synapse.Spec.ServerName = "Example_com"
+		synapse.Spec.Ports.Replication = 0
+		synapse.Spec.Ports.Metrics = 8008
+		synapse.Spec.Config.Homeserver = "server_name: ["
+		synapse.Spec.Config.Logging = "version: ["
+		synapse.Spec.Redis = &synapsev1alpha1.SynapseRedis{
+			PasswordSecretRef: &corev1.SecretKeySelector{Key: "password"},
+		}
+		synapse.Spec.Secrets = synapsev1alpha1.SynapseSecrets{
+			Cert:    "cert",
+			CertRef: &corev1.SecretKeySelector{Key: "cert"},
+		}
+		err := synapse.ValidateCreate()
+		g.Expect(apierrors.IsInvalid(err)).To(g.BeTrue())
+		g.Expect(getCauseFields(err)).To(g.ConsistOf(
+			"spec.serverName",
+			"spec.ports.replication",
+			"spec.ports.metrics",
+			"spec.configuration.homeserver",
+			"spec.configuration.logging",
+			"spec.redis.passwordSecretRef",
+			"spec.secrets.cert",
+			"spec.secrets.key",
+		))
+		g.Expect(err.Error()).To(g.ContainSubstring("8008 is already used by http port"))
+	})
+
+	ginkgo.It("should report homeserver config errors on the failed field", func() {
+		synapse := newSynapse()
+		synapse.Spec.Config.Settings = &synapsev1alpha1.SynapseSettings{
+			Caches: &synapsev1alpha1.SynapseCachesSettings{
+				GlobalFactor:    "1.0",
+				PerCacheFactors: map[string]string{"get_users_who_share_room_with_user": "1.5"},
+			},
+		}
+		synapse.Spec.Config.Overrides = &runtime.RawExtension{Raw: []byte(`{"report_stats": false}`)}
+		g.Expect(synapse.ValidateCreate()).To(g.Succeed())
+
+		synapse.Spec.Config.Settings.Caches.GlobalFactor = "high"
+		synapse.Spec.Config.Settings.Caches.PerCacheFactors["get_users_who_share_room_with_user"] = "low"
+		synapse.Spec.Config.Overrides.Raw = []byte(`{"report_stats": false,}`)
+		err := synapse.ValidateCreate()
+		g.Expect(apierrors.IsInvalid(err)).To(g.BeTrue())
+		g.Expect(getCauseFields(err)).To(g.ConsistOf(
+			"spec.configuration.settings.caches.globalFactor",
+			"spec.configuration.settings.caches.perCacheFactors[get_users_who_share_room_with_user]",
+			"spec.configuration.overrides",
+		))
+	})
+
+	ginkgo.It("should validate synapse worker", func() {
+		worker := &synapsev1alpha1.SynapseWorker{
+			ObjectMeta: metav1.ObjectMeta{Name: "sync", Namespace: "synapse"},
+			Spec: synapsev1alpha1.SynapseWorkerSpec{
+				Synapse: "example-synapse",
+				Worker:  "synapse.app.generic_worker",
+				Port:    8083,
+			},
+		}
+		g.Expect(worker.ValidateCreate()).To(g.Succeed())
+
+		worker.Spec.Synapse = ""
+		worker.Spec.Worker = "synapse.app.unknown"
+		worker.Spec.SynapseDeletionPolicy = "Keep"
+		err := worker.ValidateUpdate(worker)
+		g.Expect(apierrors.IsInvalid(err)).To(g.BeTrue())
+		g.Expect(getCauseFields(err)).To(g.ConsistOf("spec.synapse", "spec.worker", "spec.synapseDeletionPolicy"))
+
+		worker = &synapsev1alpha1.SynapseWorker{
+			ObjectMeta: metav1.ObjectMeta{Name: "sync", Namespace: "synapse"},
+			Spec: synapsev1alpha1.SynapseWorkerSpec{
+				Synapse: "example-synapse",
+				Worker:  "synapse.app.synchrotron",
+				Listeners: []synapsev1alpha1.SynapseWorkerListenerSpec{
+					{Type: "http", Port: 8083},
+					{Type: "metrics", Port: 9100},
+				},
+			},
+		}
+		g.Expect(worker.ValidateCreate()).To(g.Succeed())
+
+		worker.Spec.Port = 8083
+		worker.Spec.Listeners = append(worker.Spec.Listeners, synapsev1alpha1.SynapseWorkerListenerSpec{Name: "metrics", Type: "http", Port: 9100})
+		err = worker.ValidateUpdate(worker)
+		g.Expect(apierrors.IsInvalid(err)).To(g.BeTrue())
+		g.Expect(getCauseFields(err)).To(g.ConsistOf("spec.port", "spec.listeners[2].port", "spec.listeners[2].name"))
+	})
+
+	ginkgo.It("should validate riot config", func() {
+		riot := &riotv1alpha1.Riot{
+			ObjectMeta: metav1.ObjectMeta{Name: "example-riot", Namespace: "synapse"},
+			Spec: riotv1alpha1.RiotSpec{
+				Config: `{"brand": "Riot"}`,
+			},
+		}
+		g.Expect(riot.ValidateCreate()).To(g.Succeed())
+
+		riot.Spec.Config = `{"brand": "Riot",}`
+		err := riot.ValidateCreate()
+		g.Expect(apierrors.IsInvalid(err)).To(g.BeTrue())
+		g.Expect(getCauseFields(err)).To(g.ConsistOf("spec.config"))
+	})
+
+	ginkgo.It("should validate riot settings", func() {
+		riot := &riotv1alpha1.Riot{
+			ObjectMeta: metav1.ObjectMeta{Name: "example-riot", Namespace: "synapse"},
+			Spec: riotv1alpha1.RiotSpec{
+				SynapseRef: &corev1.LocalObjectReference{Name: "example-synapse"},
+				Features:   map[string]string{"feature_pinning": "labs"},
+				Jitsi:      &riotv1alpha1.RiotJitsi{PreferredDomain: "jitsi.example.com"},
+			},
+		}
+		g.Expect(riot.ValidateCreate()).To(g.Succeed())
+
+		riot.Spec.SynapseRef.Name = ""
+		riot.Spec.Features["feature_pinning"] = "on"
+		riot.Spec.Jitsi.PreferredDomain = ""
+		err := riot.ValidateUpdate(riot)
+		g.Expect(apierrors.IsInvalid(err)).To(g.BeTrue())
+		g.Expect(getCauseFields(err)).To(g.ConsistOf("spec.synapseRef.name", "spec.features[feature_pinning]", "spec.jitsi.preferredDomain"))
+	})
+
+	ginkgo.It("should set defaults", func() {
+		synapse := &synapsev1alpha1.Synapse{
+			Spec: synapsev1alpha1.SynapseSpec{
+				ServerName: "example.com",
+				Ports: synapsev1alpha1.SynapsePorts{
+					HTTP: 8080,
+				},
+				Storage: &synapsev1alpha1.SynapseStorage{},
+			},
+		}
+		synapse.Default()
+		g.Expect(synapse.Spec.Image).To(g.Equal(synapsev1alpha1.DefaultImage))
+		g.Expect(synapse.Spec.Ports).To(g.Equal(synapsev1alpha1.SynapsePorts{
+			HTTP:        8080,
+			HTTPS:       8448,
+			Replication: 9093,
+		}))
+		g.Expect(synapse.Spec.Storage.DeletionPolicy).To(g.Equal(synapsev1alpha1.StorageDeletionPolicyRetain))
+		g.Expect(synapse.ValidateCreate()).To(g.Succeed())
+
+		worker := &synapsev1alpha1.SynapseWorker{}
+		worker.Default()
+		g.Expect(worker.Spec.Protocol).To(g.Equal("http"))
+		g.Expect(worker.Spec.SynapseDeletionPolicy).To(g.Equal(synapsev1alpha1.SynapseDeletionPolicyDelete))
+
+		riot := &riotv1alpha1.Riot{}
+		riot.Default()
+		g.Expect(riot.Spec.Image).To(g.Equal(riotv1alpha1.DefaultImage))
+	})
+})